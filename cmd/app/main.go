@@ -19,6 +19,11 @@ import (
 // @in header
 // @name Authorization
 // @description Для доступа к защищенным эндпоинтам, укажите токен в формате "Bearer ваш_токен"
+//
+// @securityDefinitions.apikey MachineKeyAuth
+// @in header
+// @name X-API-Key
+// @description API-ключ пользователя для скриптов (POST /me/api-keys); принимается эндпоинтами объявлений
 func main() {
 	application, err := app.New()
 	if err != nil {
@@ -10,10 +10,17 @@ import (
 	"marketplace/internal/handler"
 	cache "marketplace/internal/repository/cache"
 	"marketplace/internal/repository/postgres"
+	"marketplace/internal/scheduler"
 	"marketplace/internal/service"
 	"marketplace/pkg/auth"
 	redis "marketplace/pkg/cache"
+	"marketplace/pkg/hash"
 	"marketplace/pkg/logger"
+	"marketplace/pkg/mailer"
+	"marketplace/pkg/oidc"
+	"marketplace/pkg/passwordpolicy"
+	"marketplace/pkg/ratelimit"
+	"marketplace/pkg/screening"
 	"net/http"
 	"os"
 	"os/signal"
@@ -28,6 +35,7 @@ import (
 type App struct {
 	log         *slog.Logger
 	server      *http.Server
+	publisher   *scheduler.Publisher
 	dbPool      *pgxpool.Pool
 	redisClient *redis.CacheClient
 }
@@ -70,15 +78,27 @@ func New() (*App, error) {
 		return nil, fmt.Errorf("failed to init token manager: %w", err)
 	}
 
-	// 7. Инициализация роутера
-	router := initRouter(dbPool, redisClient, tokenManager, cfg, log)
+	// 7. Инициализация отправки писем
+	mail, err := mailer.New(cfg.Mail)
+	if err != nil {
+		dbPool.Close()
+		redisClient.Client.Close()
+		return nil, fmt.Errorf("failed to init mailer: %w", err)
+	}
+
+	// 8. Инициализация роутера
+	router, services := initRouter(dbPool, redisClient, tokenManager, mail, cfg, log)
 
-	// 8. Настройка HTTP-сервера
+	// 9. Настройка HTTP-сервера
 	server := initServer(cfg, router)
 
+	// 10. Фоновая публикация отложенных объявлений
+	publisher := scheduler.NewPublisher(services.Ad, cfg.Scheduler.PublishInterval, log)
+
 	return &App{
 		log:         log,
 		server:      server,
+		publisher:   publisher,
 		dbPool:      dbPool,
 		redisClient: redisClient,
 	}, nil
@@ -88,6 +108,9 @@ func New() (*App, error) {
 func (a *App) Run() {
 	a.log.Info("starting server", slog.String("addr", a.server.Addr))
 
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	go a.publisher.Run(schedulerCtx)
+
 	go func() {
 		if err := a.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			a.log.Error("failed to start server", slog.String("error", err.Error()))
@@ -100,6 +123,7 @@ func (a *App) Run() {
 	<-quit
 
 	a.log.Info("shutting down server...")
+	stopScheduler()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -178,7 +202,15 @@ func initDB(cfg *config.Config, log *slog.Logger) (*pgxpool.Pool, error) {
 }
 
 // initRouter собирает все слои приложения и инициализирует роутер.
-func initRouter(dbPool *pgxpool.Pool, redis *redis.CacheClient, tm *auth.TokenManager, cfg *config.Config, log *slog.Logger) *gin.Engine {
+// Сервисный слой возвращается для фоновых задач.
+func initRouter(
+	dbPool *pgxpool.Pool,
+	redis *redis.CacheClient,
+	tm *auth.TokenManager,
+	mail mailer.Mailer,
+	cfg *config.Config,
+	log *slog.Logger,
+) (*gin.Engine, *service.Service) {
 	// 1. Создаем основной репозиторий, который работает с PostgreSQL.
 	postgresRepos := postgres.NewRepository(dbPool)
 
@@ -187,15 +219,76 @@ func initRouter(dbPool *pgxpool.Pool, redis *redis.CacheClient, tm *auth.TokenMa
 
 	// 3. Создаем "обертку" для репозиториев, где Ad заменен на кеширующий.
 	finalRepos := &postgres.Repository{
-		User: postgresRepos.User,
-		Ad:   cachedAdRepo,
+		User:          postgresRepos.User,
+		Ad:            cachedAdRepo,
+		Tag:           postgresRepos.Tag,
+		Promotion:     postgresRepos.Promotion,
+		Report:        postgresRepos.Report,
+		Notification:  postgresRepos.Notification,
+		RefreshToken:  postgresRepos.RefreshToken,
+		PasswordReset: postgresRepos.PasswordReset,
+		Verification:  postgresRepos.Verification,
+		TwoFactor:     postgresRepos.TwoFactor,
+		Audit:         postgresRepos.Audit,
+		APIKey:        postgresRepos.APIKey,
+		Identity:      postgresRepos.Identity,
+		Session:       postgresRepos.Session,
 	}
 
 	// 4. Передаем итоговый набор репозиториев в сервис.
 	// AdService теперь будет работать с кеширующей версией, даже не зная об этом.
-	services := service.NewService(finalRepos, tm)
-	handlers := handler.NewHandler(services, tm, log)
-	return handlers.InitRoutes()
+	duplicates := service.DuplicatePolicy{
+		Action:         screening.Action(cfg.Screening.Duplicates.Action),
+		Window:         cfg.Screening.Duplicates.Window,
+		Similarity:     cfg.Screening.Duplicates.Similarity,
+		PriceTolerance: cfg.Screening.Duplicates.PriceTolerance,
+	}
+	verification := service.VerificationPolicy{
+		Required: cfg.Auth.RequireVerifiedEmail,
+		TTL:      cfg.Auth.EmailVerificationTTL,
+		BaseURL:  cfg.Mail.PublicURL,
+	}
+	twoFactor := service.TwoFactorPolicy{
+		Issuer:       cfg.Auth.TOTPIssuer,
+		ChallengeTTL: cfg.Auth.TwoFactorChallengeTTL,
+	}
+	login := service.LoginPolicy{
+		MaxAttempts:   cfg.Auth.LoginProtection.MaxAttempts,
+		IPMaxAttempts: cfg.Auth.LoginProtection.IPMaxAttempts,
+		BaseDelay:     cfg.Auth.LoginProtection.BaseDelay,
+		MaxDelay:      cfg.Auth.LoginProtection.MaxDelay,
+		Window:        cfg.Auth.LoginProtection.Window,
+	}
+	passwords := hash.NewHasher(hash.Params{
+		Memory:      cfg.Auth.PasswordHashing.Memory,
+		Iterations:  cfg.Auth.PasswordHashing.Iterations,
+		Parallelism: cfg.Auth.PasswordHashing.Parallelism,
+		SaltLength:  cfg.Auth.PasswordHashing.SaltLength,
+		KeyLength:   cfg.Auth.PasswordHashing.KeyLength,
+	})
+	services := service.NewService(service.Deps{
+		Repos:            finalRepos,
+		Cache:            cache.NewRepository(redis),
+		TokenManager:     tm,
+		Passwords:        passwords,
+		PasswordPolicy:   passwordpolicy.NewFromConfig(cfg.Auth.PasswordPolicy),
+		Screener:         screening.NewFromConfig(cfg.Screening),
+		Duplicates:       duplicates,
+		Mailer:           mail,
+		PasswordResetTTL: cfg.Auth.PasswordResetTTL,
+		Verification:     verification,
+		TwoFactor:        twoFactor,
+		Login:            login,
+		OIDCProviders:    oidc.NewProviders(cfg.Auth.OIDC.Providers),
+		OIDCStateTTL:     cfg.Auth.OIDC.StateTTL,
+		Log:              log,
+	})
+
+	// 5. Лимитер для подсказок поиска, чтобы через них нельзя было выгрузить каталог.
+	suggestLimiter := ratelimit.NewRedisLimiter(redis.Client, "suggest", cfg.Suggest.RateLimit, cfg.Suggest.RateWindow)
+
+	handlers := handler.NewHandler(services, tm, suggestLimiter, log)
+	return handlers.InitRoutes(), services
 }
 
 // initServer настраивает HTTP-сервер.
@@ -2,8 +2,11 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"marketplace/pkg/hash"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -17,6 +20,10 @@ type Config struct {
 	Auth       Auth       `mapstructure:"auth"`
 	Redis      Redis      `mapstructure:"redis"`
 	Swagger    Swagger    `mapstructure:"swagger"`
+	Suggest    Suggest    `mapstructure:"suggest"`
+	Screening  Screening  `mapstructure:"screening"`
+	Scheduler  Scheduler  `mapstructure:"scheduler"`
+	Mail       Mail       `mapstructure:"mail"`
 }
 
 type HTTPServer struct {
@@ -34,9 +41,105 @@ type Database struct {
 	SSLMode  string `mapstructure:"sslmode"`
 }
 
+// Auth настраивает выдачу токенов. Access-токен живет недолго,
+// долгую сессию поддерживает refresh-токен, который меняется при каждом обновлении.
+// Если заданы SigningKeys, access-токены подписываются асимметричным ключом
+// ActiveKeyID, иначе - HS256 на общем секрете JWTSecret.
 type Auth struct {
-	JWTSecret string        `mapstructure:"jwtsecret"`
-	TokenTTL  time.Duration `mapstructure:"token_ttl"`
+	JWTSecret   string       `mapstructure:"jwtsecret"`
+	SigningKeys []SigningKey `mapstructure:"signing_keys"`
+	ActiveKeyID string       `mapstructure:"active_kid"`
+	// Issuer и Audience записываются в iss и aud токена и проверяются при разборе.
+	Issuer          string        `mapstructure:"issuer"`
+	Audience        string        `mapstructure:"audience"`
+	AccessTokenTTL  time.Duration `mapstructure:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `mapstructure:"refresh_token_ttl"`
+	// PasswordResetTTL - время жизни одноразового токена сброса пароля.
+	PasswordResetTTL time.Duration `mapstructure:"password_reset_ttl"`
+	// EmailVerificationTTL - время жизни ссылки подтверждения почты.
+	EmailVerificationTTL time.Duration `mapstructure:"email_verification_ttl"`
+	// RequireVerifiedEmail запрещает создавать объявления до подтверждения почты.
+	RequireVerifiedEmail bool `mapstructure:"require_verified_email"`
+	// TOTPIssuer - название сервиса, под которым аккаунт виден в приложении-аутентификаторе.
+	TOTPIssuer string `mapstructure:"totp_issuer"`
+	// TwoFactorChallengeTTL - сколько времени после пароля дается на ввод кода 2FA.
+	TwoFactorChallengeTTL time.Duration `mapstructure:"two_factor_challenge_ttl"`
+	// LoginProtection ограничивает подбор паролей.
+	LoginProtection LoginProtection `mapstructure:"login_protection"`
+	// OIDC - вход через внешних провайдеров OpenID Connect.
+	OIDC OIDC `mapstructure:"oidc"`
+	// PasswordHashing - параметры argon2id для хеширования паролей.
+	PasswordHashing PasswordHashing `mapstructure:"password_hashing"`
+	// PasswordPolicy - требования к новым паролям.
+	PasswordPolicy PasswordPolicy `mapstructure:"password_policy"`
+}
+
+// PasswordPolicy задает требования к паролю при регистрации, смене и сбросе.
+// BreachedPath - каталог с диапазонами SHA-1 утекших паролей (файлы
+// <PREFIX>.txt со строками SUFFIX:COUNT); пустое значение отключает проверку.
+type PasswordPolicy struct {
+	MinLength        int    `mapstructure:"min_length"`
+	RequireLowercase bool   `mapstructure:"require_lowercase"`
+	RequireUppercase bool   `mapstructure:"require_uppercase"`
+	RequireDigit     bool   `mapstructure:"require_digit"`
+	RequireSymbol    bool   `mapstructure:"require_symbol"`
+	ForbidUsername   bool   `mapstructure:"forbid_username"`
+	BreachedPath     string `mapstructure:"breached_path"`
+}
+
+// PasswordHashing задает стоимость argon2id. Memory указывается в КиБ. Хеши,
+// созданные с другими параметрами или bcrypt, пересчитываются при следующем входе.
+type PasswordHashing struct {
+	Memory      uint32 `mapstructure:"memory_kib"`
+	Iterations  uint32 `mapstructure:"iterations"`
+	Parallelism uint8  `mapstructure:"parallelism"`
+	SaltLength  uint32 `mapstructure:"salt_length"`
+	KeyLength   uint32 `mapstructure:"key_length"`
+}
+
+// OIDC задает провайдеров входа через OpenID Connect. StateTTL - сколько
+// времени у пользователя есть на вход у провайдера и возврат обратно.
+type OIDC struct {
+	StateTTL  time.Duration  `mapstructure:"state_ttl"`
+	Providers []OIDCProvider `mapstructure:"providers"`
+}
+
+// OIDCProvider - внешний провайдер OpenID Connect. Адреса эндпоинтов
+// определяются по discovery-документу Issuer. Секрет клиента можно передать
+// переменной окружения OIDC_<NAME>_CLIENT_SECRET.
+type OIDCProvider struct {
+	Name         string   `mapstructure:"name"`
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url"`
+	Scopes       []string `mapstructure:"scopes"`
+}
+
+// SigningKey - ключ подписи access-токенов в формате PEM. Алгоритм определяется
+// типом ключа: RSA - RS256, Ed25519 - EdDSA. Для ключа, которым токены больше не
+// подписываются, достаточно открытой части. RetiredAt (RFC 3339) - момент вывода
+// ключа из оборота: ключ принимается еще access_token_ttl, пока не истекут
+// выданные им токены, а затем исчезает из JWKS.
+type SigningKey struct {
+	ID             string `mapstructure:"kid"`
+	PrivateKey     string `mapstructure:"private_key"`
+	PrivateKeyPath string `mapstructure:"private_key_path"`
+	PublicKey      string `mapstructure:"public_key"`
+	PublicKeyPath  string `mapstructure:"public_key_path"`
+	RetiredAt      string `mapstructure:"retired_at"`
+}
+
+// LoginProtection задает защиту входа от подбора пароля. Неудачные попытки
+// считаются отдельно по имени пользователя и по IP-адресу в течение Window.
+// Когда их число достигает порога, вход по ключу блокируется на BaseDelay,
+// и каждая следующая неудача удваивает блокировку, но не дольше MaxDelay.
+type LoginProtection struct {
+	MaxAttempts   int           `mapstructure:"max_attempts"`
+	IPMaxAttempts int           `mapstructure:"ip_max_attempts"`
+	BaseDelay     time.Duration `mapstructure:"base_delay"`
+	MaxDelay      time.Duration `mapstructure:"max_delay"`
+	Window        time.Duration `mapstructure:"window"`
 }
 
 type Redis struct {
@@ -50,6 +153,71 @@ type Swagger struct {
 	Host string `mapstructure:"host"`
 }
 
+type Suggest struct {
+	RateLimit  int           `mapstructure:"rate_limit"`
+	RateWindow time.Duration `mapstructure:"rate_window"`
+}
+
+// Mail настраивает отправку писем. Driver smtp отправляет письма через SMTP-сервер,
+// driver log дописывает их в файл LogPath вместо отправки.
+type Mail struct {
+	Driver  string `mapstructure:"driver"`
+	From    string `mapstructure:"from"`
+	LogPath string `mapstructure:"log_path"`
+	SMTP    SMTP   `mapstructure:"smtp"`
+	// PublicURL - внешний адрес API, от которого строятся ссылки в письмах.
+	PublicURL string `mapstructure:"public_url"`
+}
+
+type SMTP struct {
+	Host     string `mapstructure:"host"`
+	Port     string `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+// Scheduler настраивает фоновые задачи приложения.
+type Scheduler struct {
+	// PublishInterval - как часто проверять наступление времени отложенной публикации.
+	PublishInterval time.Duration `mapstructure:"publish_interval"`
+}
+
+// Screening описывает правила автоматической проверки объявлений.
+// Action каждого правила: reject, premoderate или off.
+type Screening struct {
+	BannedWords BannedWordsRule `mapstructure:"banned_words"`
+	Contacts    ContactsRule    `mapstructure:"contacts"`
+	Caps        CapsRule        `mapstructure:"caps"`
+	Duplicates  DuplicatesRule  `mapstructure:"duplicates"`
+}
+
+type BannedWordsRule struct {
+	Action string   `mapstructure:"action"`
+	Words  []string `mapstructure:"words"`
+}
+
+type ContactsRule struct {
+	Action string `mapstructure:"action"`
+}
+
+type CapsRule struct {
+	Action     string  `mapstructure:"action"`
+	MaxRatio   float64 `mapstructure:"max_ratio"`
+	MinLetters int     `mapstructure:"min_letters"`
+}
+
+// DuplicatesRule ограничивает повторную публикацию похожего объявления
+// продавцом в течение Window. Объявления считаются похожими, если сходство
+// текста по триграммам не меньше Similarity, а цены расходятся не больше
+// чем на долю PriceTolerance. Те же пороги используются при поиске групп
+// повторов для модераторов.
+type DuplicatesRule struct {
+	Action         string        `mapstructure:"action"`
+	Window         time.Duration `mapstructure:"window"`
+	Similarity     float64       `mapstructure:"similarity"`
+	PriceTolerance float64       `mapstructure:"price_tolerance"`
+}
+
 func LoadConfig() *Config {
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, reading from environment")
@@ -86,6 +254,18 @@ func LoadConfig() *Config {
 
 	// Auth
 	_ = viper.BindEnv("auth.jwtsecret", "AUTH_JWT_SECRET")
+	_ = viper.BindEnv("auth.active_kid", "AUTH_ACTIVE_KID")
+	_ = viper.BindEnv("auth.issuer", "AUTH_ISSUER")
+	_ = viper.BindEnv("auth.audience", "AUTH_AUDIENCE")
+
+	// Mail
+	_ = viper.BindEnv("mail.driver", "MAIL_DRIVER")
+	_ = viper.BindEnv("mail.from", "MAIL_FROM")
+	_ = viper.BindEnv("mail.public_url", "MAIL_PUBLIC_URL")
+	_ = viper.BindEnv("mail.smtp.host", "MAIL_SMTP_HOST")
+	_ = viper.BindEnv("mail.smtp.port", "MAIL_SMTP_PORT")
+	_ = viper.BindEnv("mail.smtp.username", "MAIL_SMTP_USERNAME")
+	_ = viper.BindEnv("mail.smtp.password", "MAIL_SMTP_PASSWORD")
 
 	var cfg Config
 	if err := viper.Unmarshal(&cfg); err != nil {
@@ -96,6 +276,14 @@ func LoadConfig() *Config {
 		cfg.HTTPServer.Port = port
 	}
 
+	// Список провайдеров задается в файле, а секреты - в окружении.
+	for i, p := range cfg.Auth.OIDC.Providers {
+		env := "OIDC_" + strings.ToUpper(strings.ReplaceAll(p.Name, "-", "_")) + "_CLIENT_SECRET"
+		if secret := os.Getenv(env); secret != "" {
+			cfg.Auth.OIDC.Providers[i].ClientSecret = secret
+		}
+	}
+
 	if err := cfg.Validate(); err != nil {
 		log.Fatalf("Configuration validation error: %s", err)
 	}
@@ -104,14 +292,168 @@ func LoadConfig() *Config {
 }
 
 func (c *Config) Validate() error {
-	if c.Auth.JWTSecret == "" {
-		return errors.New("auth.jwt_secret is not set")
+	if err := c.Auth.validateSigning(); err != nil {
+		return err
+	}
+	if c.Auth.AccessTokenTTL <= 0 {
+		return errors.New("auth.access_token_ttl must be a positive duration")
 	}
-	if c.Auth.TokenTTL <= 0 {
-		return errors.New("auth.token_ttl must be a positive duration")
+	if c.Auth.RefreshTokenTTL <= c.Auth.AccessTokenTTL {
+		return errors.New("auth.refresh_token_ttl must be longer than auth.access_token_ttl")
+	}
+	if c.Auth.PasswordResetTTL <= 0 {
+		return errors.New("auth.password_reset_ttl must be a positive duration")
+	}
+	if c.Auth.EmailVerificationTTL <= 0 {
+		return errors.New("auth.email_verification_ttl must be a positive duration")
+	}
+	if c.Auth.TOTPIssuer == "" {
+		return errors.New("auth.totp_issuer is not set")
+	}
+	if c.Auth.TwoFactorChallengeTTL <= 0 {
+		return errors.New("auth.two_factor_challenge_ttl must be a positive duration")
+	}
+	lp := c.Auth.LoginProtection
+	if lp.MaxAttempts <= 0 || lp.IPMaxAttempts <= 0 {
+		return errors.New("auth.login_protection.max_attempts and ip_max_attempts must be positive")
+	}
+	if lp.BaseDelay <= 0 || lp.MaxDelay < lp.BaseDelay {
+		return errors.New("auth.login_protection.base_delay must be positive and not greater than max_delay")
+	}
+	if lp.Window <= 0 {
+		return errors.New("auth.login_protection.window must be a positive duration")
+	}
+	if err := c.Auth.OIDC.validate(); err != nil {
+		return err
+	}
+	ph := c.Auth.PasswordHashing
+	if err := (hash.Params{
+		Memory: ph.Memory, Iterations: ph.Iterations, Parallelism: ph.Parallelism,
+		SaltLength: ph.SaltLength, KeyLength: ph.KeyLength,
+	}).Validate(); err != nil {
+		return fmt.Errorf("auth.password_hashing: %w", err)
+	}
+	pp := c.Auth.PasswordPolicy
+	if pp.MinLength < 8 || pp.MinLength > 256 {
+		return errors.New("auth.password_policy.min_length must be between 8 and 256")
+	}
+	if pp.BreachedPath != "" {
+		if info, err := os.Stat(pp.BreachedPath); err != nil || !info.IsDir() {
+			return fmt.Errorf("auth.password_policy.breached_path %q is not a directory", pp.BreachedPath)
+		}
 	}
 	if c.HTTPServer.Port == "" {
 		return errors.New("http_server.port is not set")
 	}
+	if c.Suggest.RateLimit <= 0 {
+		return errors.New("suggest.rate_limit must be positive")
+	}
+	if c.Suggest.RateWindow <= 0 {
+		return errors.New("suggest.rate_window must be a positive duration")
+	}
+	if c.Mail.From == "" {
+		return errors.New("mail.from is not set")
+	}
+	if c.Mail.PublicURL == "" {
+		return errors.New("mail.public_url is not set")
+	}
+	switch c.Mail.Driver {
+	case "smtp":
+		if c.Mail.SMTP.Host == "" || c.Mail.SMTP.Port == "" {
+			return errors.New("mail.smtp.host and mail.smtp.port must be set for smtp driver")
+		}
+	case "log":
+		if c.Mail.LogPath == "" {
+			return errors.New("mail.log_path must be set for log driver")
+		}
+	default:
+		return fmt.Errorf("mail.driver must be smtp or log, got %q", c.Mail.Driver)
+	}
+	if c.Scheduler.PublishInterval <= 0 {
+		return errors.New("scheduler.publish_interval must be a positive duration")
+	}
+	screeningActions := map[string]string{
+		"banned_words": c.Screening.BannedWords.Action,
+		"contacts":     c.Screening.Contacts.Action,
+		"caps":         c.Screening.Caps.Action,
+		"duplicates":   c.Screening.Duplicates.Action,
+	}
+	for rule, action := range screeningActions {
+		switch action {
+		case "", "reject", "premoderate", "off":
+		default:
+			return fmt.Errorf("screening.%s.action must be reject, premoderate or off, got %q", rule, action)
+		}
+	}
+	if a := c.Screening.Duplicates.Action; a != "" && a != "off" && c.Screening.Duplicates.Window <= 0 {
+		return errors.New("screening.duplicates.window must be a positive duration")
+	}
+	if s := c.Screening.Duplicates.Similarity; s < 0 || s > 1 {
+		return fmt.Errorf("screening.duplicates.similarity must be between 0 and 1, got %v", s)
+	}
+	if t := c.Screening.Duplicates.PriceTolerance; t < 0 || t >= 1 {
+		return fmt.Errorf("screening.duplicates.price_tolerance must be at least 0 and less than 1, got %v", t)
+	}
+	return nil
+}
+
+// validateSigning проверяет настройки подписи токенов. Сами ключи читаются
+// и разбираются при создании менеджера токенов.
+func (a Auth) validateSigning() error {
+	if len(a.SigningKeys) == 0 {
+		if a.JWTSecret == "" {
+			return errors.New("auth.jwt_secret is not set")
+		}
+		return nil
+	}
+
+	seen := make(map[string]bool, len(a.SigningKeys))
+	for _, k := range a.SigningKeys {
+		if k.ID == "" {
+			return errors.New("auth.signing_keys: kid is not set")
+		}
+		if seen[k.ID] {
+			return fmt.Errorf("auth.signing_keys: duplicate kid %q", k.ID)
+		}
+		seen[k.ID] = true
+		if k.PrivateKey == "" && k.PrivateKeyPath == "" && k.PublicKey == "" && k.PublicKeyPath == "" {
+			return fmt.Errorf("auth.signing_keys: key %q has no private or public key", k.ID)
+		}
+		if k.RetiredAt != "" {
+			if _, err := time.Parse(time.RFC3339, k.RetiredAt); err != nil {
+				return fmt.Errorf("auth.signing_keys: key %q: retired_at must be RFC 3339: %w", k.ID, err)
+			}
+		}
+	}
+	if !seen[a.ActiveKeyID] {
+		return fmt.Errorf("auth.active_kid %q does not match any of auth.signing_keys", a.ActiveKeyID)
+	}
+	return nil
+}
+
+// validate проверяет настройки провайдеров OpenID Connect. Имя провайдера
+// входит в URL эндпоинтов входа, поэтому допускаются только строчные латинские
+// буквы, цифры и дефис.
+func (o OIDC) validate() error {
+	if len(o.Providers) == 0 {
+		return nil
+	}
+	if o.StateTTL <= 0 {
+		return errors.New("auth.oidc.state_ttl must be a positive duration")
+	}
+
+	seen := make(map[string]bool, len(o.Providers))
+	for _, p := range o.Providers {
+		if p.Name == "" || strings.Trim(p.Name, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return fmt.Errorf("auth.oidc.providers: invalid name %q", p.Name)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("auth.oidc.providers: duplicate name %q", p.Name)
+		}
+		seen[p.Name] = true
+		if p.Issuer == "" || p.ClientID == "" || p.RedirectURL == "" {
+			return fmt.Errorf("auth.oidc.providers: %q must have issuer, client_id and redirect_url", p.Name)
+		}
+	}
 	return nil
 }
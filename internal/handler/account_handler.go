@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"marketplace/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Выгрузка персональных данных
+// @Security ApiKeyAuth
+// @Tags users
+// @Description Возвращает все данные, которые сервис хранит о пользователе: профиль, объявления, жалобы,
+// @Description уведомления, API-ключи, привязки к провайдерам, историю входов и события безопасности.
+// @Description С format=zip данные отдаются архивом с отдельным JSON-файлом на каждый раздел.
+// @Produce  json
+// @Produce  application/zip
+// @Param format query string false "Формат выгрузки" Enums(json, zip) default(json)
+// @Success 200 {object} models.AccountExport "Данные пользователя"
+// @Failure 400 {object} ErrorResponse "Неизвестный формат"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 404 {object} ErrorResponse "Пользователь не найден"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /me/export [get]
+func (h *Handler) exportAccount(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		h.newErrorResponse(c, http.StatusBadRequest, "format must be json or zip", fmt.Errorf("unknown export format %q", format))
+		return
+	}
+
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	export, err := h.service.Account.Export(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, postgres.ErrUserNotFound) {
+			h.newErrorResponse(c, http.StatusNotFound, "user not found", err)
+		} else {
+			h.newErrorResponse(c, http.StatusInternalServerError, "failed to export account", err)
+		}
+		return
+	}
+
+	filename := fmt.Sprintf("marketplace-export-%d-%s", userID, export.ExportedAt.Format("20060102"))
+	c.Header("Cache-Control", "no-store")
+	if format == "json" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, filename))
+	c.Status(http.StatusOK)
+	c.Writer.Header().Set("Content-Type", "application/zip")
+	if err := writeExportZip(c.Writer, export); err != nil {
+		// Заголовки уже отправлены, клиент получит оборванный архив.
+		h.log.Error("failed to write export archive", slog.String("error", err.Error()))
+	}
+}
+
+// writeExportZip пишет выгрузку архивом: по JSON-файлу на каждый раздел.
+func writeExportZip(w io.Writer, export *models.AccountExport) error {
+	sections := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"ads.json", export.Ads},
+		{"reports.json", export.Reports},
+		{"notifications.json", export.Notifications},
+		{"api_keys.json", export.APIKeys},
+		{"identities.json", export.Identities},
+		{"login_history.json", export.LoginHistory},
+		{"security_events.json", export.SecurityEvents},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, section := range sections {
+		f, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     section.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(section.data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// @Summary Удаление учетной записи
+// @Security ApiKeyAuth
+// @Tags users
+// @Description Удаляет пользователя после повторного ввода пароля. Объявления, токены, ключи, уведомления
+// @Description и жалобы удаляются вместе с ним, все сессии завершаются. В журнале безопасности остаются
+// @Description только обезличенные записи. Пользователь, вошедший через провайдера и не задавший пароль,
+// @Description передает пустой пароль и подтверждает удаление повторным входом через провайдера: токен запроса
+// @Description должен принадлежать сессии, открытой не более 10 минут назад.
+// @Accept  json
+// @Param   input body models.DeleteAccountRequest true "Текущий пароль"
+// @Success 204 "Учетная запись удалена"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 403 {object} ErrorResponse "Неверный пароль или требуется повторный вход"
+// @Failure 404 {object} ErrorResponse "Пользователь не найден"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /me [delete]
+func (h *Handler) deleteAccount(c *gin.Context) {
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+		return
+	}
+
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	var sessionID string
+	if claims, ok := GetClaimsFromCtx(c); ok {
+		sessionID = claims.SessionID
+	}
+
+	if err := h.service.Account.Delete(c.Request.Context(), userID, sessionID, req.Password); err != nil {
+		switch {
+		case errors.Is(err, service.ErrWrongPassword):
+			h.newErrorResponse(c, http.StatusForbidden, "password is incorrect", err)
+		case errors.Is(err, service.ErrReauthRequired):
+			h.newErrorResponse(c, http.StatusForbidden, "recent sign-in required", err)
+		case errors.Is(err, postgres.ErrUserNotFound):
+			h.newErrorResponse(c, http.StatusNotFound, "user not found", err)
+		default:
+			h.newErrorResponse(c, http.StatusInternalServerError, "failed to delete account", err)
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -3,24 +3,35 @@ package handler
 import (
 	"errors"
 	"fmt"
+	"io"
 	"marketplace/internal/models"
 	"marketplace/internal/repository/postgres"
+	"marketplace/internal/service"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 // @Summary Создание нового объявления
 // @Security ApiKeyAuth
+// @Security MachineKeyAuth
 // @Tags ads
 // @Description Создает новое объявление от имени авторизованного пользователя
+// @Description Объявление проходит автоматическую проверку: при нарушении правил оно отклоняется
+// @Description с ошибками по полям либо создается скрытым до проверки модератором (pending_review: true).
+// @Description С publish_at объявление публикуется в указанное время, до этого его видят только владелец и модераторы.
 // @Accept  json
 // @Produce  json
 // @Param   input body models.CreateAdRequest true "Данные для создания объявления"
 // @Success 201 {object} models.CreateAdResponse "ID созданного объявления" // <--- ИЗМЕНЕНО
-// @Failure 400 {object} ErrorResponse "Неверный формат запроса"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса или время публикации в прошлом"
 // @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 403 {object} ErrorResponse "Почта не подтверждена"
+// @Failure 409 {object} ErrorResponse "Похожее объявление уже опубликовано недавно"
+// @Failure 422 {object} ErrorResponse "Объявление не прошло автоматическую проверку или нарушает ограничения полей"
 // @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
 // @Router /ads [post]
 func (h *Handler) CreateAd(c *gin.Context) {
@@ -42,25 +53,47 @@ func (h *Handler) CreateAd(c *gin.Context) {
 		Description: req.Description,
 		Price:       req.Price,
 		ImageURL:    req.ImageURL,
+		Tags:        req.Tags,
+		PublishAt:   req.PublishAt,
 	}
 
 	adID, err := h.service.Ad.CreateAd(c.Request.Context(), ad)
 	if err != nil {
+		if errors.Is(err, service.ErrTooManyTags) || errors.Is(err, service.ErrInvalidTag) ||
+			errors.Is(err, service.ErrPublishAtInPast) {
+			h.newErrorResponse(c, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		if errors.Is(err, service.ErrDuplicateAd) {
+			h.newErrorResponse(c, http.StatusConflict, err.Error(), err)
+			return
+		}
+		var rejected *service.ContentRejectedError
+		if errors.As(err, &rejected) {
+			h.newFieldErrorResponse(c, http.StatusUnprocessableEntity, "ad content rejected", rejected.Fields(), err)
+			return
+		}
+		if h.newConstraintErrorResponse(c, err) {
+			return
+		}
 		h.newErrorResponse(c, http.StatusInternalServerError, "failed to create ad", err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.CreateAdResponse{ID: adID})
+	c.JSON(http.StatusCreated, models.CreateAdResponse{ID: adID, PendingReview: ad.HiddenAt != nil})
 }
 
 // @Summary Получение списка объявлений
 // @Tags ads
-// @Description Возвращает список объявлений с возможностью пагинации и сортировки
+// @Description Возвращает список объявлений с возможностью пагинации и сортировки.
+// @Description На первой странице над результатами закрепляются продвигаемые объявления (promoted: true).
 // @Produce  json
 // @Param page query int false "Номер страницы" default(1)
 // @Param limit query int false "Количество элементов на странице" default(10)
 // @Param sort_by query string false "Поле для сортировки" Enums(created_at, price) default(created_at)
 // @Param sort_order query string false "Порядок сортировки" Enums(asc, desc) default(desc)
+// @Param q query string false "Поиск по заголовку"
+// @Param tag query string false "Фильтр по тегу"
 // @Success 200 {array} models.AdResponse "Список объявлений"
 // @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
 // @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
@@ -72,40 +105,31 @@ func (h *Handler) GetAllAds(c *gin.Context) {
 		return
 	}
 
-	offset := (query.Page - 1) * query.Limit
-
-	params := postgres.GetAllAdsParams{
-		Limit:     query.Limit,
-		Offset:    offset,
-		SortBy:    query.SortBy,
-		SortOrder: query.SortOrder,
-	}
-
-	ads, err := h.service.Ad.GetAllAds(c.Request.Context(), params)
+	ads, err := h.service.Ad.GetAllAds(clientContext(c), listParams(query))
 	if err != nil {
 		h.newErrorResponse(c, http.StatusInternalServerError, "failed to get ads", err)
 		return
 	}
 
-	var responses []models.AdResponse
-	for _, ad := range ads {
-		responses = append(responses, models.AdResponse{
-			ID:          ad.ID,
-			Title:       ad.Title,
-			Description: ad.Description,
-			Price:       ad.Price,
-			ImageURL:    ad.ImageURL,
-			AuthorID:    ad.UserID,
-			CreatedAt:   ad.CreatedAt,
-		})
-	}
-
-	c.JSON(http.StatusOK, responses)
+	c.JSON(http.StatusOK, toAdResponses(ads))
+}
+
+// listParams переводит параметры запроса списка объявлений в параметры выборки.
+func listParams(query models.AdsQuery) postgres.GetAllAdsParams {
+	return postgres.GetAllAdsParams{
+		Limit:     query.Limit,
+		Offset:    (query.Page - 1) * query.Limit,
+		SortBy:    query.SortBy,
+		SortOrder: query.SortOrder,
+		Search:    strings.TrimSpace(query.Search),
+		Tag:       query.Tag,
+	}
 }
 
 // @Summary Получение объявления по ID
 // @Tags ads
-// @Description Возвращает одно объявление по его уникальному идентификатору
+// @Description Возвращает одно объявление по его уникальному идентификатору.
+// @Description Скрытые модератором объявления доступны только владельцу и модераторам.
 // @Produce  json
 // @Param id path int true "ID объявления"
 // @Success 200 {object} models.Ad "Полные данные объявления"
@@ -120,7 +144,7 @@ func (h *Handler) GetAdByID(c *gin.Context) {
 		return
 	}
 
-	ad, err := h.service.Ad.GetAdByID(c.Request.Context(), id)
+	ad, err := h.service.Ad.GetAdByID(c.Request.Context(), id, GetActorFromCtx(c))
 	if err != nil {
 		if errors.Is(err, postgres.ErrAdNotFound) {
 			h.newErrorResponse(c, http.StatusNotFound, "ad not found", err)
@@ -135,17 +159,28 @@ func (h *Handler) GetAdByID(c *gin.Context) {
 
 // @Summary Обновление объявления
 // @Security ApiKeyAuth
+// @Security MachineKeyAuth
 // @Tags ads
-// @Description Обновляет данные объявления (только владелец)
+// @Description Обновляет данные объявления (владелец или модератор).
+// @Description application/json - частичное обновление переданных полей (models.UpdateAdRequest).
+// @Description application/merge-patch+json - JSON Merge Patch (RFC 7396), null удаляет значение поля.
+// @Description application/json-patch+json - JSON Patch (RFC 6902) над полями title, description, price, image_url, tags.
+// @Description Итоговое объявление проверяется по тем же правилам, что и при создании.
+// @Description publish_at можно перенести или сбросить (опубликовать сразу), пока объявление не опубликовано.
 // @Accept  json
+// @Accept  application/merge-patch+json
+// @Accept  application/json-patch+json
 // @Produce  json
 // @Param id path int true "ID объявления"
-// @Param input body models.UpdateAdRequest true "Поля для обновления"
-// @Success 200 {object} models.Ad "Обновленные данные объявления"
-// @Failure 400 {object} ErrorResponse "Неверный формат запроса или ID"
+// @Param input body models.UpdateAdRequest true "Поля для обновления или патч"
+// @Success 200 {object} models.AdResponse "Обновленные данные объявления"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса, ID или патча"
 // @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
-// @Failure 403 {object} ErrorResponse "Доступ запрещен (не владелец)"
+// @Failure 403 {object} ErrorResponse "Доступ запрещен (не владелец и не модератор)"
 // @Failure 404 {object} ErrorResponse "Объявление не найдено"
+// @Failure 409 {object} ErrorResponse "Не выполнена операция test из JSON Patch, объявление уже опубликовано или похожее объявление опубликовано недавно"
+// @Failure 415 {object} ErrorResponse "Неподдерживаемый тип содержимого"
+// @Failure 422 {object} ErrorResponse "Объявление не проходит проверку или нарушает ограничения полей"
 // @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
 // @Router /ads/{id} [patch]
 func (h *Handler) UpdateAd(c *gin.Context) {
@@ -155,35 +190,76 @@ func (h *Handler) UpdateAd(c *gin.Context) {
 		return
 	}
 
-	var req models.UpdateAdRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
-		return
-	}
-
-	userID, ok := GetUserIDFromCtx(c)
-	if !ok {
+	actor := GetActorFromCtx(c)
+	if actor.UserID == 0 {
 		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
 		return
 	}
 
-	updatedAd, err := h.service.Ad.UpdateAd(c.Request.Context(), id, userID, req)
-	if err != nil {
-		if errors.Is(err, postgres.ErrAdNotFound) {
-			h.newErrorResponse(c, http.StatusNotFound, "ad not found", err)
-		} else if errors.Is(err, postgres.ErrAdAccessDenied) {
-			h.newErrorResponse(c, http.StatusForbidden, "access denied", err)
-		} else {
-			h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+	var updatedAd *models.Ad
+	switch contentType := c.ContentType(); contentType {
+	case models.MergePatchContentType, models.JSONPatchContentType:
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+			return
+		}
+		patch := models.AdPatch{ContentType: contentType, Body: body}
+		updatedAd, err = h.service.Ad.PatchAd(c.Request.Context(), id, actor, patch)
+		if err != nil {
+			h.handleUpdateAdError(c, err)
+			return
+		}
+	case "", binding.MIMEJSON:
+		var req models.UpdateAdRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+			return
+		}
+		updatedAd, err = h.service.Ad.UpdateAd(c.Request.Context(), id, actor, req)
+		if err != nil {
+			h.handleUpdateAdError(c, err)
+			return
 		}
+	default:
+		h.newErrorResponse(c, http.StatusUnsupportedMediaType, "unsupported content type", fmt.Errorf("content type %q", contentType))
 		return
 	}
 
 	c.JSON(http.StatusOK, toAdResponse(updatedAd))
 }
 
+func (h *Handler) handleUpdateAdError(c *gin.Context, err error) {
+	var invalid *service.InvalidAdError
+	var rejected *service.ContentRejectedError
+	switch {
+	case errors.Is(err, postgres.ErrAdNotFound):
+		h.newErrorResponse(c, http.StatusNotFound, "ad not found", err)
+	case errors.Is(err, postgres.ErrAdAccessDenied):
+		h.newErrorResponse(c, http.StatusForbidden, "access denied", err)
+	case errors.Is(err, service.ErrTooManyTags) || errors.Is(err, service.ErrInvalidTag) ||
+		errors.Is(err, service.ErrPublishAtInPast):
+		h.newErrorResponse(c, http.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, service.ErrAdAlreadyPublished) || errors.Is(err, service.ErrDuplicateAd):
+		h.newErrorResponse(c, http.StatusConflict, err.Error(), err)
+	case errors.Is(err, service.ErrInvalidPatch):
+		h.newErrorResponse(c, http.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, service.ErrPatchTestFailed):
+		h.newErrorResponse(c, http.StatusConflict, err.Error(), err)
+	case errors.As(err, &invalid):
+		h.newFieldErrorResponse(c, http.StatusUnprocessableEntity, "invalid ad", invalid.Fields, err)
+	case errors.As(err, &rejected):
+		h.newFieldErrorResponse(c, http.StatusUnprocessableEntity, "ad content rejected", rejected.Fields(), err)
+	default:
+		if !h.newConstraintErrorResponse(c, err) {
+			h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		}
+	}
+}
+
 // @Summary Удаление объявления
 // @Security ApiKeyAuth
+// @Security MachineKeyAuth
 // @Tags ads
 // @Description Удаляет объявление (только владелец)
 // @Param id path int true "ID объявления для удаления"
@@ -191,6 +267,7 @@ func (h *Handler) UpdateAd(c *gin.Context) {
 // @Failure 400 {object} ErrorResponse "Неверный ID объявления"
 // @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
 // @Failure 403 {object} ErrorResponse "Доступ запрещен (не владелец)"
+// @Failure 404 {object} ErrorResponse "Объявление не найдено"
 // @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
 // @Router /ads/{id} [delete]
 func (h *Handler) DeleteAd(c *gin.Context) {
@@ -208,7 +285,9 @@ func (h *Handler) DeleteAd(c *gin.Context) {
 
 	err = h.service.Ad.DeleteAd(c.Request.Context(), id, userID)
 	if err != nil {
-		if errors.Is(err, postgres.ErrAdAccessDenied) {
+		if errors.Is(err, postgres.ErrAdNotFound) {
+			h.newErrorResponse(c, http.StatusNotFound, "ad not found", err)
+		} else if errors.Is(err, postgres.ErrAdAccessDenied) {
 			h.newErrorResponse(c, http.StatusForbidden, "access denied", err)
 		} else {
 			h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
@@ -219,6 +298,41 @@ func (h *Handler) DeleteAd(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// @Summary Подсказки для строки поиска
+// @Tags ads
+// @Description Возвращает варианты заголовков и популярные запросы, начинающиеся с введенного префикса
+// @Produce  json
+// @Param prefix query string true "Начало поискового запроса"
+// @Param limit query int false "Максимальное количество подсказок каждого вида" default(5)
+// @Success 200 {object} models.SuggestResponse "Подсказки"
+// @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
+// @Failure 429 {object} ErrorResponse "Слишком много запросов"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /ads/suggest [get]
+func (h *Handler) SuggestAds(c *gin.Context) {
+	var query models.SuggestQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid query parameters", err)
+		return
+	}
+
+	suggestions, err := h.service.Ad.Suggest(c.Request.Context(), query.Prefix, query.Limit)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusInternalServerError, "failed to get suggestions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestions)
+}
+
+func toAdResponses(ads []models.Ad) []models.AdResponse {
+	var responses []models.AdResponse
+	for _, ad := range ads {
+		responses = append(responses, toAdResponse(&ad))
+	}
+	return responses
+}
+
 func toAdResponse(ad *models.Ad) models.AdResponse {
 	return models.AdResponse{
 		ID:          ad.ID,
@@ -226,7 +340,47 @@ func toAdResponse(ad *models.Ad) models.AdResponse {
 		Description: ad.Description,
 		Price:       ad.Price,
 		ImageURL:    ad.ImageURL,
+		Tags:        ad.Tags,
+		Promoted:    ad.Promoted,
+		Hidden:      ad.HiddenAt != nil,
+		PublishAt:   ad.PublishAt,
 		AuthorID:    ad.UserID,
 		CreatedAt:   ad.CreatedAt,
 	}
 }
+
+// @Summary Скрытие объявления
+// @Security ApiKeyAuth
+// @Tags ads
+// @Description Снимает любое объявление с публикации без жалобы (модераторы и администраторы).
+// @Description Принимает только access-токен, API-ключи не принимаются.
+// @Param id path int true "ID объявления"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse "Неверный ID объявления"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} ErrorResponse "Объявление не найдено"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /ads/{id}/hide [post]
+func (h *Handler) HideAd(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid ad ID", err)
+		return
+	}
+
+	err = h.service.Ad.HideAd(c.Request.Context(), id, GetActorFromCtx(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrAdNotFound):
+			h.newErrorResponse(c, http.StatusNotFound, "ad not found", err)
+		case errors.Is(err, postgres.ErrAdAccessDenied):
+			h.newErrorResponse(c, http.StatusForbidden, "insufficient permissions", err)
+		default:
+			h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
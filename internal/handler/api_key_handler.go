@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Создание API-ключа
+// @Security ApiKeyAuth
+// @Tags api-keys
+// @Description Выпускает именованный ключ для скриптов и интеграций с областями доступа ads:read и ads:write.
+// @Description Ключ передается в заголовке X-API-Key и показывается только в этом ответе.
+// @Accept  json
+// @Produce  json
+// @Param   input body models.CreateAPIKeyRequest true "Название и области ключа"
+// @Success 201 {object} models.CreateAPIKeyResponse "Созданный ключ"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /me/api-keys [post]
+func (h *Handler) createAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+		return
+	}
+
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	key, raw, err := h.service.APIKey.Create(c.Request.Context(), userID, req.Name, req.Scopes)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateAPIKeyResponse{APIKey: *key, Key: raw})
+}
+
+// @Summary Список API-ключей
+// @Security ApiKeyAuth
+// @Tags api-keys
+// @Description Возвращает действующие ключи пользователя с префиксом и временем последнего использования.
+// @Produce  json
+// @Success 200 {array} models.APIKey "Ключи пользователя"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /me/api-keys [get]
+func (h *Handler) listAPIKeys(c *gin.Context) {
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	keys, err := h.service.APIKey.List(c.Request.Context(), userID)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// @Summary Отзыв API-ключа
+// @Security ApiKeyAuth
+// @Tags api-keys
+// @Description Отзывает ключ пользователя; запросы с ним сразу перестают приниматься.
+// @Param id path int true "ID ключа"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse "Неверный ID ключа"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 404 {object} ErrorResponse "Ключ не найден или уже отозван"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /me/api-keys/{id} [delete]
+func (h *Handler) revokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid api key ID", err)
+		return
+	}
+
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	if err := h.service.APIKey.Revoke(c.Request.Context(), userID, id); err != nil {
+		if errors.Is(err, postgres.ErrAPIKeyNotFound) {
+			h.newErrorResponse(c, http.StatusNotFound, "api key not found", err)
+			return
+		}
+		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
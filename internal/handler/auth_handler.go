@@ -3,22 +3,26 @@ package handler
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"marketplace/internal/models"
 	"marketplace/internal/service"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 // @Summary Регистрация нового пользователя
 // @Tags auth
-// @Description Создает нового пользователя в системе
+// @Description Создает нового пользователя в системе и отправляет на почту ссылку для ее подтверждения
 // @Accept  json
 // @Produce  json
 // @Param   input body models.RegisterRequest true "Данные для регистрации"
 // @Success 201 {object} models.UserResponse "Пользователь успешно создан"
 // @Failure 400 {object} ErrorResponse "Неверный формат запроса"
-// @Failure 409 {object} ErrorResponse "Пользователь уже существует"
+// @Failure 409 {object} ErrorResponse "Пользователь или почта уже существуют"
+// @Failure 422 {object} ErrorResponse "Пароль не соответствует требованиям (причины - в reasons) или данные нарушают ограничения"
 // @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
 // @Router /auth/register [post]
 func (h *Handler) signUp(c *gin.Context) {
@@ -28,16 +32,36 @@ func (h *Handler) signUp(c *gin.Context) {
 		return
 	}
 
-	user, err := h.service.Auth.Register(c.Request.Context(), req.Username, req.Password)
+	user, err := h.service.Auth.Register(c.Request.Context(), req.Username, req.Password, req.Email)
 	if err != nil {
 		if errors.Is(err, service.ErrUserExists) {
 			h.newErrorResponse(c, http.StatusConflict, "user already exists", err)
 			return
 		}
+		if errors.Is(err, service.ErrEmailTaken) {
+			h.newErrorResponse(c, http.StatusConflict, "email already in use", err)
+			return
+		}
+		var weak *service.WeakPasswordError
+		if errors.As(err, &weak) {
+			h.newWeakPasswordResponse(c, "password", weak)
+			return
+		}
+		if h.newConstraintErrorResponse(c, err) {
+			return
+		}
 		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", fmt.Errorf("failed to register user: %w", err))
 		return
 	}
 
+	// Пользователь уже создан: если письмо не ушло, ссылку можно запросить повторно.
+	if err := h.service.Verification.SendVerification(c.Request.Context(), user); err != nil {
+		h.log.Warn("failed to send verification email",
+			slog.Int64("user_id", user.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+
 	c.JSON(http.StatusCreated, models.UserResponse{
 		ID:        user.ID,
 		Username:  user.Username,
@@ -47,13 +71,18 @@ func (h *Handler) signUp(c *gin.Context) {
 
 // @Summary Авторизация пользователя
 // @Tags auth
-// @Description Авторизует пользователя и возвращает JWT токен
+// @Description Авторизует пользователя и возвращает короткоживущий JWT токен и refresh-токен.
+// @Description Если включена двухфакторная аутентификация, возвращает challenge-токен для POST /auth/login/2fa.
 // @Accept  json
 // @Produce  json
 // @Param   input body models.LoginRequest true "Данные для входа"
 // @Success 200 {object} models.LoginResponse "Успешная авторизация"
+// @Success 202 {object} models.TwoFactorChallengeResponse "Требуется код второго фактора"
 // @Failure 400 {object} ErrorResponse "Неверный формат запроса"
 // @Failure 401 {object} ErrorResponse "Неверные учетные данные"
+// @Failure 403 {object} ErrorResponse "Пользователь заблокирован"
+// @Failure 429 {object} ErrorResponse "Слишком много неудачных попыток, вход временно заблокирован"
+// @Header  429 {integer} Retry-After "Через сколько секунд можно повторить вход"
 // @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
 // @Router /auth/login [post]
 func (h *Handler) signIn(c *gin.Context) {
@@ -63,15 +92,353 @@ func (h *Handler) signIn(c *gin.Context) {
 		return
 	}
 
-	token, err := h.service.Auth.Login(c.Request.Context(), req.Username, req.Password)
+	result, err := h.service.Auth.Login(clientContext(c), req.Username, req.Password, c.ClientIP())
 	if err != nil {
+		var locked *service.LoginLockedError
+		if errors.As(err, &locked) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			h.newErrorResponse(c, http.StatusTooManyRequests, "too many failed login attempts", err)
+			return
+		}
 		if errors.Is(err, service.ErrInvalidCredentials) {
 			h.newErrorResponse(c, http.StatusUnauthorized, "invalid credentials", err)
 			return
 		}
+		if errors.Is(err, service.ErrUserBanned) {
+			h.newErrorResponse(c, http.StatusForbidden, "user is banned", err)
+			return
+		}
 		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, models.LoginResponse{Token: token})
+	if result.Challenge != nil {
+		c.JSON(http.StatusAccepted, models.TwoFactorChallengeResponse{
+			ChallengeToken: result.Challenge.Token,
+			ExpiresIn:      int64(result.Challenge.ExpiresIn.Seconds()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, toLoginResponse(result.Tokens))
+}
+
+// @Summary Второй шаг входа
+// @Tags auth
+// @Description Обменивает challenge-токен и код из приложения-аутентификатора (или резервный код) на пару токенов.
+// @Description После нескольких неверных кодов challenge-токен перестает действовать, а неверные коды
+// @Description учитываются вместе с неверными паролями и могут временно заблокировать вход.
+// @Description Уже принятый код из приложения повторно не принимается.
+// @Accept  json
+// @Produce  json
+// @Param   input body models.TwoFactorLoginRequest true "Challenge-токен и код"
+// @Success 200 {object} models.LoginResponse "Успешная авторизация"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса"
+// @Failure 401 {object} ErrorResponse "Challenge-токен недействителен или неверный код"
+// @Failure 403 {object} ErrorResponse "Пользователь заблокирован"
+// @Failure 429 {object} ErrorResponse "Слишком много неудачных попыток, вход временно заблокирован"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/login/2fa [post]
+func (h *Handler) signInTwoFactor(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+		return
+	}
+
+	tokens, err := h.service.Auth.LoginTwoFactor(clientContext(c), req.ChallengeToken, req.Code)
+	if err != nil {
+		var locked *service.LoginLockedError
+		switch {
+		case errors.As(err, &locked):
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			h.newErrorResponse(c, http.StatusTooManyRequests, "too many failed login attempts", err)
+		case errors.Is(err, service.ErrInvalidChallenge):
+			h.newErrorResponse(c, http.StatusUnauthorized, "invalid or expired challenge, please log in again", err)
+		case errors.Is(err, service.ErrInvalidTwoFactorCode):
+			h.newErrorResponse(c, http.StatusUnauthorized, "invalid two-factor code", err)
+		case errors.Is(err, service.ErrUserBanned):
+			h.newErrorResponse(c, http.StatusForbidden, "user is banned", err)
+		default:
+			h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, toLoginResponse(tokens))
+}
+
+// @Summary Обновление токенов
+// @Tags auth
+// @Description Обменивает refresh-токен на новую пару токенов. Refresh-токен действует один раз;
+// @Description повторное использование отзывает все токены, полученные от того же входа.
+// @Accept  json
+// @Produce  json
+// @Param   input body models.RefreshRequest true "Refresh-токен"
+// @Success 200 {object} models.LoginResponse "Новая пара токенов"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса"
+// @Failure 401 {object} ErrorResponse "Токен недействителен или уже использован"
+// @Failure 403 {object} ErrorResponse "Пользователь заблокирован"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/refresh [post]
+func (h *Handler) refreshTokens(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+		return
+	}
+
+	tokens, err := h.service.Auth.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRefreshToken):
+			h.newErrorResponse(c, http.StatusUnauthorized, "invalid refresh token", err)
+		case errors.Is(err, service.ErrRefreshTokenReused):
+			h.newErrorResponse(c, http.StatusUnauthorized, "refresh token reuse detected, please log in again", err)
+		case errors.Is(err, service.ErrUserBanned):
+			h.newErrorResponse(c, http.StatusForbidden, "user is banned", err)
+		default:
+			h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, toLoginResponse(tokens))
+}
+
+// @Summary Выход из системы
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description Отзывает текущий access-токен до истечения его срока и завершает его сессию вместе с ее refresh-токенами.
+// @Description Переданный refresh-токен отзывается вместе со своим семейством.
+// @Accept  json
+// @Param   input body models.LogoutRequest false "Refresh-токен текущей сессии"
+// @Success 204 "Токены отозваны"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/logout [post]
+func (h *Handler) logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+			return
+		}
+	}
+
+	claims, ok := GetClaimsFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("token claims not found"))
+		return
+	}
+
+	if err := h.service.Auth.Logout(c.Request.Context(), claims, req.RefreshToken); err != nil {
+		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Выход на всех устройствах
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description Отзывает все выданные пользователю access- и refresh-токены
+// @Success 204 "Все сессии завершены"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/logout-all [post]
+func (h *Handler) logoutAll(c *gin.Context) {
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	if err := h.service.Auth.LogoutAll(c.Request.Context(), userID); err != nil {
+		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Смена пароля
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description Меняет пароль после проверки текущего. Все сессии пользователя завершаются, нужно войти заново.
+// @Accept  json
+// @Param   input body models.ChangePasswordRequest true "Текущий и новый пароль"
+// @Success 204 "Пароль изменен"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 403 {object} ErrorResponse "Неверный текущий пароль"
+// @Failure 422 {object} ErrorResponse "Новый пароль не соответствует требованиям; причины - в reasons"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /me/password [patch]
+func (h *Handler) changePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+		return
+	}
+
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	err := h.service.Password.ChangePassword(c.Request.Context(), userID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		if errors.Is(err, service.ErrWrongPassword) {
+			h.newErrorResponse(c, http.StatusForbidden, "current password is incorrect", err)
+			return
+		}
+		var weak *service.WeakPasswordError
+		if errors.As(err, &weak) {
+			h.newWeakPasswordResponse(c, "new_password", weak)
+			return
+		}
+		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Запрос сброса пароля
+// @Tags auth
+// @Description Отправляет на почту одноразовый код сброса пароля. Ответ одинаков
+// @Description для зарегистрированных и незарегистрированных адресов.
+// @Accept  json
+// @Param   input body models.PasswordResetRequest true "Почта пользователя"
+// @Success 202 "Если адрес зарегистрирован, письмо отправлено"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/password-reset/request [post]
+func (h *Handler) requestPasswordReset(c *gin.Context) {
+	var req models.PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+		return
+	}
+
+	if err := h.service.Password.RequestReset(c.Request.Context(), req.Email); err != nil {
+		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// @Summary Подтверждение сброса пароля
+// @Tags auth
+// @Description Устанавливает новый пароль по коду из письма. Код действует один раз;
+// @Description все сессии пользователя завершаются.
+// @Accept  json
+// @Param   input body models.PasswordResetConfirmRequest true "Код из письма и новый пароль"
+// @Success 204 "Пароль изменен"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса, код недействителен или истек"
+// @Failure 422 {object} ErrorResponse "Новый пароль не соответствует требованиям; причины - в reasons"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/password-reset/confirm [post]
+func (h *Handler) confirmPasswordReset(c *gin.Context) {
+	var req models.PasswordResetConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+		return
+	}
+
+	if err := h.service.Password.ConfirmReset(c.Request.Context(), req.Token, req.NewPassword); err != nil {
+		if errors.Is(err, service.ErrInvalidResetToken) {
+			h.newErrorResponse(c, http.StatusBadRequest, "invalid or expired reset token", err)
+			return
+		}
+		var weak *service.WeakPasswordError
+		if errors.As(err, &weak) {
+			h.newWeakPasswordResponse(c, "new_password", weak)
+			return
+		}
+		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Подтверждение почты
+// @Tags auth
+// @Description Подтверждает адрес электронной почты по ссылке из письма. Ссылка действует один раз.
+// @Produce  json
+// @Param   token query string true "Токен из письма"
+// @Success 200 {object} models.VerifyEmailResponse "Почта подтверждена"
+// @Failure 400 {object} ErrorResponse "Токен не передан, недействителен или истек"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/verify [get]
+func (h *Handler) verifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		h.newErrorResponse(c, http.StatusBadRequest, "token is required", fmt.Errorf("empty verification token"))
+		return
+	}
+
+	email, err := h.service.Verification.Verify(c.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidVerificationToken) {
+			h.newErrorResponse(c, http.StatusBadRequest, "invalid or expired verification token", err)
+			return
+		}
+		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.VerifyEmailResponse{Email: email, Verified: true})
+}
+
+// @Summary Повторная отправка ссылки подтверждения
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description Отправляет новую ссылку подтверждения почты; ранее отправленные ссылки перестают действовать
+// @Success 202 "Письмо отправлено"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 409 {object} ErrorResponse "Почта уже подтверждена или не указана"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/verify/resend [post]
+func (h *Handler) resendVerification(c *gin.Context) {
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	if err := h.service.Verification.Resend(c.Request.Context(), userID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrEmailAlreadyVerified):
+			h.newErrorResponse(c, http.StatusConflict, "email is already verified", err)
+		case errors.Is(err, service.ErrNoEmail):
+			h.newErrorResponse(c, http.StatusConflict, "user has no email", err)
+		default:
+			h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		}
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+func toLoginResponse(tokens *models.TokenPair) models.LoginResponse {
+	return models.LoginResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
+	}
+}
+
+// jwks отдает открытые ключи проверки access-токенов для других сервисов.
+// Набор кешируется ненадолго, чтобы новый ключ после ротации подхватывался быстро.
+func (h *Handler) jwks(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.TokenManager.JWKS())
 }
@@ -2,8 +2,10 @@ package handler
 
 import (
 	"log/slog"
+	"marketplace/internal/models"
 	"marketplace/internal/service"
 	"marketplace/pkg/auth"
+	"marketplace/pkg/ratelimit"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -13,16 +15,20 @@ import (
 )
 
 type Handler struct {
-	service      *service.Service
-	TokenManager *auth.TokenManager
-	log          *slog.Logger
+	service        *service.Service
+	TokenManager   *auth.TokenManager
+	suggestLimiter ratelimit.Limiter
+	log            *slog.Logger
 }
 
-func NewHandler(services *service.Service, tm *auth.TokenManager, log *slog.Logger) *Handler {
+// NewHandler создает обработчики HTTP-запросов. suggestLimiter может быть nil,
+// тогда эндпоинт подсказок работает без ограничения частоты запросов.
+func NewHandler(services *service.Service, tm *auth.TokenManager, suggestLimiter ratelimit.Limiter, log *slog.Logger) *Handler {
 	return &Handler{
-		service:      services,
-		TokenManager: tm,
-		log:          log,
+		service:        services,
+		TokenManager:   tm,
+		suggestLimiter: suggestLimiter,
+		log:            log,
 	}
 }
 
@@ -36,26 +42,92 @@ func (h *Handler) InitRoutes() *gin.Engine {
 		c.Redirect(http.StatusMovedPermanently, "/swagger/index.html")
 	})
 
+	// JWKS публикуется вне /api/v1 по стандартному адресу RFC 8615.
+	router.GET("/.well-known/jwks.json", h.jwks)
+
 	apiV1 := router.Group("/api/v1")
 	{
 		authGroup := apiV1.Group("/auth")
 		{
 			authGroup.POST("/register", h.signUp)
 			authGroup.POST("/login", h.signIn)
+			authGroup.POST("/login/2fa", h.signInTwoFactor)
+			authGroup.POST("/refresh", h.refreshTokens)
+			authGroup.POST("/logout", h.AuthMiddleware(), h.logout)
+			authGroup.POST("/logout-all", h.AuthMiddleware(), h.logoutAll)
+			authGroup.POST("/password-reset/request", h.requestPasswordReset)
+			authGroup.POST("/password-reset/confirm", h.confirmPasswordReset)
+			authGroup.GET("/verify", h.verifyEmail)
+			authGroup.POST("/verify/resend", h.AuthMiddleware(), h.resendVerification)
+			authGroup.GET("/oidc/:provider/login", h.beginExternalLogin)
+			authGroup.GET("/oidc/:provider/callback", h.completeExternalLogin)
+		}
+
+		meGroup := apiV1.Group("/me")
+		meGroup.Use(h.AuthMiddleware())
+		{
+			meGroup.GET("", h.getProfile)
+			meGroup.PATCH("", h.updateProfile)
+			meGroup.DELETE("", h.deleteAccount)
+			meGroup.GET("/export", h.exportAccount)
+			meGroup.PATCH("/password", h.changePassword)
+			meGroup.POST("/2fa/enroll", h.enrollTwoFactor)
+			meGroup.POST("/2fa/confirm", h.confirmTwoFactor)
+			meGroup.GET("/api-keys", h.listAPIKeys)
+			meGroup.POST("/api-keys", h.createAPIKey)
+			meGroup.DELETE("/api-keys/:id", h.revokeAPIKey)
+			meGroup.GET("/sessions", h.listSessions)
+			meGroup.DELETE("/sessions/:id", h.revokeSession)
+			meGroup.GET("/identities", h.getExternalIdentities)
+			meGroup.POST("/oidc/:provider/link", h.linkExternalIdentity)
 		}
 
 		adsGroup := apiV1.Group("/ads")
 		{
 			adsGroup.GET("", h.GetAllAds)
-			adsGroup.GET("/:id", h.GetAdByID)
+			adsGroup.GET("/suggest", h.RateLimitMiddleware(h.suggestLimiter), h.SuggestAds)
+			adsGroup.GET("/:id", h.OptionalAuthMiddleware(models.ScopeAdsRead), h.GetAdByID)
 
 			adsSecure := adsGroup.Group("")
-			adsSecure.Use(h.AuthMiddleware())
+			adsSecure.Use(h.AuthMiddleware(models.ScopeAdsWrite))
 			{
-				adsSecure.POST("", h.CreateAd)
+				adsSecure.POST("", h.RequireVerifiedEmail(), h.CreateAd)
 				adsSecure.PATCH("/:id", h.UpdateAd)
 				adsSecure.DELETE("/:id", h.DeleteAd)
+				adsSecure.POST("/:id/report", h.ReportAd)
 			}
+			// Модерация доступна только по access-токену, API-ключи здесь не принимаются.
+			adsGroup.POST("/:id/hide", h.AuthMiddleware(), h.RequirePermission(models.PermissionHideAnyAd), h.HideAd)
+		}
+
+		usersGroup := apiV1.Group("/users")
+		{
+			usersGroup.GET("/:id", h.GetUserProfile)
+			usersGroup.GET("/:id/ads", h.GetUserAds)
+		}
+
+		tagsGroup := apiV1.Group("/tags")
+		{
+			tagsGroup.GET("/popular", h.GetPopularTags)
+		}
+
+		moderationGroup := apiV1.Group("/moderation")
+		moderationGroup.Use(h.AuthMiddleware(), h.RequirePermission(models.PermissionModerateReports))
+		{
+			moderationGroup.GET("/reports", h.GetReports)
+			moderationGroup.POST("/reports/:id/claim", h.ClaimReport)
+			moderationGroup.POST("/reports/:id/resolve", h.ResolveReport)
+			moderationGroup.GET("/duplicates", h.GetDuplicateClusters)
+		}
+
+		apiV1.GET("/notifications", h.AuthMiddleware(), h.GetNotifications)
+
+		adminGroup := apiV1.Group("/admin")
+		adminGroup.Use(h.AuthMiddleware(), h.RequirePermission(models.PermissionManagePromotions))
+		{
+			adminGroup.GET("/ads/:id/promotions", h.GetAdPromotions)
+			adminGroup.POST("/ads/:id/promotions", h.GrantPromotion)
+			adminGroup.DELETE("/promotions/:id", h.RevokePromotion)
 		}
 	}
 
@@ -1,8 +1,17 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"marketplace/internal/models"
+	"marketplace/internal/service"
+	"marketplace/pkg/auth"
+	"marketplace/pkg/ratelimit"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -11,11 +20,47 @@ import (
 type contextKey string
 
 const (
-	userCtxKey = contextKey("userID")
+	userCtxKey   = contextKey("userID")
+	roleCtxKey   = contextKey("role")
+	claimsCtxKey = contextKey("claims")
+	apiKeyCtxKey = contextKey("apiKey")
 )
 
-func (h *Handler) AuthMiddleware() gin.HandlerFunc {
+// apiKeyHeader - заголовок, в котором машинные клиенты передают API-ключ.
+const apiKeyHeader = "X-API-Key"
+
+var (
+	errAPIKeyNotAccepted = errors.New("api keys are not accepted for this endpoint")
+	errAPIKeyScope       = errors.New("api key lacks the required scope")
+)
+
+// AuthMiddleware пропускает запросы с действующим access-токеном. Если заданы
+// области scopes, вместо токена можно передать API-ключ в заголовке X-API-Key
+// с одной из этих областей; без scopes эндпоинт API-ключи не принимает.
+func (h *Handler) AuthMiddleware(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if rawKey := c.GetHeader(apiKeyHeader); rawKey != "" {
+			principal, err := h.resolveAPIKey(c, rawKey, scopes)
+			if err != nil {
+				switch {
+				case errors.Is(err, service.ErrInvalidAPIKey):
+					h.newErrorResponse(c, http.StatusUnauthorized, "invalid api key", err)
+				case errors.Is(err, service.ErrUserBanned):
+					h.newErrorResponse(c, http.StatusForbidden, "user is banned", err)
+				case errors.Is(err, errAPIKeyNotAccepted):
+					h.newErrorResponse(c, http.StatusForbidden, "api keys are not accepted for this endpoint", err)
+				case errors.Is(err, errAPIKeyScope):
+					h.newErrorResponse(c, http.StatusForbidden, "api key lacks the required scope", err)
+				default:
+					h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+				}
+				return
+			}
+			setAPIKeyPrincipal(c, principal)
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			h.newErrorResponse(c, http.StatusUnauthorized, "authorization header is empty", fmt.Errorf("authorization header is empty"))
@@ -35,7 +80,140 @@ func (h *Handler) AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if err := h.service.Auth.CheckToken(c.Request.Context(), claims); err != nil {
+			if errors.Is(err, service.ErrTokenRevoked) {
+				h.newErrorResponse(c, http.StatusUnauthorized, "token has been revoked", err)
+				return
+			}
+			// Без проверки отзыва нельзя отличить действующий токен от отозванного
+			// выходом или сменой пароля, поэтому запрос отклоняется.
+			h.newErrorResponse(c, http.StatusServiceUnavailable, "token revocation check unavailable", err)
+			return
+		}
+
 		c.Set(string(userCtxKey), claims.UserID)
+		c.Set(string(roleCtxKey), claims.Role)
+		c.Set(string(claimsCtxKey), claims)
+		c.Next()
+	}
+}
+
+// OptionalAuthMiddleware распознает пользователя на публичных эндпоинтах.
+// Запрос без токена или с недействительным токеном обрабатывается как анонимный.
+// API-ключ учитывается, если у него есть одна из областей scopes.
+func (h *Handler) OptionalAuthMiddleware(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rawKey := c.GetHeader(apiKeyHeader); rawKey != "" {
+			if principal, err := h.resolveAPIKey(c, rawKey, scopes); err == nil {
+				setAPIKeyPrincipal(c, principal)
+			}
+			c.Next()
+			return
+		}
+
+		headerParts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			c.Next()
+			return
+		}
+
+		// Токен, отзыв которого не удалось проверить, не учитывается.
+		claims, err := h.TokenManager.ParseToken(headerParts[1])
+		if err != nil || h.service.Auth.CheckToken(c.Request.Context(), claims) != nil {
+			c.Next()
+			return
+		}
+
+		c.Set(string(userCtxKey), claims.UserID)
+		c.Set(string(roleCtxKey), claims.Role)
+		c.Next()
+	}
+}
+
+// resolveAPIKey находит владельца API-ключа и проверяет, что ключу выдана одна
+// из областей scopes.
+func (h *Handler) resolveAPIKey(c *gin.Context, rawKey string, scopes []string) (*models.APIKeyPrincipal, error) {
+	if len(scopes) == 0 {
+		return nil, errAPIKeyNotAccepted
+	}
+
+	principal, err := h.service.APIKey.Authenticate(c.Request.Context(), rawKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range scopes {
+		if principal.Key.HasScope(scope) {
+			return principal, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: key %d, required one of %v", errAPIKeyScope, principal.Key.ID, scopes)
+}
+
+func setAPIKeyPrincipal(c *gin.Context, principal *models.APIKeyPrincipal) {
+	c.Set(string(userCtxKey), principal.Actor.UserID)
+	c.Set(string(roleCtxKey), principal.Actor.Role)
+	c.Set(string(apiKeyCtxKey), principal.Key)
+}
+
+// RequirePermission пропускает запрос, только если роль пользователя дает разрешение perm.
+// Должен подключаться после AuthMiddleware.
+func (h *Handler) RequirePermission(perm models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !GetActorFromCtx(c).Can(perm) {
+			role, _ := GetUserRoleFromCtx(c)
+			h.newErrorResponse(c, http.StatusForbidden, "insufficient permissions", fmt.Errorf("role %q lacks permission %q", role, perm))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail пропускает запрос, только если пользователь подтвердил почту
+// или конфигурация этого не требует. Должен подключаться после AuthMiddleware.
+func (h *Handler) RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := GetUserIDFromCtx(c)
+		if !ok {
+			h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+			return
+		}
+
+		if err := h.service.Verification.RequireVerified(c.Request.Context(), userID); err != nil {
+			if errors.Is(err, service.ErrEmailNotVerified) {
+				h.newErrorResponse(c, http.StatusForbidden, "email is not verified", err)
+				return
+			}
+			h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RateLimitMiddleware ограничивает частоту запросов с одного IP-адреса.
+// При недоступности хранилища счетчиков запрос пропускается.
+func (h *Handler) RateLimitMiddleware(limiter ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		res, err := limiter.Allow(c.Request.Context(), c.ClientIP())
+		if err != nil {
+			h.log.Warn("rate limiter unavailable", slog.String("error", err.Error()))
+			c.Next()
+			return
+		}
+
+		if !res.Allowed {
+			retryAfter := int(math.Ceil(res.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			h.newErrorResponse(c, http.StatusTooManyRequests, "too many requests", fmt.Errorf("rate limit exceeded for %s", c.ClientIP()))
+			return
+		}
+
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
 		c.Next()
 	}
 }
@@ -49,3 +227,38 @@ func GetUserIDFromCtx(c *gin.Context) (int64, bool) {
 	userID, ok := val.(int64)
 	return userID, ok
 }
+
+// clientContext возвращает контекст запроса с IP-адресом и User-Agent клиента:
+// по ним открывается сессия при входе и отсеиваются повторы поисковых запросов.
+func clientContext(c *gin.Context) context.Context {
+	return service.WithClientInfo(c.Request.Context(), models.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// GetClaimsFromCtx возвращает утверждения access-токена запроса.
+func GetClaimsFromCtx(c *gin.Context) (*auth.Claims, bool) {
+	val, ok := c.Get(string(claimsCtxKey))
+	if !ok {
+		return nil, false
+	}
+	claims, ok := val.(*auth.Claims)
+	return claims, ok
+}
+
+func GetUserRoleFromCtx(c *gin.Context) (string, bool) {
+	val, ok := c.Get(string(roleCtxKey))
+	if !ok {
+		return "", false
+	}
+	role, ok := val.(string)
+	return role, ok
+}
+
+// GetActorFromCtx возвращает пользователя запроса; для анонимного запроса - нулевое значение.
+func GetActorFromCtx(c *gin.Context) models.Actor {
+	userID, _ := GetUserIDFromCtx(c)
+	role, _ := GetUserRoleFromCtx(c)
+	return models.Actor{UserID: userID, Role: role}
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"marketplace/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Жалоба на объявление
+// @Security ApiKeyAuth
+// @Security MachineKeyAuth
+// @Tags moderation
+// @Description Отправляет жалобу на объявление в очередь модерации
+// @Accept  json
+// @Produce  json
+// @Param id path int true "ID объявления"
+// @Param input body models.ReportAdRequest true "Причина жалобы"
+// @Success 201 {object} models.CreateReportResponse "ID созданной жалобы"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса или жалоба на свое объявление"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 404 {object} ErrorResponse "Объявление не найдено"
+// @Failure 409 {object} ErrorResponse "Жалоба на это объявление уже отправлена"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /ads/{id}/report [post]
+func (h *Handler) ReportAd(c *gin.Context) {
+	adID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid ad ID", err)
+		return
+	}
+
+	var req models.ReportAdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+		return
+	}
+
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	reportID, err := h.service.Moderation.ReportAd(c.Request.Context(), adID, userID, req.Reason, req.Comment)
+	if err != nil {
+		if errors.Is(err, postgres.ErrAdNotFound) {
+			h.newErrorResponse(c, http.StatusNotFound, "ad not found", err)
+		} else if errors.Is(err, service.ErrCannotReportOwnAd) {
+			h.newErrorResponse(c, http.StatusBadRequest, "cannot report your own ad", err)
+		} else if errors.Is(err, postgres.ErrReportExists) {
+			h.newErrorResponse(c, http.StatusConflict, "ad already reported", err)
+		} else {
+			h.newErrorResponse(c, http.StatusInternalServerError, "failed to report ad", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateReportResponse{ID: reportID})
+}
+
+// @Summary Очередь жалоб
+// @Security ApiKeyAuth
+// @Tags moderation
+// @Description Возвращает жалобы с указанным статусом, старые первыми (только модераторы)
+// @Produce  json
+// @Param status query string false "Статус жалоб" Enums(open, claimed, resolved) default(open)
+// @Param page query int false "Номер страницы" default(1)
+// @Param limit query int false "Количество элементов на странице" default(20)
+// @Success 200 {array} models.Report "Список жалоб"
+// @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /moderation/reports [get]
+func (h *Handler) GetReports(c *gin.Context) {
+	var query models.ReportsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid query parameters", err)
+		return
+	}
+
+	offset := (query.Page - 1) * query.Limit
+	reports, err := h.service.Moderation.GetReports(c.Request.Context(), query.Status, query.Limit, offset)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusInternalServerError, "failed to get reports", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+// @Summary Взять жалобу в работу
+// @Security ApiKeyAuth
+// @Tags moderation
+// @Description Закрепляет открытую жалобу за текущим модератором
+// @Param id path int true "ID жалобы"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse "Неверный ID жалобы"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} ErrorResponse "Жалоба не найдена"
+// @Failure 409 {object} ErrorResponse "Жалоба уже взята в работу или закрыта"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /moderation/reports/{id}/claim [post]
+func (h *Handler) ClaimReport(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid report ID", err)
+		return
+	}
+
+	moderatorID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	if err := h.service.Moderation.ClaimReport(c.Request.Context(), id, moderatorID); err != nil {
+		if errors.Is(err, postgres.ErrReportNotFound) {
+			h.newErrorResponse(c, http.StatusNotFound, "report not found", err)
+		} else if errors.Is(err, postgres.ErrReportClaimed) {
+			h.newErrorResponse(c, http.StatusConflict, "report already claimed", err)
+		} else {
+			h.newErrorResponse(c, http.StatusInternalServerError, "failed to claim report", err)
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Решение по жалобе
+// @Security ApiKeyAuth
+// @Tags moderation
+// @Description Закрывает жалобу и все открытые жалобы на то же объявление: скрывает объявление,
+// @Description отклоняет жалобы или блокирует автора. Владелец объявления получает уведомление.
+// @Accept  json
+// @Param id path int true "ID жалобы"
+// @Param input body models.ResolveReportRequest true "Решение модератора"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса или ID"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} ErrorResponse "Жалоба не найдена"
+// @Failure 409 {object} ErrorResponse "Жалоба не взята в работу текущим модератором"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /moderation/reports/{id}/resolve [post]
+func (h *Handler) ResolveReport(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid report ID", err)
+		return
+	}
+
+	var req models.ResolveReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+		return
+	}
+
+	moderatorID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	if err := h.service.Moderation.ResolveReport(c.Request.Context(), id, moderatorID, req.Decision); err != nil {
+		if errors.Is(err, postgres.ErrReportNotFound) {
+			h.newErrorResponse(c, http.StatusNotFound, "report not found", err)
+		} else if errors.Is(err, postgres.ErrReportNotClaimed) {
+			h.newErrorResponse(c, http.StatusConflict, "report is not claimed by you", err)
+		} else {
+			h.newErrorResponse(c, http.StatusInternalServerError, "failed to resolve report", err)
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Повторяющиеся объявления
+// @Security ApiKeyAuth
+// @Tags moderation
+// @Description Возвращает группы похожих объявлений, крупные группы первыми (только модераторы).
+// @Description Тексты сравниваются по сходству триграмм после приведения регистра, пунктуации и подмены букв,
+// @Description цены - с допуском screening.duplicates.price_tolerance. Похожие через цепочку объявления попадают в одну группу.
+// @Produce  json
+// @Param min_users query int false "Минимальное число продавцов в группе" default(1)
+// @Param page query int false "Номер страницы" default(1)
+// @Param limit query int false "Количество элементов на странице" default(20)
+// @Success 200 {array} models.DuplicateCluster "Группы повторяющихся объявлений"
+// @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /moderation/duplicates [get]
+func (h *Handler) GetDuplicateClusters(c *gin.Context) {
+	var query models.DuplicatesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid query parameters", err)
+		return
+	}
+
+	offset := (query.Page - 1) * query.Limit
+	clusters, err := h.service.Moderation.GetDuplicateClusters(c.Request.Context(), query.MinUsers, query.Limit, offset)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusInternalServerError, "failed to get duplicate ads", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, clusters)
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Уведомления пользователя
+// @Security ApiKeyAuth
+// @Tags notifications
+// @Description Возвращает последние уведомления текущего пользователя, например о решениях модераторов
+// @Produce  json
+// @Success 200 {array} models.Notification "Список уведомлений"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /notifications [get]
+func (h *Handler) GetNotifications(c *gin.Context) {
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	notifications, err := h.service.Notification.GetNotifications(c.Request.Context(), userID)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusInternalServerError, "failed to get notifications", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, notifications)
+}
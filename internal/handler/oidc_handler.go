@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"marketplace/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Вход через внешнего провайдера
+// @Tags auth
+// @Description Перенаправляет на страницу входа провайдера OpenID Connect из конфигурации.
+// @Description После входа провайдер вернет пользователя на GET /auth/oidc/{provider}/callback.
+// @Param provider path string true "Имя провайдера"
+// @Success 302 "Перенаправление на страницу провайдера"
+// @Failure 404 {object} ErrorResponse "Провайдер не настроен"
+// @Failure 502 {object} ErrorResponse "Провайдер недоступен"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/oidc/{provider}/login [get]
+func (h *Handler) beginExternalLogin(c *gin.Context) {
+	url, err := h.service.Auth.BeginExternalLogin(c.Request.Context(), c.Param("provider"), 0)
+	if err != nil {
+		h.externalLoginError(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, url)
+}
+
+// @Summary Возврат от внешнего провайдера
+// @Tags auth
+// @Description Завершает вход через провайдера OpenID Connect: проверяет state и ID-токен и выдает пару токенов.
+// @Description При первом входе создается новый пользователь. Если вход начат через POST /me/oidc/{provider}/link,
+// @Description провайдер привязывается к учетной записи и возвращается привязка.
+// @Produce  json
+// @Param provider path string true "Имя провайдера"
+// @Param code query string true "Код авторизации"
+// @Param state query string true "Значение state из запроса на вход"
+// @Success 200 {object} models.LoginResponse "Успешная авторизация"
+// @Success 201 {object} models.ExternalIdentity "Провайдер привязан"
+// @Success 202 {object} models.TwoFactorChallengeResponse "Требуется код второго фактора"
+// @Failure 400 {object} ErrorResponse "Нет кода или state недействителен"
+// @Failure 401 {object} ErrorResponse "Провайдер отклонил вход или ID-токен не прошел проверку"
+// @Failure 403 {object} ErrorResponse "Пользователь заблокирован"
+// @Failure 404 {object} ErrorResponse "Провайдер не настроен"
+// @Failure 409 {object} ErrorResponse "Почта или учетная запись провайдера уже заняты"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/oidc/{provider}/callback [get]
+func (h *Handler) completeExternalLogin(c *gin.Context) {
+	if providerErr := c.Query("error"); providerErr != "" {
+		h.newErrorResponse(c, http.StatusUnauthorized, "external login was denied", fmt.Errorf("provider returned %q", providerErr))
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		h.newErrorResponse(c, http.StatusBadRequest, "code and state are required", fmt.Errorf("missing code or state"))
+		return
+	}
+
+	result, err := h.service.Auth.CompleteExternalLogin(clientContext(c), c.Param("provider"), state, code)
+	if err != nil {
+		h.externalLoginError(c, err)
+		return
+	}
+
+	switch {
+	case result.Linked != nil:
+		c.JSON(http.StatusCreated, result.Linked)
+	case result.Login.Challenge != nil:
+		c.JSON(http.StatusAccepted, models.TwoFactorChallengeResponse{
+			ChallengeToken: result.Login.Challenge.Token,
+			ExpiresIn:      int64(result.Login.Challenge.ExpiresIn.Seconds()),
+		})
+	default:
+		c.JSON(http.StatusOK, toLoginResponse(result.Login.Tokens))
+	}
+}
+
+// @Summary Привязка внешнего провайдера
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description Возвращает адрес страницы входа провайдера. После входа у провайдера его учетная запись
+// @Description привязывается к текущему пользователю, и дальше можно входить через провайдера.
+// @Produce  json
+// @Param provider path string true "Имя провайдера"
+// @Success 200 {object} models.ExternalLoginURLResponse "Адрес страницы провайдера"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 404 {object} ErrorResponse "Провайдер не настроен"
+// @Failure 502 {object} ErrorResponse "Провайдер недоступен"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /me/oidc/{provider}/link [post]
+func (h *Handler) linkExternalIdentity(c *gin.Context) {
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	url, err := h.service.Auth.BeginExternalLogin(c.Request.Context(), c.Param("provider"), userID)
+	if err != nil {
+		h.externalLoginError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ExternalLoginURLResponse{AuthorizationURL: url})
+}
+
+// @Summary Привязанные провайдеры
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description Возвращает внешних провайдеров, через которых пользователь может войти.
+// @Produce  json
+// @Success 200 {array} models.ExternalIdentity "Привязанные провайдеры"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /me/identities [get]
+func (h *Handler) getExternalIdentities(c *gin.Context) {
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	identities, err := h.service.Auth.ExternalIdentities(c.Request.Context(), userID)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		return
+	}
+	c.JSON(http.StatusOK, identities)
+}
+
+// externalLoginError отвечает на ошибки входа через внешнего провайдера.
+func (h *Handler) externalLoginError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnknownProvider):
+		h.newErrorResponse(c, http.StatusNotFound, "identity provider not found", err)
+	case errors.Is(err, service.ErrProviderUnavailable):
+		h.newErrorResponse(c, http.StatusBadGateway, "identity provider is unavailable", err)
+	case errors.Is(err, service.ErrInvalidOIDCState):
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid or expired login state, please start again", err)
+	case errors.Is(err, service.ErrExternalAuthFailed):
+		h.newErrorResponse(c, http.StatusUnauthorized, "external authentication failed", err)
+	case errors.Is(err, service.ErrUserBanned):
+		h.newErrorResponse(c, http.StatusForbidden, "user is banned", err)
+	case errors.Is(err, service.ErrExternalEmailTaken):
+		h.newErrorResponse(c, http.StatusConflict, "an account with this email already exists, sign in and link the provider", err)
+	case errors.Is(err, postgres.ErrIdentityLinked):
+		h.newErrorResponse(c, http.StatusConflict, "this external account is already linked to another user", err)
+	case errors.Is(err, postgres.ErrProviderAlreadyLinked):
+		h.newErrorResponse(c, http.StatusConflict, "an account from this provider is already linked", err)
+	default:
+		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+	}
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"marketplace/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Продвижение объявления
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description Закрепляет объявление над выдачей на указанный период (только администратор)
+// @Accept  json
+// @Produce  json
+// @Param id path int true "ID объявления"
+// @Param input body models.GrantPromotionRequest true "Период продвижения"
+// @Success 201 {object} models.CreatePromotionResponse "ID созданного продвижения"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса или период"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} ErrorResponse "Объявление не найдено"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/ads/{id}/promotions [post]
+func (h *Handler) GrantPromotion(c *gin.Context) {
+	adID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid ad ID", err)
+		return
+	}
+
+	var req models.GrantPromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+		return
+	}
+
+	adminID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	promotion, err := h.service.Promotion.Grant(c.Request.Context(), adID, adminID, req.StartsAt, req.EndsAt)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPromotionPeriod) {
+			h.newErrorResponse(c, http.StatusBadRequest, "invalid promotion period", err)
+		} else if errors.Is(err, postgres.ErrAdNotFound) {
+			h.newErrorResponse(c, http.StatusNotFound, "ad not found", err)
+		} else {
+			h.newErrorResponse(c, http.StatusInternalServerError, "failed to grant promotion", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreatePromotionResponse{ID: promotion.ID})
+}
+
+// @Summary Продвижения объявления
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description Возвращает историю продвижений объявления (только администратор)
+// @Produce  json
+// @Param id path int true "ID объявления"
+// @Success 200 {array} models.Promotion "Список продвижений"
+// @Failure 400 {object} ErrorResponse "Неверный ID объявления"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/ads/{id}/promotions [get]
+func (h *Handler) GetAdPromotions(c *gin.Context) {
+	adID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid ad ID", err)
+		return
+	}
+
+	promotions, err := h.service.Promotion.GetByAdID(c.Request.Context(), adID)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusInternalServerError, "failed to get promotions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, promotions)
+}
+
+// @Summary Отзыв продвижения
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description Досрочно прекращает продвижение объявления (только администратор)
+// @Param id path int true "ID продвижения"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse "Неверный ID продвижения"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 403 {object} ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} ErrorResponse "Продвижение не найдено или уже отозвано"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/promotions/{id} [delete]
+func (h *Handler) RevokePromotion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid promotion ID", err)
+		return
+	}
+
+	if err := h.service.Promotion.Revoke(c.Request.Context(), id); err != nil {
+		if errors.Is(err, postgres.ErrPromotionNotFound) {
+			h.newErrorResponse(c, http.StatusNotFound, "promotion not found", err)
+			return
+		}
+		h.newErrorResponse(c, http.StatusInternalServerError, "failed to revoke promotion", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -1,16 +1,74 @@
 package handler
 
 import (
+	"errors"
 	"log/slog"
+	"marketplace/internal/repository/postgres"
+	"marketplace/internal/service"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ErrorResponse struct {
 	Message string `json:"message"`
+	// Fields содержит ошибки по отдельным полям запроса.
+	Fields map[string]string `json:"fields,omitempty"`
+	// Reasons перечисляет причины отказа с машиночитаемыми кодами.
+	Reasons []ErrorReason `json:"reasons,omitempty"`
+}
+
+type ErrorReason struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 func (h *Handler) newErrorResponse(c *gin.Context, statusCode int, message string, err error) {
 	h.log.Error(message, slog.String("error", err.Error()))
 	c.AbortWithStatusJSON(statusCode, ErrorResponse{Message: message})
 }
+
+// newFieldErrorResponse отвечает ошибкой с описанием нарушений по полям запроса.
+func (h *Handler) newFieldErrorResponse(c *gin.Context, statusCode int, message string, fields map[string]string, err error) {
+	h.log.Warn(message, slog.String("error", err.Error()))
+	c.AbortWithStatusJSON(statusCode, ErrorResponse{Message: message, Fields: fields})
+}
+
+// newConstraintErrorResponse отвечает на нарушение ограничения базы данных:
+// 409 для уникальности, 422 для внешнего ключа и проверки значения. Если err
+// не содержит *postgres.ConstraintError, ответ не отправляется и возвращается false.
+func (h *Handler) newConstraintErrorResponse(c *gin.Context, err error) bool {
+	var constraint *postgres.ConstraintError
+	if !errors.As(err, &constraint) {
+		return false
+	}
+
+	status, message, problem := http.StatusUnprocessableEntity, "invalid value", "value is not allowed"
+	switch constraint.Kind {
+	case postgres.ConstraintUnique:
+		status, message, problem = http.StatusConflict, "resource already exists", "already in use"
+	case postgres.ConstraintForeignKey:
+		message, problem = "referenced resource does not exist", "references a missing resource"
+	}
+	var fields map[string]string
+	if constraint.Field != "" {
+		fields = map[string]string{constraint.Field: problem}
+	}
+	h.newFieldErrorResponse(c, status, message, fields, err)
+	return true
+}
+
+// newWeakPasswordResponse отвечает 422 с перечнем нарушенных требований к паролю
+// из поля field запроса.
+func (h *Handler) newWeakPasswordResponse(c *gin.Context, field string, weak *service.WeakPasswordError) {
+	h.log.Warn("password does not meet policy", slog.String("error", weak.Error()))
+	reasons := make([]ErrorReason, 0, len(weak.Violations))
+	for _, v := range weak.Violations {
+		reasons = append(reasons, ErrorReason{Code: v.Code, Message: v.Message})
+	}
+	c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorResponse{
+		Message: "password does not meet policy",
+		Fields:  map[string]string{field: weak.Violations[0].Message},
+		Reasons: reasons,
+	})
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"marketplace/internal/repository/postgres"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Активные сессии
+// @Security ApiKeyAuth
+// @Tags sessions
+// @Description Возвращает устройства, на которых выполнен вход: User-Agent, IP-адрес, время входа и
+// @Description последнего обновления токенов. Сессия текущего токена отмечена полем current.
+// @Produce  json
+// @Success 200 {array} models.Session "Сессии пользователя"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /me/sessions [get]
+func (h *Handler) listSessions(c *gin.Context) {
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	var currentID string
+	if claims, ok := GetClaimsFromCtx(c); ok {
+		currentID = claims.SessionID
+	}
+
+	sessions, err := h.service.Session.List(c.Request.Context(), userID, currentID)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// @Summary Завершение сессии
+// @Security ApiKeyAuth
+// @Tags sessions
+// @Description Выходит из системы на выбранном устройстве: refresh-токены сессии отзываются,
+// @Description а ее access-токены перестают приниматься сразу. Можно завершить и текущую сессию.
+// @Param id path string true "ID сессии"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 404 {object} ErrorResponse "Сессия не найдена или уже завершена"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /me/sessions/{id} [delete]
+func (h *Handler) revokeSession(c *gin.Context) {
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	if err := h.service.Session.Revoke(c.Request.Context(), userID, c.Param("id")); err != nil {
+		if errors.Is(err, postgres.ErrSessionNotFound) {
+			h.newErrorResponse(c, http.StatusNotFound, "session not found", err)
+			return
+		}
+		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
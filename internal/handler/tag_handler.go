@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"marketplace/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Популярные теги
+// @Tags tags
+// @Description Возвращает теги, которые чаще всего используются в объявлениях
+// @Produce  json
+// @Param limit query int false "Количество тегов" default(20)
+// @Success 200 {array} models.TagCount "Список тегов с количеством объявлений"
+// @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /tags/popular [get]
+func (h *Handler) GetPopularTags(c *gin.Context) {
+	var query models.PopularTagsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid query parameters", err)
+		return
+	}
+
+	tags, err := h.service.Tag.GetPopularTags(c.Request.Context(), query.Limit)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusInternalServerError, "failed to get tags", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
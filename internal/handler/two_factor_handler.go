@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+	"marketplace/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Подключение двухфакторной аутентификации
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description Создает секрет TOTP и возвращает его вместе с otpauth-URI и QR-кодом (PNG в base64).
+// @Description Двухфакторная аутентификация включится после подтверждения кодом (POST /me/2fa/confirm).
+// @Produce  json
+// @Success 200 {object} models.TOTPEnrollmentResponse "Секрет для приложения-аутентификатора"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 409 {object} ErrorResponse "Двухфакторная аутентификация уже включена"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /me/2fa/enroll [post]
+func (h *Handler) enrollTwoFactor(c *gin.Context) {
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	enrollment, err := h.service.TwoFactor.Enroll(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrTwoFactorAlreadyEnabled) {
+			h.newErrorResponse(c, http.StatusConflict, "two-factor authentication is already enabled", err)
+			return
+		}
+		h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TOTPEnrollmentResponse{
+		Secret:     enrollment.Secret,
+		OTPAuthURI: enrollment.URI,
+		QRCodePNG:  enrollment.QRCode,
+	})
+}
+
+// @Summary Подтверждение двухфакторной аутентификации
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description Включает двухфакторную аутентификацию по первому коду из приложения и возвращает
+// @Description одноразовые резервные коды. Коды показываются только один раз.
+// @Accept  json
+// @Produce  json
+// @Param   input body models.TOTPConfirmRequest true "Код из приложения"
+// @Success 200 {object} models.RecoveryCodesResponse "Резервные коды"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса или неверный код"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 409 {object} ErrorResponse "Подключение не начато или уже завершено"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /me/2fa/confirm [post]
+func (h *Handler) confirmTwoFactor(c *gin.Context) {
+	var req models.TOTPConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+		return
+	}
+
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	codes, err := h.service.TwoFactor.Confirm(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTwoFactorCode):
+			h.newErrorResponse(c, http.StatusBadRequest, "invalid two-factor code", err)
+		case errors.Is(err, service.ErrTwoFactorNotEnrolled):
+			h.newErrorResponse(c, http.StatusConflict, "two-factor enrollment has not been started", err)
+		case errors.Is(err, service.ErrTwoFactorAlreadyEnabled):
+			h.newErrorResponse(c, http.StatusConflict, "two-factor authentication is already enabled", err)
+		default:
+			h.newErrorResponse(c, http.StatusInternalServerError, "internal server error", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Публичный профиль продавца
+// @Tags users
+// @Description Возвращает имя продавца, дату регистрации и число его активных объявлений
+// @Produce  json
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} models.PublicProfile "Профиль продавца"
+// @Failure 400 {object} ErrorResponse "Неверный ID пользователя"
+// @Failure 404 {object} ErrorResponse "Пользователь не найден"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /users/{id} [get]
+func (h *Handler) GetUserProfile(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid user ID", err)
+		return
+	}
+
+	profile, err := h.service.User.GetPublicProfile(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, postgres.ErrUserNotFound) {
+			h.newErrorResponse(c, http.StatusNotFound, "user not found", err)
+		} else {
+			h.newErrorResponse(c, http.StatusInternalServerError, "failed to get user profile", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary Объявления продавца
+// @Tags users
+// @Description Возвращает активные объявления продавца с теми же пагинацией, сортировкой и фильтрами, что и общий список
+// @Produce  json
+// @Param id path int true "ID пользователя"
+// @Param page query int false "Номер страницы" default(1)
+// @Param limit query int false "Количество элементов на странице" default(10)
+// @Param sort_by query string false "Поле для сортировки" Enums(created_at, price) default(created_at)
+// @Param sort_order query string false "Порядок сортировки" Enums(asc, desc) default(desc)
+// @Param q query string false "Поиск по заголовку"
+// @Param tag query string false "Фильтр по тегу"
+// @Success 200 {array} models.AdResponse "Список объявлений"
+// @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
+// @Failure 404 {object} ErrorResponse "Пользователь не найден"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /users/{id}/ads [get]
+func (h *Handler) GetUserAds(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid user ID", err)
+		return
+	}
+
+	var query models.AdsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid query parameters", err)
+		return
+	}
+
+	ads, err := h.service.User.GetUserAds(c.Request.Context(), id, listParams(query))
+	if err != nil {
+		if errors.Is(err, postgres.ErrUserNotFound) {
+			h.newErrorResponse(c, http.StatusNotFound, "user not found", err)
+		} else {
+			h.newErrorResponse(c, http.StatusInternalServerError, "failed to get ads", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, toAdResponses(ads))
+}
+
+// @Summary Профиль текущего пользователя
+// @Security ApiKeyAuth
+// @Tags users
+// @Description Возвращает данные пользователя из токена вместе с полями профиля
+// @Produce  json
+// @Success 200 {object} models.ProfileResponse "Профиль"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 404 {object} ErrorResponse "Пользователь не найден"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /me [get]
+func (h *Handler) getProfile(c *gin.Context) {
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	user, err := h.service.User.GetProfile(c.Request.Context(), userID)
+	if err != nil {
+		h.profileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toProfileResponse(user))
+}
+
+// @Summary Изменение профиля
+// @Security ApiKeyAuth
+// @Tags users
+// @Description Изменяет имя, аватар, телефон, описание и язык. Меняются только переданные поля,
+// @Description пустая строка очищает поле. Имя, аватар и описание видны на публичной странице продавца.
+// @Accept  json
+// @Produce  json
+// @Param   input body models.UpdateProfileRequest true "Поля профиля"
+// @Success 200 {object} models.ProfileResponse "Обновленный профиль"
+// @Failure 400 {object} ErrorResponse "Неверный формат запроса"
+// @Failure 401 {object} ErrorResponse "Пользователь не авторизован"
+// @Failure 404 {object} ErrorResponse "Пользователь не найден"
+// @Failure 409 {object} ErrorResponse "Значение поля уже занято"
+// @Failure 422 {object} ErrorResponse "Значение поля нарушает ограничения"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /me [patch]
+func (h *Handler) updateProfile(c *gin.Context) {
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.newErrorResponse(c, http.StatusBadRequest, "invalid request body", err)
+		return
+	}
+
+	userID, ok := GetUserIDFromCtx(c)
+	if !ok {
+		h.newErrorResponse(c, http.StatusUnauthorized, "invalid user context", fmt.Errorf("user context not found"))
+		return
+	}
+
+	user, err := h.service.User.UpdateProfile(c.Request.Context(), userID, req)
+	if err != nil {
+		h.profileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toProfileResponse(user))
+}
+
+func (h *Handler) profileError(c *gin.Context, err error) {
+	if errors.Is(err, postgres.ErrUserNotFound) {
+		h.newErrorResponse(c, http.StatusNotFound, "user not found", err)
+	} else if !h.newConstraintErrorResponse(c, err) {
+		h.newErrorResponse(c, http.StatusInternalServerError, "failed to get user profile", err)
+	}
+}
+
+func toProfileResponse(user *models.User) models.ProfileResponse {
+	return models.ProfileResponse{
+		ID:               user.ID,
+		Username:         user.Username,
+		Email:            user.Email,
+		EmailVerified:    user.EmailVerifiedAt != nil,
+		Role:             user.Role,
+		TwoFactorEnabled: user.TOTPEnabledAt != nil,
+		DisplayName:      user.DisplayName,
+		AvatarURL:        user.AvatarURL,
+		Phone:            user.Phone,
+		Bio:              user.Bio,
+		Language:         user.Language,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+	}
+}
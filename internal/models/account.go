@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// AccountExport - все данные, которые сервис хранит о пользователе.
+type AccountExport struct {
+	ExportedAt     time.Time          `json:"exported_at"`
+	Profile        *User              `json:"profile"`
+	Ads            []Ad               `json:"ads"`
+	Reports        []Report           `json:"reports"`
+	Notifications  []Notification     `json:"notifications"`
+	APIKeys        []APIKey           `json:"api_keys"`
+	Identities     []ExternalIdentity `json:"identities"`
+	LoginHistory   []Session          `json:"login_history"`
+	SecurityEvents []AuditEntry       `json:"security_events"`
+}
@@ -2,13 +2,52 @@ package models
 
 import "time"
 
+// Источник скрытия объявления. Отклонение жалоб публикует только объявления,
+// скрытые автоматической проверкой до решения модератора.
+const (
+	HiddenByScreening = "screening"
+	HiddenByModerator = "moderator"
+)
+
 type Ad struct {
-	ID          int64     `json:"id"`
-	UserID      int64     `json:"user_id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price"`
-	ImageURL    string    `json:"image_url"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Price       float64    `json:"price"`
+	ImageURL    string     `json:"image_url"`
+	Tags        []string   `json:"tags"`
+	Promoted    bool       `json:"promoted"`
+	HiddenAt    *time.Time `json:"hidden_at,omitempty"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// NormalizedText - заголовок и описание в виде для поиска похожих объявлений
+	// (см. screening.Normalize).
+	NormalizedText string `json:"-"`
+
+	// HiddenBy - источник скрытия объявления (HiddenByScreening или
+	// HiddenByModerator), пустой у видимых объявлений.
+	HiddenBy string `json:"-"`
+
+	// ScreeningNote заполняется автоматической проверкой. Объявление с непустой
+	// заметкой создается скрытым и попадает в очередь модерации.
+	ScreeningNote string `json:"-"`
+}
+
+// IsScheduled сообщает, ожидает ли объявление отложенной публикации на момент now.
+// До публикации объявление видят только владелец и модераторы.
+func (a *Ad) IsScheduled(now time.Time) bool {
+	return a.PublishAt != nil && a.PublishAt.After(now)
+}
+
+// DuplicateCluster - группа похожих объявлений. Объявления попадают в одну
+// группу, если каждое похоже хотя бы на одно другое объявление группы.
+type DuplicateCluster struct {
+	AdCount       int       `json:"ad_count"`
+	UserCount     int       `json:"user_count"`
+	AdIDs         []int64   `json:"ad_ids"`
+	UserIDs       []int64   `json:"user_ids"`
+	LastCreatedAt time.Time `json:"last_created_at"`
 }
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Области доступа API-ключей. Ключ действует только на эндпоинтах,
+// которые принимают одну из его областей.
+const (
+	ScopeAdsRead  = "ads:read"
+	ScopeAdsWrite = "ads:write"
+)
+
+// APIKey - именованный ключ для скриптов и интеграций. Сам ключ показывается
+// один раз при создании, в базе хранится только его хеш; Prefix помогает
+// пользователю отличать ключи в списке.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// HasScope сообщает, выдана ли ключу область scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyPrincipal - пользователь, от имени которого действует предъявленный ключ.
+type APIKeyPrincipal struct {
+	Key   *APIKey
+	Actor Actor
+}
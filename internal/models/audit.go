@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+const (
+	AuditLoginLockout = "login_lockout"
+)
+
+// AuditEntry - запись журнала событий безопасности. UserID пуст, если событие
+// не удалось связать с существующим пользователем.
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	UserID    *int64    `json:"user_id,omitempty"`
+	Action    string    `json:"action"`
+	IP        string    `json:"ip,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -4,7 +4,9 @@ import "time"
 
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=4,max=32"`
-	Password string `json:"password" binding:"required,min=8,max=64"`
+	Password string `json:"password" binding:"required,min=8,max=256"`
+	// Email подтверждается по ссылке из письма и нужен для восстановления пароля.
+	Email string `json:"email" binding:"required,email,max=254"`
 }
 
 type UserResponse struct {
@@ -13,34 +15,153 @@ type UserResponse struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// ProfileResponse - профиль текущего пользователя. Телефон и почта видны только
+// ему самому, на публичной странице продавца их нет.
+type ProfileResponse struct {
+	ID               int64     `json:"id"`
+	Username         string    `json:"username"`
+	Email            string    `json:"email,omitempty"`
+	EmailVerified    bool      `json:"email_verified"`
+	Role             string    `json:"role"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	DisplayName      string    `json:"display_name,omitempty"`
+	AvatarURL        string    `json:"avatar_url,omitempty"`
+	Phone            string    `json:"phone,omitempty"`
+	Bio              string    `json:"bio,omitempty"`
+	Language         string    `json:"language,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// UpdateProfileRequest изменяет только переданные поля; пустая строка очищает поле.
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name,omitempty" binding:"omitempty,max=64"`
+	AvatarURL   *string `json:"avatar_url,omitempty" binding:"omitempty,max=2048,len=0|url"`
+	// Phone - номер в формате E.164, например +79991234567.
+	Phone    *string `json:"phone,omitempty" binding:"omitempty,len=0|e164"`
+	Bio      *string `json:"bio,omitempty" binding:"omitempty,max=500"`
+	Language *string `json:"language,omitempty" binding:"omitempty,len=0|oneof=ru en"`
+}
+
+// DeleteAccountRequest подтверждает удаление учетной записи текущим паролем.
+// Пользователь без пароля оставляет поле пустым и подтверждает удаление
+// недавним входом через провайдера.
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	// ExpiresIn - время жизни access-токена в секундах.
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// TwoFactorChallengeResponse возвращается при входе, если у пользователя
+// включена двухфакторная аутентификация.
+type TwoFactorChallengeResponse struct {
+	ChallengeToken string `json:"challenge_token"`
+	// ExpiresIn - время жизни challenge-токена в секундах.
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	// Code - шестизначный код из приложения или резервный код.
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+type TOTPEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	// QRCodePNG - PNG с QR-кодом otpauth-URI в base64.
+	QRCodePNG []byte `json:"qr_code_png"`
+}
+
+type TOTPConfirmRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=ads:read ads:write"`
+}
+
+// CreateAPIKeyResponse содержит ключ целиком; повторно получить его нельзя.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// ExternalLoginURLResponse - адрес, на который нужно перейти для входа у провайдера.
+type ExternalLoginURLResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type VerifyEmailResponse struct {
+	Email    string `json:"email"`
+	Verified bool   `json:"verified"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8,max=256"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8,max=256"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	// RefreshToken, если указан, отзывается вместе с access-токеном.
+	RefreshToken string `json:"refresh_token"`
 }
 
 type CreateAdRequest struct {
-	Title       string  `json:"title" binding:"required,min=1,max=100"`
-	Description string  `json:"description" binding:"required,max=1000"`
-	Price       float64 `json:"price" binding:"required,gte=0"`
-	ImageURL    string  `json:"image_url" binding:"omitempty,url"`
+	Title       string   `json:"title" binding:"required,min=1,max=100"`
+	Description string   `json:"description" binding:"required,max=1000"`
+	Price       float64  `json:"price" binding:"required,gte=0"`
+	ImageURL    string   `json:"image_url" binding:"omitempty,url"`
+	Tags        []string `json:"tags" binding:"omitempty,max=50"`
+	// PublishAt откладывает публикацию объявления до указанного времени.
+	PublishAt *time.Time `json:"publish_at,omitempty"`
 }
 
 type CreateAdResponse struct {
-	ID int64 `json:"id"`
+	ID            int64 `json:"id"`
+	PendingReview bool  `json:"pending_review,omitempty"`
 }
 
 type AdResponse struct {
-	ID          int64     `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price"`
-	ImageURL    string    `json:"image_url"`
-	AuthorID    int64     `json:"author_id"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          int64      `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Price       float64    `json:"price"`
+	ImageURL    string     `json:"image_url"`
+	Tags        []string   `json:"tags"`
+	Promoted    bool       `json:"promoted"`
+	Hidden      bool       `json:"hidden,omitempty"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	AuthorID    int64      `json:"author_id"`
+	CreatedAt   time.Time  `json:"created_at"`
 }
 
 type AdsQuery struct {
@@ -48,10 +169,74 @@ type AdsQuery struct {
 	Limit     int    `form:"limit,default=10"`
 	SortBy    string `form:"sort_by,default=created_at"` // 'created_at' or 'price'
 	SortOrder string `form:"sort_order,default=desc"`    // 'asc' or 'desc'
+	Search    string `form:"q" binding:"max=100"`
+	Tag       string `form:"tag" binding:"max=30"`
+}
+
+type SuggestQuery struct {
+	Prefix string `form:"prefix" binding:"required,min=2,max=50"`
+	Limit  int    `form:"limit,default=5" binding:"min=1,max=10"`
+}
+
+type SuggestResponse struct {
+	Titles  []string `json:"titles"`
+	Queries []string `json:"queries"`
+}
+
+// Типы содержимого патчей для PATCH /ads/{id}.
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// AdPatch - тело запроса с JSON Merge Patch или JSON Patch для объявления.
+type AdPatch struct {
+	ContentType string
+	Body        []byte
 }
 
 type UpdateAdRequest struct {
-	Title       *string  `json:"title,omitempty"`
-	Description *string  `json:"description,omitempty"`
-	Price       *float64 `json:"price,omitempty"`
+	Title       *string   `json:"title,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Price       *float64  `json:"price,omitempty"`
+	Tags        *[]string `json:"tags,omitempty" binding:"omitempty,max=50"`
+}
+
+type PopularTagsQuery struct {
+	Limit int `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+type GrantPromotionRequest struct {
+	StartsAt time.Time `json:"starts_at" binding:"required"`
+	EndsAt   time.Time `json:"ends_at" binding:"required"`
+}
+
+type CreatePromotionResponse struct {
+	ID int64 `json:"id"`
+}
+
+type ReportAdRequest struct {
+	Reason  string `json:"reason" binding:"required,oneof=spam scam prohibited offensive duplicate other"`
+	Comment string `json:"comment" binding:"max=500"`
+}
+
+type CreateReportResponse struct {
+	ID int64 `json:"id"`
+}
+
+type ReportsQuery struct {
+	Status string `form:"status,default=open" binding:"oneof=open claimed resolved"`
+	Page   int    `form:"page,default=1" binding:"min=1"`
+	Limit  int    `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+type DuplicatesQuery struct {
+	// MinUsers отбирает группы, в которых участвует не меньше указанного числа продавцов.
+	MinUsers int `form:"min_users,default=1" binding:"min=1"`
+	Page     int `form:"page,default=1" binding:"min=1"`
+	Limit    int `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+type ResolveReportRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=hide dismiss ban_author"`
 }
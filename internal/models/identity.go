@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// ExternalIdentity - учетная запись пользователя у внешнего провайдера
+// OpenID Connect. Провайдер однозначно определяет пользователя по Subject.
+type ExternalIdentity struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"-"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"-"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OIDCState - данные попытки входа через провайдера, которые хранятся до
+// возврата пользователя. Verifier - секрет PKCE, Nonce сверяется с ID-токеном.
+type OIDCState struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	// LinkUserID - пользователь, который привязывает провайдера к своей
+	// учетной записи. Для обычного входа - 0.
+	LinkUserID int64 `json:"link_user_id,omitempty"`
+}
+
+// ExternalLoginResult - итог возврата от провайдера: вход (Login) или
+// привязка провайдера к учетной записи (Linked).
+type ExternalLoginResult struct {
+	Login  *LoginResult
+	Linked *ExternalIdentity
+}
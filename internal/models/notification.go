@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+const (
+	NotificationAdHidden      = "ad_hidden"
+	NotificationAdReviewed    = "ad_reviewed"
+	NotificationAccountBanned = "account_banned"
+	NotificationAdApproved    = "ad_approved"
+)
+
+type Notification struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"-"`
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+	AdID      *int64    `json:"ad_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+// Permission - действие, которое роль разрешает выполнять над чужими данными.
+// Со своими объявлениями пользователь работает без отдельных разрешений.
+type Permission string
+
+const (
+	PermissionViewHiddenAds    Permission = "ads:view_hidden"
+	PermissionEditAnyAd        Permission = "ads:edit_any"
+	PermissionHideAnyAd        Permission = "ads:hide_any"
+	PermissionModerateReports  Permission = "reports:moderate"
+	PermissionManagePromotions Permission = "promotions:manage"
+)
+
+var moderatorPermissions = []Permission{
+	PermissionViewHiddenAds,
+	PermissionEditAnyAd,
+	PermissionHideAnyAd,
+	PermissionModerateReports,
+}
+
+// rolePermissions задает разрешения каждой роли. Роль user разрешений не имеет.
+var rolePermissions = map[string][]Permission{
+	RoleModerator: moderatorPermissions,
+	RoleAdmin:     append([]Permission{PermissionManagePromotions}, moderatorPermissions...),
+}
+
+// HasPermission сообщает, входит ли разрешение в роль.
+func HasPermission(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type Promotion struct {
+	ID        int64      `json:"id"`
+	AdID      int64      `json:"ad_id"`
+	GrantedBy int64      `json:"granted_by"`
+	StartsAt  time.Time  `json:"starts_at"`
+	EndsAt    time.Time  `json:"ends_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	ReportReasonSpam       = "spam"
+	ReportReasonScam       = "scam"
+	ReportReasonProhibited = "prohibited"
+	ReportReasonOffensive  = "offensive"
+	ReportReasonDuplicate  = "duplicate"
+	ReportReasonOther      = "other"
+
+	// ReportReasonAutoScreening - системная жалоба, которой автоматическая
+	// проверка отправляет объявление на премодерацию.
+	ReportReasonAutoScreening = "auto_screening"
+)
+
+const (
+	ReportStatusOpen     = "open"
+	ReportStatusClaimed  = "claimed"
+	ReportStatusResolved = "resolved"
+)
+
+const (
+	DecisionHide      = "hide"
+	DecisionDismiss   = "dismiss"
+	DecisionBanAuthor = "ban_author"
+)
+
+type Report struct {
+	ID          int64      `json:"id"`
+	AdID        int64      `json:"ad_id"`
+	ReporterID  *int64     `json:"reporter_id"`
+	Reason      string     `json:"reason"`
+	Comment     string     `json:"comment"`
+	Status      string     `json:"status"`
+	ModeratorID *int64     `json:"moderator_id,omitempty"`
+	Decision    *string    `json:"decision,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ClaimedAt   *time.Time `json:"claimed_at,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Session - вход пользователя с одного устройства. Сессия живет, пока
+// обновляются ее refresh-токены, и совпадает с их семейством.
+type Session struct {
+	ID        string    `json:"id"`
+	UserID    int64     `json:"-"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+	// LastSeenAt - время входа или последнего обновления токенов.
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// Current отмечает сессию, которой принадлежит токен запроса.
+	Current bool `json:"current"`
+}
+
+// ClientInfo описывает клиента, с которого выполняется вход.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
@@ -0,0 +1,6 @@
+package models
+
+type TagCount struct {
+	Name     string `json:"name"`
+	AdsCount int64  `json:"ads_count"`
+}
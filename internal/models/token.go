@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// RefreshToken - выданный пользователю refresh-токен. Сам токен не хранится,
+// только его хеш. Токены, полученные последовательной ротацией, образуют
+// семейство с общим FamilyID.
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// TokenPair - пара токенов, выдаваемая при входе и при обновлении сессии.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// EmailVerificationToken - одноразовый токен подтверждения почты. Токен
+// действует только для адреса Email, на который был отправлен.
+type EmailVerificationToken struct {
+	ID        int64
+	UserID    int64
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// PasswordResetToken - одноразовый токен сброса пароля. Как и refresh-токен,
+// хранится только в виде хеша.
+type PasswordResetToken struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// TOTPSettings - состояние двухфакторной аутентификации пользователя. Секрет
+// сохраняется при начале подключения, а EnabledAt выставляется только после
+// подтверждения первым кодом из приложения.
+type TOTPSettings struct {
+	UserID    int64
+	Secret    string
+	EnabledAt *time.Time
+}
+
+// TOTPEnrollment - данные для добавления аккаунта в приложение-аутентификатор.
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+	QRCode []byte
+}
+
+// TwoFactorChallenge - короткоживущий токен второго шага входа. Его обменивают
+// на пару токенов вместе с кодом из приложения или резервным кодом.
+type TwoFactorChallenge struct {
+	Token     string
+	ExpiresIn time.Duration
+}
+
+// LoginResult - итог входа по паролю: либо пара токенов, либо, если включена
+// двухфакторная аутентификация, Challenge для второго шага.
+type LoginResult struct {
+	Tokens    *TokenPair
+	Challenge *TwoFactorChallenge
+}
@@ -2,10 +2,59 @@ package models
 
 import "time"
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 type User struct {
-	ID        int64     `json:"id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int64      `json:"id"`
+	Username  string     `json:"username"`
+	Email     string     `json:"email,omitempty"`
+	Password  string     `json:"-"`
+	Role      string     `json:"role"`
+	BannedAt  *time.Time `json:"banned_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	// EmailVerifiedAt - когда пользователь подтвердил текущий адрес почты.
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// TOTPEnabledAt - когда была включена двухфакторная аутентификация.
+	TOTPEnabledAt *time.Time `json:"-"`
+
+	// Поля профиля, которые пользователь заполняет сам; пустая строка - не указано.
+	DisplayName string `json:"display_name,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+	Phone       string `json:"phone,omitempty"`
+	Bio         string `json:"bio,omitempty"`
+	Language    string `json:"language,omitempty"`
+}
+
+// PublicProfile - данные продавца, доступные всем посетителям.
+type PublicProfile struct {
+	ID            int64     `json:"id"`
+	Username      string    `json:"username"`
+	DisplayName   string    `json:"display_name,omitempty"`
+	AvatarURL     string    `json:"avatar_url,omitempty"`
+	Bio           string    `json:"bio,omitempty"`
+	MemberSince   time.Time `json:"member_since"`
+	ActiveAdCount int       `json:"active_ad_count"`
+}
+
+// Actor описывает пользователя, от имени которого выполняется запрос.
+// Нулевое значение соответствует анонимному посетителю.
+type Actor struct {
+	UserID int64
+	Role   string
+}
+
+// Can сообщает, есть ли у пользователя разрешение.
+func (a Actor) Can(perm Permission) bool {
+	return HasPermission(a.Role, perm)
+}
+
+// CanEditAd сообщает, может ли пользователь изменять объявление: свое - всегда,
+// чужое - при наличии разрешения PermissionEditAnyAd.
+func (a Actor) CanEditAd(ad *Ad) bool {
+	return (a.UserID != 0 && ad.UserID == a.UserID) || a.Can(PermissionEditAnyAd)
 }
@@ -7,7 +7,7 @@ import (
 	"marketplace/internal/models"
 	"marketplace/internal/repository/postgres"
 	"marketplace/pkg/cache"
-	// "time"
+	"time"
 
 	// "github.com/redis/go-redis/v9"
 )
@@ -26,14 +26,21 @@ func NewAdRepository(postgresRepo postgres.AdRepository, cache *cache.CacheClien
 	}
 }
 
+// adListCachePattern совпадает с ключами всех страниц списка объявлений.
+const adListCachePattern = "ads:page=*"
+
 // adListCacheKey генерирует уникальный ключ для кеша списка объявлений.
 func adListCacheKey(params postgres.GetAllAdsParams) string {
 	page := params.Offset/params.Limit + 1
-	return fmt.Sprintf("ads:page=%d&limit=%d&sort_by=%s&sort_order=%s",
+	return fmt.Sprintf("ads:page=%d&limit=%d&sort_by=%s&sort_order=%s&q=%s&tag=%s&promoted=%t&user=%d",
 		page,
 		params.Limit,
 		params.SortBy,
 		params.SortOrder,
+		params.Search,
+		params.Tag,
+		params.PromotedOnly,
+		params.UserID,
 	)
 }
 
@@ -93,4 +100,64 @@ func (r *AdRepository) DeleteAd(ctx context.Context, id, userID int64) error {
 // В будущем можно добавить кеширование для отдельных объявлений здесь.
 func (r *AdRepository) GetAdByID(ctx context.Context, id int64) (*models.Ad, error) {
 	return r.postgresRepo.GetAdByID(ctx, id)
-}
\ No newline at end of file
+}
+
+// HideAd проксирует вызов к основному репозиторию.
+func (r *AdRepository) HideAd(ctx context.Context, id int64) error {
+	return r.postgresRepo.HideAd(ctx, id)
+}
+
+// HasRecentDuplicate проксирует вызов к основному репозиторию: проверка повторов
+// должна видеть только что созданные объявления.
+func (r *AdRepository) HasRecentDuplicate(ctx context.Context, q postgres.DuplicateQuery) (bool, error) {
+	return r.postgresRepo.HasRecentDuplicate(ctx, q)
+}
+
+// GetSimilarAdPairs проксирует вызов к основному репозиторию.
+func (r *AdRepository) GetSimilarAdPairs(ctx context.Context, match postgres.DuplicateMatch) ([]postgres.SimilarPair, error) {
+	return r.postgresRepo.GetSimilarAdPairs(ctx, match)
+}
+
+// PublishScheduled публикует отложенные объявления и, если что-то опубликовано,
+// сбрасывает закешированные страницы списка, чтобы новые объявления появились сразу.
+func (r *AdRepository) PublishScheduled(ctx context.Context, now time.Time) ([]models.Ad, error) {
+	ads, err := r.postgresRepo.PublishScheduled(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(ads) > 0 {
+		if err := r.invalidateAdLists(ctx); err != nil {
+			return ads, err
+		}
+	}
+	return ads, nil
+}
+
+// invalidateAdLists удаляет все закешированные страницы списка объявлений.
+func (r *AdRepository) invalidateAdLists(ctx context.Context) error {
+	iter := r.cache.Client.Scan(ctx, 0, adListCachePattern, 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("cache.invalidateAdLists: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := r.cache.Client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("cache.invalidateAdLists: %w", err)
+	}
+	return nil
+}
+
+// CountAds проксирует вызов к основному репозиторию.
+func (r *AdRepository) CountAds(ctx context.Context, params postgres.GetAllAdsParams) (int, error) {
+	return r.postgresRepo.CountAds(ctx, params)
+}
+
+// GetAdsByUserID проксирует вызов к основному репозиторию.
+func (r *AdRepository) GetAdsByUserID(ctx context.Context, userID int64) ([]models.Ad, error) {
+	return r.postgresRepo.GetAdsByUserID(ctx, userID)
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/pkg/cache"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrChallengeNotFound = errors.New("two-factor challenge not found")
+
+const (
+	challengeKeyPrefix         = "auth:2fa:challenge:"
+	challengeAttemptsKeyPrefix = "auth:2fa:attempts:"
+)
+
+type challengeRepository struct {
+	cache *cache.CacheClient
+}
+
+// NewChallengeRepository создает хранилище токенов второго шага входа.
+// Токены хранятся по хешу и удаляются по истечении срока автоматически.
+func NewChallengeRepository(cache *cache.CacheClient) ChallengeRepository {
+	return &challengeRepository{cache: cache}
+}
+
+func (r *challengeRepository) CreateChallenge(ctx context.Context, hash string, userID int64, ttl time.Duration) error {
+	if err := r.cache.Client.Set(ctx, challengeKeyPrefix+hash, userID, ttl).Err(); err != nil {
+		return fmt.Errorf("repository.CreateChallenge: %w", err)
+	}
+	return nil
+}
+
+// TakeChallenge атомарно извлекает токен: возвращает пользователя и оставшийся
+// срок токена и удаляет его, поэтому из параллельных запросов с одним токеном
+// его получает только один. Счетчик попыток при этом сохраняется.
+func (r *challengeRepository) TakeChallenge(ctx context.Context, hash string) (int64, time.Duration, error) {
+	const op = "repository.TakeChallenge"
+	key := challengeKeyPrefix + hash
+
+	var get *redis.StringCmd
+	var ttl *redis.DurationCmd
+	_, err := r.cache.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		ttl = pipe.PTTL(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, 0, ErrChallengeNotFound
+		}
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err := get.Int64()
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	// Токены создаются только со сроком; бессрочный ключ не принимается.
+	if ttl.Val() <= 0 {
+		return 0, 0, ErrChallengeNotFound
+	}
+	return userID, ttl.Val(), nil
+}
+
+// IncrChallengeAttempts увеличивает счетчик попыток ввести код для токена.
+// Счетчик живет не дольше самого токена.
+func (r *challengeRepository) IncrChallengeAttempts(ctx context.Context, hash string, ttl time.Duration) (int64, error) {
+	key := challengeAttemptsKeyPrefix + hash
+
+	pipe := r.cache.Client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("repository.IncrChallengeAttempts: %w", err)
+	}
+	return incr.Val(), nil
+}
+
+func (r *challengeRepository) DeleteChallenge(ctx context.Context, hash string) error {
+	if err := r.cache.Client.Del(ctx, challengeKeyPrefix+hash, challengeAttemptsKeyPrefix+hash).Err(); err != nil {
+		return fmt.Errorf("repository.DeleteChallenge: %w", err)
+	}
+	return nil
+}
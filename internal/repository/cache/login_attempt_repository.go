@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"marketplace/pkg/cache"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	loginFailuresKeyPrefix = "auth:login:failures:"
+	loginLockKeyPrefix     = "auth:login:lock:"
+)
+
+type loginAttemptRepository struct {
+	cache *cache.CacheClient
+}
+
+// NewLoginAttemptRepository создает хранилище счетчиков неудачных попыток входа
+// и временных блокировок. Ключом служит имя пользователя или IP-адрес.
+func NewLoginAttemptRepository(cache *cache.CacheClient) LoginAttemptRepository {
+	return &loginAttemptRepository{cache: cache}
+}
+
+// LockedFor возвращает, сколько еще действует блокировка ключа; ноль - блокировки нет.
+func (r *loginAttemptRepository) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.cache.Client.PTTL(ctx, loginLockKeyPrefix+key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("repository.LockedFor: %w", err)
+	}
+	// PTTL возвращает отрицательное значение для отсутствующего ключа.
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// RegisterFailure учитывает неудачную попытку и возвращает их число за окно window.
+// Окно отсчитывается от первой неудачи.
+func (r *loginAttemptRepository) RegisterFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	redisKey := loginFailuresKeyPrefix + key
+
+	var incr *redis.IntCmd
+	_, err := r.cache.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, redisKey)
+		pipe.ExpireNX(ctx, redisKey, window)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("repository.RegisterFailure: %w", err)
+	}
+	return incr.Val(), nil
+}
+
+func (r *loginAttemptRepository) Lock(ctx context.Context, key string, d time.Duration) error {
+	if err := r.cache.Client.Set(ctx, loginLockKeyPrefix+key, 1, d).Err(); err != nil {
+		return fmt.Errorf("repository.Lock: %w", err)
+	}
+	return nil
+}
+
+// Reset сбрасывает счетчик неудач ключа после успешного входа.
+func (r *loginAttemptRepository) Reset(ctx context.Context, key string) error {
+	if err := r.cache.Client.Del(ctx, loginFailuresKeyPrefix+key, loginLockKeyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("repository.Reset: %w", err)
+	}
+	return nil
+}
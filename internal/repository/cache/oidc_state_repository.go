@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+	"marketplace/pkg/cache"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrOIDCStateNotFound = errors.New("oidc state not found")
+
+const oidcStateKeyPrefix = "auth:oidc:state:"
+
+type oidcStateRepository struct {
+	cache *cache.CacheClient
+}
+
+// NewOIDCStateRepository создает хранилище незавершенных попыток входа через
+// провайдеров OpenID Connect. Попытки хранятся по хешу state.
+func NewOIDCStateRepository(cache *cache.CacheClient) OIDCStateRepository {
+	return &oidcStateRepository{cache: cache}
+}
+
+func (r *oidcStateRepository) SaveState(ctx context.Context, hash string, state *models.OIDCState, ttl time.Duration) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("repository.SaveState: %w", err)
+	}
+	if err := r.cache.Client.Set(ctx, oidcStateKeyPrefix+hash, data, ttl).Err(); err != nil {
+		return fmt.Errorf("repository.SaveState: %w", err)
+	}
+	return nil
+}
+
+// TakeState возвращает и сразу удаляет попытку входа: state действует один раз.
+func (r *oidcStateRepository) TakeState(ctx context.Context, hash string) (*models.OIDCState, error) {
+	data, err := r.cache.Client.GetDel(ctx, oidcStateKeyPrefix+hash).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrOIDCStateNotFound
+		}
+		return nil, fmt.Errorf("repository.TakeState: %w", err)
+	}
+
+	var state models.OIDCState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("repository.TakeState: %w", err)
+	}
+	return &state, nil
+}
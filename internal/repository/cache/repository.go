@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"marketplace/internal/models"
+	"marketplace/pkg/cache"
+	"time"
+)
+
+// SuggestRepository хранит префиксный индекс заголовков объявлений и популярных поисковых запросов.
+type SuggestRepository interface {
+	IndexTitle(ctx context.Context, title string) error
+	RemoveTitle(ctx context.Context, title string) error
+	RecordQuery(ctx context.Context, client, query string) error
+	SuggestTitles(ctx context.Context, prefix string, limit int) ([]string, error)
+	SuggestQueries(ctx context.Context, prefix string, limit int) ([]string, error)
+}
+
+// TokenRepository хранит сведения об отозванных access-токенах.
+type TokenRepository interface {
+	DenyToken(ctx context.Context, jti string, ttl time.Duration) error
+	IsTokenDenied(ctx context.Context, jti string) (bool, error)
+	TokenGeneration(ctx context.Context, userID int64) (int64, error)
+	IncrTokenGeneration(ctx context.Context, userID int64) (int64, error)
+	DenySession(ctx context.Context, sessionID string, ttl time.Duration) error
+	IsSessionDenied(ctx context.Context, sessionID string) (bool, error)
+}
+
+// ChallengeRepository хранит токены второго шага входа и счетчики попыток ввода кода.
+type ChallengeRepository interface {
+	CreateChallenge(ctx context.Context, hash string, userID int64, ttl time.Duration) error
+	TakeChallenge(ctx context.Context, hash string) (int64, time.Duration, error)
+	IncrChallengeAttempts(ctx context.Context, hash string, ttl time.Duration) (int64, error)
+	DeleteChallenge(ctx context.Context, hash string) error
+}
+
+// LoginAttemptRepository хранит счетчики неудачных попыток входа и блокировки.
+type LoginAttemptRepository interface {
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	RegisterFailure(ctx context.Context, key string, window time.Duration) (int64, error)
+	Lock(ctx context.Context, key string, d time.Duration) error
+	Reset(ctx context.Context, key string) error
+}
+
+// OIDCStateRepository хранит попытки входа через провайдеров OpenID Connect
+// до возврата пользователя от провайдера.
+type OIDCStateRepository interface {
+	SaveState(ctx context.Context, hash string, state *models.OIDCState, ttl time.Duration) error
+	TakeState(ctx context.Context, hash string) (*models.OIDCState, error)
+}
+
+// Repository объединяет хранилища, работающие поверх Redis.
+type Repository struct {
+	Suggest      SuggestRepository
+	Token        TokenRepository
+	Challenge    ChallengeRepository
+	LoginAttempt LoginAttemptRepository
+	OIDCState    OIDCStateRepository
+}
+
+func NewRepository(client *cache.CacheClient) *Repository {
+	return &Repository{
+		Suggest:      NewSuggestRepository(client),
+		Token:        NewTokenRepository(client),
+		Challenge:    NewChallengeRepository(client),
+		LoginAttempt: NewLoginAttemptRepository(client),
+		OIDCState:    NewOIDCStateRepository(client),
+	}
+}
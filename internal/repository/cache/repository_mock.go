@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"context"
+	"marketplace/internal/models"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSuggestRepository является мок-реализацией SuggestRepository.
+type MockSuggestRepository struct {
+	mock.Mock
+}
+
+// IndexTitle симулирует добавление заголовка в индекс.
+func (m *MockSuggestRepository) IndexTitle(ctx context.Context, title string) error {
+	args := m.Called(ctx, title)
+	return args.Error(0)
+}
+
+// RemoveTitle симулирует удаление заголовка из индекса.
+func (m *MockSuggestRepository) RemoveTitle(ctx context.Context, title string) error {
+	args := m.Called(ctx, title)
+	return args.Error(0)
+}
+
+// RecordQuery симулирует учет поискового запроса.
+func (m *MockSuggestRepository) RecordQuery(ctx context.Context, client, query string) error {
+	args := m.Called(ctx, client, query)
+	return args.Error(0)
+}
+
+// SuggestTitles симулирует получение подсказок по заголовкам.
+func (m *MockSuggestRepository) SuggestTitles(ctx context.Context, prefix string, limit int) ([]string, error) {
+	args := m.Called(ctx, prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// SuggestQueries симулирует получение популярных запросов.
+func (m *MockSuggestRepository) SuggestQueries(ctx context.Context, prefix string, limit int) ([]string, error) {
+	args := m.Called(ctx, prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// MockTokenRepository является мок-реализацией TokenRepository.
+type MockTokenRepository struct {
+	mock.Mock
+}
+
+// DenyToken симулирует добавление токена в denylist.
+func (m *MockTokenRepository) DenyToken(ctx context.Context, jti string, ttl time.Duration) error {
+	args := m.Called(ctx, jti, ttl)
+	return args.Error(0)
+}
+
+// IsTokenDenied симулирует проверку токена по denylist.
+func (m *MockTokenRepository) IsTokenDenied(ctx context.Context, jti string) (bool, error) {
+	args := m.Called(ctx, jti)
+	return args.Bool(0), args.Error(1)
+}
+
+// TokenGeneration симулирует получение поколения токенов пользователя.
+func (m *MockTokenRepository) TokenGeneration(ctx context.Context, userID int64) (int64, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// IncrTokenGeneration симулирует увеличение поколения токенов пользователя.
+func (m *MockTokenRepository) IncrTokenGeneration(ctx context.Context, userID int64) (int64, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// DenySession симулирует отзыв access-токенов сессии.
+func (m *MockTokenRepository) DenySession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	args := m.Called(ctx, sessionID, ttl)
+	return args.Error(0)
+}
+
+// IsSessionDenied симулирует проверку сессии по denylist.
+func (m *MockTokenRepository) IsSessionDenied(ctx context.Context, sessionID string) (bool, error) {
+	args := m.Called(ctx, sessionID)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockChallengeRepository является мок-реализацией ChallengeRepository.
+type MockChallengeRepository struct {
+	mock.Mock
+}
+
+// CreateChallenge симулирует сохранение токена второго шага входа.
+func (m *MockChallengeRepository) CreateChallenge(ctx context.Context, hash string, userID int64, ttl time.Duration) error {
+	args := m.Called(ctx, hash, userID, ttl)
+	return args.Error(0)
+}
+
+// TakeChallenge симулирует извлечение токена второго шага.
+func (m *MockChallengeRepository) TakeChallenge(ctx context.Context, hash string) (int64, time.Duration, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(int64), args.Get(1).(time.Duration), args.Error(2)
+}
+
+// IncrChallengeAttempts симулирует учет попытки ввода кода.
+func (m *MockChallengeRepository) IncrChallengeAttempts(ctx context.Context, hash string, ttl time.Duration) (int64, error) {
+	args := m.Called(ctx, hash, ttl)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// DeleteChallenge симулирует удаление токена второго шага.
+func (m *MockChallengeRepository) DeleteChallenge(ctx context.Context, hash string) error {
+	args := m.Called(ctx, hash)
+	return args.Error(0)
+}
+
+// MockLoginAttemptRepository является мок-реализацией LoginAttemptRepository.
+type MockLoginAttemptRepository struct {
+	mock.Mock
+}
+
+// LockedFor симулирует проверку блокировки входа.
+func (m *MockLoginAttemptRepository) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(time.Duration), args.Error(1)
+}
+
+// RegisterFailure симулирует учет неудачной попытки входа.
+func (m *MockLoginAttemptRepository) RegisterFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	args := m.Called(ctx, key, window)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// Lock симулирует временную блокировку входа.
+func (m *MockLoginAttemptRepository) Lock(ctx context.Context, key string, d time.Duration) error {
+	args := m.Called(ctx, key, d)
+	return args.Error(0)
+}
+
+// Reset симулирует сброс счетчика после успешного входа.
+func (m *MockLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+// MockOIDCStateRepository является мок-реализацией OIDCStateRepository.
+type MockOIDCStateRepository struct {
+	mock.Mock
+}
+
+// SaveState симулирует сохранение попытки входа через провайдера.
+func (m *MockOIDCStateRepository) SaveState(ctx context.Context, hash string, state *models.OIDCState, ttl time.Duration) error {
+	args := m.Called(ctx, hash, state, ttl)
+	return args.Error(0)
+}
+
+// TakeState симулирует однократное получение попытки входа.
+func (m *MockOIDCStateRepository) TakeState(ctx context.Context, hash string) (*models.OIDCState, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.OIDCState), args.Error(1)
+}
@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"marketplace/pkg/cache"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	titlesKeyPrefix    = "suggest:titles:"
+	queriesKeyPrefix   = "suggest:queries:"
+	querySeenKeyPrefix = "suggest:seen:"
+
+	// maxPrefixLen ограничивает длину индексируемых префиксов, чтобы
+	// длинные заголовки не порождали лишние ключи.
+	maxPrefixLen = 20
+
+	// maxQueriesPerPrefix - сколько самых частых запросов хранится для префикса.
+	maxQueriesPerPrefix = 100
+	// queriesTTL - через сколько удаляется множество префикса, по которому давно не искали.
+	queriesTTL = 30 * 24 * time.Hour
+	// queryDedupWindow - в течение этого времени повтор запроса одним клиентом не учитывается.
+	queryDedupWindow = 24 * time.Hour
+	// minQueryCount - сколько раз запрос должен встретиться, чтобы попасть в подсказки.
+	minQueryCount = 3
+)
+
+type suggestRepository struct {
+	cache *cache.CacheClient
+}
+
+// NewSuggestRepository создает префиксный индекс на отсортированных множествах Redis.
+// Для каждого префикса хранится ZSET, где элемент - полная строка, а счет - частота.
+func NewSuggestRepository(cache *cache.CacheClient) SuggestRepository {
+	return &suggestRepository{cache: cache}
+}
+
+// NormalizeSuggestion приводит строку к виду, в котором она хранится в индексе.
+func NormalizeSuggestion(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// prefixes возвращает все префиксы строки длиной до maxPrefixLen символов.
+func prefixes(s string) []string {
+	runes := []rune(s)
+	n := min(len(runes), maxPrefixLen)
+	result := make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		result = append(result, string(runes[:i]))
+	}
+	return result
+}
+
+func (r *suggestRepository) IndexTitle(ctx context.Context, title string) error {
+	if err := r.increment(ctx, titlesKeyPrefix, title, 1); err != nil {
+		return fmt.Errorf("repository.IndexTitle: %w", err)
+	}
+	return nil
+}
+
+func (r *suggestRepository) RemoveTitle(ctx context.Context, title string) error {
+	if err := r.increment(ctx, titlesKeyPrefix, title, -1); err != nil {
+		return fmt.Errorf("repository.RemoveTitle: %w", err)
+	}
+	return nil
+}
+
+// RecordQuery учитывает поисковый запрос клиента. Повтор запроса тем же
+// клиентом в течение queryDedupWindow не учитывается, чтобы один клиент не мог
+// накрутить популярность. Для каждого префикса хранятся только самые частые
+// запросы, а множества префиксов, по которым давно не искали, истекают.
+func (r *suggestRepository) RecordQuery(ctx context.Context, client, query string) error {
+	const op = "repository.RecordQuery"
+
+	normalized := NormalizeSuggestion(query)
+	if normalized == "" {
+		return nil
+	}
+
+	first, err := r.cache.Client.SetNX(ctx, querySeenKeyPrefix+client+":"+normalized, 1, queryDedupWindow).Result()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !first {
+		return nil
+	}
+
+	_, err = r.cache.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, p := range prefixes(normalized) {
+			key := queriesKeyPrefix + p
+			pipe.ZIncrBy(ctx, key, 1, normalized)
+			pipe.ZRemRangeByRank(ctx, key, 0, -(maxQueriesPerPrefix + 1))
+			pipe.Expire(ctx, key, queriesTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *suggestRepository) SuggestTitles(ctx context.Context, prefix string, limit int) ([]string, error) {
+	titles, err := r.top(ctx, titlesKeyPrefix, prefix, limit, 1)
+	if err != nil {
+		return nil, fmt.Errorf("repository.SuggestTitles: %w", err)
+	}
+	return titles, nil
+}
+
+func (r *suggestRepository) SuggestQueries(ctx context.Context, prefix string, limit int) ([]string, error) {
+	queries, err := r.top(ctx, queriesKeyPrefix, prefix, limit, minQueryCount)
+	if err != nil {
+		return nil, fmt.Errorf("repository.SuggestQueries: %w", err)
+	}
+	return queries, nil
+}
+
+// increment меняет счет строки во всех префиксных множествах и удаляет
+// элементы, счет которых опустился до нуля.
+func (r *suggestRepository) increment(ctx context.Context, keyPrefix, value string, delta float64) error {
+	normalized := NormalizeSuggestion(value)
+	if normalized == "" {
+		return nil
+	}
+
+	_, err := r.cache.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, p := range prefixes(normalized) {
+			key := keyPrefix + p
+			pipe.ZIncrBy(ctx, key, delta, normalized)
+			if delta < 0 {
+				pipe.ZRemRangeByScore(ctx, key, "-inf", "0")
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// top возвращает до limit самых частых строк с префиксом prefix, встретившихся
+// не меньше minCount раз.
+func (r *suggestRepository) top(ctx context.Context, keyPrefix, prefix string, limit, minCount int) ([]string, error) {
+	normalized := NormalizeSuggestion(prefix)
+	if normalized == "" {
+		return []string{}, nil
+	}
+	byScore := &redis.ZRangeBy{Min: strconv.Itoa(minCount), Max: "+inf"}
+	runes := []rune(normalized)
+	if len(runes) <= maxPrefixLen {
+		byScore.Count = int64(limit)
+		return r.cache.Client.ZRevRangeByScore(ctx, keyPrefix+normalized, byScore).Result()
+	}
+
+	// Префиксы длиннее индексируемых ищем по обрезанному ключу и доотфильтровываем.
+	values, err := r.cache.Client.ZRevRangeByScore(ctx, keyPrefix+string(runes[:maxPrefixLen]), byScore).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, limit)
+	for _, v := range values {
+		if len(result) == limit {
+			break
+		}
+		if strings.HasPrefix(v, normalized) {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/pkg/cache"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	denylistKeyPrefix    = "auth:denylist:"
+	generationKeyPrefix  = "auth:generation:"
+	sessionDenyKeyPrefix = "auth:denylist:session:"
+)
+
+type tokenRepository struct {
+	cache *cache.CacheClient
+}
+
+// NewTokenRepository создает хранилище отозванных access-токенов.
+// Отдельные токены попадают в denylist по jti до истечения их срока,
+// а выход на всех устройствах увеличивает счетчик поколения токенов пользователя.
+func NewTokenRepository(cache *cache.CacheClient) TokenRepository {
+	return &tokenRepository{cache: cache}
+}
+
+func (r *tokenRepository) DenyToken(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	if err := r.cache.Client.Set(ctx, denylistKeyPrefix+jti, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("repository.DenyToken: %w", err)
+	}
+	return nil
+}
+
+func (r *tokenRepository) IsTokenDenied(ctx context.Context, jti string) (bool, error) {
+	n, err := r.cache.Client.Exists(ctx, denylistKeyPrefix+jti).Result()
+	if err != nil {
+		return false, fmt.Errorf("repository.IsTokenDenied: %w", err)
+	}
+	return n > 0, nil
+}
+
+// TokenGeneration возвращает текущее поколение токенов пользователя;
+// пока пользователь ни разу не выходил на всех устройствах, оно равно нулю.
+func (r *tokenRepository) TokenGeneration(ctx context.Context, userID int64) (int64, error) {
+	gen, err := r.cache.Client.Get(ctx, generationKey(userID)).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("repository.TokenGeneration: %w", err)
+	}
+	return gen, nil
+}
+
+func (r *tokenRepository) IncrTokenGeneration(ctx context.Context, userID int64) (int64, error) {
+	gen, err := r.cache.Client.Incr(ctx, generationKey(userID)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("repository.IncrTokenGeneration: %w", err)
+	}
+	return gen, nil
+}
+
+// DenySession запрещает access-токены отозванной сессии. ttl должен быть не
+// меньше срока жизни access-токена: после него выданные токены истекут сами.
+func (r *tokenRepository) DenySession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	if err := r.cache.Client.Set(ctx, sessionDenyKeyPrefix+sessionID, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("repository.DenySession: %w", err)
+	}
+	return nil
+}
+
+func (r *tokenRepository) IsSessionDenied(ctx context.Context, sessionID string) (bool, error) {
+	n, err := r.cache.Client.Exists(ctx, sessionDenyKeyPrefix+sessionID).Result()
+	if err != nil {
+		return false, fmt.Errorf("repository.IsSessionDenied: %w", err)
+	}
+	return n > 0, nil
+}
+
+func generationKey(userID int64) string {
+	return generationKeyPrefix + strconv.FormatInt(userID, 10)
+}
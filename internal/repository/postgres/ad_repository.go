@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"marketplace/internal/models"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -19,6 +21,13 @@ var (
 		"created_at": {},
 		"price":      {},
 	}
+
+	// adTagsColumn выбирает теги объявления одним массивом, отсортированным по имени.
+	adTagsColumn = fmt.Sprintf(`ARRAY(SELECT t.name FROM %s at JOIN %s t ON t.id = at.tag_id
+		WHERE at.ad_id = %s.id ORDER BY t.name) AS tags`, adTagsTable, tagsTable, adsTable)
+
+	// likeEscaper экранирует спецсимволы шаблона LIKE в пользовательском вводе.
+	likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
 )
 
 type adRepository struct {
@@ -30,12 +39,42 @@ func NewAdRepository(db *pgxpool.Pool) AdRepository {
 }
 
 func (r *adRepository) CreateAd(ctx context.Context, ad *models.Ad) (int64, error) {
-	query := fmt.Sprintf(`INSERT INTO %s (user_id, title, description, price, image_url) 
-	          						VALUES ($1, $2, $3, $4, $5) RETURNING id`, adsTable)
+	const op = "repository.CreateAd"
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Новое объявление скрывает только автоматическая проверка.
+	var hiddenBy string
+	if ad.HiddenAt != nil {
+		hiddenBy = models.HiddenByScreening
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, title, description, price, image_url, hidden_at, hidden_by, normalized_text, publish_at) 
+	          						VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''), NULLIF($8, ''), $9) RETURNING id`, adsTable)
 	var id int64
-	err := r.db.QueryRow(ctx, query, ad.UserID, ad.Title, ad.Description, ad.Price, ad.ImageURL).Scan(&id)
+	err = tx.QueryRow(ctx, query,
+		ad.UserID, ad.Title, ad.Description, ad.Price, ad.ImageURL, ad.HiddenAt, hiddenBy, ad.NormalizedText, ad.PublishAt,
+	).Scan(&id)
 	if err != nil {
-		return 0, fmt.Errorf("repository.CreateAd: %w", err)
+		return 0, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	if err := setAdTags(ctx, tx, id, ad.Tags); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if ad.ScreeningNote != "" {
+		if err := queueForReview(ctx, tx, id, ad.ScreeningNote); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 	return id, nil
 }
@@ -45,15 +84,24 @@ type GetAllAdsParams struct {
 	Offset    int
 	SortBy    string
 	SortOrder string
+	Search    string
+	Tag       string
+	// PromotedOnly ограничивает выборку объявлениями с действующим продвижением.
+	PromotedOnly bool
+	// UserID ограничивает выборку объявлениями одного продавца.
+	UserID int64
 }
 
 func (r adRepository) GetAllAds(ctx context.Context, params GetAllAdsParams) ([]models.Ad, error) {
-	baseQuery := fmt.Sprintf(`SELECT id, user_id, title, description, price, image_url, created_at 
-														FROM %s`, adsTable)
+	baseQuery := fmt.Sprintf(`SELECT id, user_id, title, description, price, COALESCE(image_url, ''), created_at, %s 
+														FROM %s`, adTagsColumn, adsTable)
 
 	var queryBuilder strings.Builder
 	queryBuilder.WriteString(baseQuery)
 
+	where, args := buildAdFilters(params, []any{params.Limit, params.Offset})
+	queryBuilder.WriteString(where)
+
 	if _, ok := allowedSortBy[params.SortBy]; ok {
 		queryBuilder.WriteString(fmt.Sprintf(" ORDER BY %s", params.SortBy))
 
@@ -70,7 +118,7 @@ func (r adRepository) GetAllAds(ctx context.Context, params GetAllAdsParams) ([]
 
 	finalQuery := queryBuilder.String()
 
-	rows, err := r.db.Query(ctx, finalQuery, params.Limit, params.Offset)
+	rows, err := r.db.Query(ctx, finalQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("repository.GetAllAds: query error: %w", err)
 	}
@@ -79,7 +127,7 @@ func (r adRepository) GetAllAds(ctx context.Context, params GetAllAdsParams) ([]
 	var ads []models.Ad
 	for rows.Next() {
 		var ad models.Ad
-		if err := rows.Scan(&ad.ID, &ad.UserID, &ad.Title, &ad.Description, &ad.Price, &ad.ImageURL, &ad.CreatedAt); err != nil {
+		if err := rows.Scan(&ad.ID, &ad.UserID, &ad.Title, &ad.Description, &ad.Price, &ad.ImageURL, &ad.CreatedAt, &ad.Tags); err != nil {
 			return nil, fmt.Errorf("repository.GetAllAds: row scan error: %w", err)
 		}
 		ads = append(ads, ad)
@@ -92,12 +140,55 @@ func (r adRepository) GetAllAds(ctx context.Context, params GetAllAdsParams) ([]
 	return ads, nil
 }
 
+// CountAds возвращает число видимых объявлений, подходящих под фильтры params.
+// Пагинация и сортировка игнорируются.
+func (r *adRepository) CountAds(ctx context.Context, params GetAllAdsParams) (int, error) {
+	where, args := buildAdFilters(params, nil)
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s%s`, adsTable, where)
+
+	var count int
+	if err := r.db.QueryRow(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("repository.CountAds: %w", err)
+	}
+	return count, nil
+}
+
+// GetAdsByUserID возвращает все объявления пользователя, включая скрытые и
+// ожидающие публикации.
+func (r *adRepository) GetAdsByUserID(ctx context.Context, userID int64) ([]models.Ad, error) {
+	query := fmt.Sprintf(`SELECT id, user_id, title, description, price, COALESCE(image_url, ''), created_at, updated_at, hidden_at, COALESCE(hidden_by, ''), publish_at, %s 
+												FROM %s WHERE user_id = $1 ORDER BY created_at`, adTagsColumn, adsTable)
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetAdsByUserID: query error: %w", err)
+	}
+	defer rows.Close()
+
+	ads := []models.Ad{}
+	for rows.Next() {
+		var ad models.Ad
+		if err := rows.Scan(
+			&ad.ID, &ad.UserID, &ad.Title, &ad.Description, &ad.Price, &ad.ImageURL, &ad.CreatedAt, &ad.UpdatedAt, &ad.HiddenAt, &ad.HiddenBy, &ad.PublishAt, &ad.Tags,
+		); err != nil {
+			return nil, fmt.Errorf("repository.GetAdsByUserID: row scan error: %w", err)
+		}
+		ads = append(ads, ad)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.GetAdsByUserID: %w", err)
+	}
+
+	return ads, nil
+}
+
 func (r *adRepository) GetAdByID(ctx context.Context, id int64) (*models.Ad, error) {
-	query := fmt.Sprintf(`SELECT id, user_id, title, description, price, image_url, created_at, updated_at 
-												FROM %s WHERE id = $1`, adsTable)
+	query := fmt.Sprintf(`SELECT id, user_id, title, description, price, COALESCE(image_url, ''), created_at, updated_at, hidden_at, COALESCE(hidden_by, ''), publish_at, %s 
+												FROM %s WHERE id = $1`, adTagsColumn, adsTable)
 	var ad models.Ad
 	err := r.db.QueryRow(ctx, query, id).Scan(
-		&ad.ID, &ad.UserID, &ad.Title, &ad.Description, &ad.Price, &ad.ImageURL, &ad.CreatedAt, &ad.UpdatedAt,
+		&ad.ID, &ad.UserID, &ad.Title, &ad.Description, &ad.Price, &ad.ImageURL, &ad.CreatedAt, &ad.UpdatedAt, &ad.HiddenAt, &ad.HiddenBy, &ad.PublishAt, &ad.Tags,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -109,16 +200,50 @@ func (r *adRepository) GetAdByID(ctx context.Context, id int64) (*models.Ad, err
 }
 
 func (r *adRepository) UpdateAd(ctx context.Context, ad *models.Ad) error {
-	query := fmt.Sprintf(`UPDATE %s SET title = $1, description = $2, price = $3, updated_at = NOW()
+	const op = "repository.UpdateAd"
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf(`UPDATE %s SET title = $1, description = $2, price = $3, normalized_text = NULLIF($6, ''),
+												image_url = NULLIF($7, ''), publish_at = $8, updated_at = NOW()
 												WHERE id = $4 AND user_id = $5`, adsTable)
 
-	res, err := r.db.Exec(ctx, query, ad.Title, ad.Description, ad.Price, ad.ID, ad.UserID)
+	res, err := tx.Exec(ctx, query, ad.Title, ad.Description, ad.Price, ad.ID, ad.UserID, ad.NormalizedText, ad.ImageURL, ad.PublishAt)
 	if err != nil {
-		return fmt.Errorf("repository.UpdateAd: %w", err)
+		return fmt.Errorf("%s: %w", op, translateError(err))
 	}
 	if res.RowsAffected() == 0 {
 		return ErrAdAccessDenied
 	}
+
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE ad_id = $1`, adTagsTable)
+	if _, err := tx.Exec(ctx, deleteQuery, ad.ID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := setAdTags(ctx, tx, ad.ID, ad.Tags); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if ad.ScreeningNote != "" {
+		// Измененное объявление, требующее проверки, скрывается до решения модератора.
+		// Объявление, уже скрытое модератором, остается скрытым им.
+		hideQuery := fmt.Sprintf(`UPDATE %s SET hidden_at = COALESCE(hidden_at, NOW()), hidden_by = COALESCE(hidden_by, $2) 
+												WHERE id = $1`, adsTable)
+		if _, err := tx.Exec(ctx, hideQuery, ad.ID, models.HiddenByScreening); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := queueForReview(ctx, tx, ad.ID, ad.ScreeningNote); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 	return nil
 }
 
@@ -133,3 +258,192 @@ func (r *adRepository) DeleteAd(ctx context.Context, id, userID int64) error {
 	}
 	return nil
 }
+
+// HideAd скрывает объявление решением модератора. Уже скрытое объявление
+// остается скрытым с прежней даты, но отклонение жалоб его больше не опубликует.
+func (r *adRepository) HideAd(ctx context.Context, id int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET hidden_at = COALESCE(hidden_at, NOW()), hidden_by = $2 WHERE id = $1`, adsTable)
+	res, err := r.db.Exec(ctx, query, id, models.HiddenByModerator)
+	if err != nil {
+		return fmt.Errorf("repository.HideAd: %w", err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrAdNotFound
+	}
+	return nil
+}
+
+// PublishScheduled снимает отметку отложенной публикации с объявлений, время
+// которых наступило к моменту now, и возвращает опубликованные объявления.
+func (r *adRepository) PublishScheduled(ctx context.Context, now time.Time) ([]models.Ad, error) {
+	query := fmt.Sprintf(`UPDATE %s SET publish_at = NULL 
+												WHERE publish_at IS NOT NULL AND publish_at <= $1
+												RETURNING id, user_id, title, hidden_at`, adsTable)
+
+	rows, err := r.db.Query(ctx, query, now)
+	if err != nil {
+		return nil, fmt.Errorf("repository.PublishScheduled: %w", err)
+	}
+	defer rows.Close()
+
+	var ads []models.Ad
+	for rows.Next() {
+		var ad models.Ad
+		if err := rows.Scan(&ad.ID, &ad.UserID, &ad.Title, &ad.HiddenAt); err != nil {
+			return nil, fmt.Errorf("repository.PublishScheduled: %w", err)
+		}
+		ads = append(ads, ad)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.PublishScheduled: %w", err)
+	}
+	return ads, nil
+}
+
+// queueForReview ставит объявление в общую очередь модерации системной жалобой.
+func queueForReview(ctx context.Context, tx pgx.Tx, adID int64, note string) error {
+	query := fmt.Sprintf(`INSERT INTO %s (ad_id, reason, comment) VALUES ($1, $2, $3)`, reportsTable)
+	if _, err := tx.Exec(ctx, query, adID, models.ReportReasonAutoScreening, note); err != nil {
+		return fmt.Errorf("queue for review: %w", err)
+	}
+	return nil
+}
+
+// DuplicateMatch задает, насколько объявления должны совпадать, чтобы считаться повтором.
+type DuplicateMatch struct {
+	// Similarity - минимальное сходство нормализованного текста по триграммам, от 0 до 1.
+	Similarity float64
+	// PriceTolerance - допустимое расхождение цен в долях от большей цены.
+	PriceTolerance float64
+}
+
+// DuplicateQuery описывает поиск повтора среди недавних объявлений продавца.
+type DuplicateQuery struct {
+	DuplicateMatch
+	UserID int64
+	// ExcludeID исключает из сравнения само проверяемое объявление при изменении.
+	ExcludeID int64
+	Text      string
+	Price     float64
+	Since     time.Time
+}
+
+// SimilarAd - объявление из пары похожих.
+type SimilarAd struct {
+	ID        int64
+	UserID    int64
+	CreatedAt time.Time
+}
+
+// SimilarPair - пара похожих объявлений.
+type SimilarPair struct {
+	First  SimilarAd
+	Second SimilarAd
+}
+
+// HasRecentDuplicate проверяет, публиковал ли пользователь начиная с q.Since
+// объявление с похожим текстом и близкой ценой.
+func (r *adRepository) HasRecentDuplicate(ctx context.Context, q DuplicateQuery) (bool, error) {
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s 
+														WHERE user_id = $1 AND id <> $2 AND created_at >= $3
+															AND similarity(normalized_text, $4) >= $5
+															AND ABS(price - $6) <= $7 * GREATEST(price, $6))`, adsTable)
+	var exists bool
+	err := r.db.QueryRow(ctx, query,
+		q.UserID, q.ExcludeID, q.Since, q.Text, q.Similarity, q.Price, q.PriceTolerance,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("repository.HasRecentDuplicate: %w", err)
+	}
+	return exists, nil
+}
+
+// GetSimilarAdPairs возвращает все пары похожих объявлений. Кандидаты отбираются
+// оператором %% по GIN-индексу триграмм, поэтому порог сходства задается для
+// транзакции через pg_trgm.similarity_threshold.
+func (r *adRepository) GetSimilarAdPairs(ctx context.Context, match DuplicateMatch) ([]SimilarPair, error) {
+	const op = "repository.GetSimilarAdPairs"
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	threshold := strconv.FormatFloat(match.Similarity, 'f', -1, 64)
+	if _, err := tx.Exec(ctx, `SELECT set_config('pg_trgm.similarity_threshold', $1, true)`, threshold); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := fmt.Sprintf(`SELECT a.id, a.user_id, a.created_at, b.id, b.user_id, b.created_at
+												FROM %[1]s a
+												JOIN %[1]s b ON a.id < b.id AND a.normalized_text %% b.normalized_text
+												WHERE ABS(a.price - b.price) <= $1 * GREATEST(a.price, b.price)`, adsTable)
+
+	rows, err := tx.Query(ctx, query, match.PriceTolerance)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var pairs []SimilarPair
+	for rows.Next() {
+		var p SimilarPair
+		if err := rows.Scan(
+			&p.First.ID, &p.First.UserID, &p.First.CreatedAt,
+			&p.Second.ID, &p.Second.UserID, &p.Second.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		pairs = append(pairs, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return pairs, nil
+}
+
+// setAdTags создает недостающие теги и привязывает их к объявлению.
+func setAdTags(ctx context.Context, tx pgx.Tx, adID int64, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	insertTags := fmt.Sprintf(`INSERT INTO %s (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`, tagsTable)
+	if _, err := tx.Exec(ctx, insertTags, tags); err != nil {
+		return fmt.Errorf("insert tags: %w", translateError(err))
+	}
+
+	linkTags := fmt.Sprintf(`INSERT INTO %s (ad_id, tag_id) SELECT $1, id FROM %s WHERE name = ANY($2)`, adTagsTable, tagsTable)
+	if _, err := tx.Exec(ctx, linkTags, adID, tags); err != nil {
+		return fmt.Errorf("link tags: %w", translateError(err))
+	}
+	return nil
+}
+
+// buildAdFilters формирует условие WHERE для выборки объявлений,
+// дописывая значения параметров в args. Скрытые модератором объявления
+// в публичную выдачу не попадают.
+func buildAdFilters(params GetAllAdsParams, args []any) (string, []any) {
+	conditions := []string{"hidden_at IS NULL", "(publish_at IS NULL OR publish_at <= NOW())"}
+	if params.Search != "" {
+		args = append(args, likeEscaper.Replace(params.Search))
+		conditions = append(conditions, fmt.Sprintf(`title ILIKE '%%' || $%d || '%%'`, len(args)))
+	}
+	if params.Tag != "" {
+		args = append(args, params.Tag)
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (SELECT 1 FROM %s at JOIN %s t ON t.id = at.tag_id
+			WHERE at.ad_id = %s.id AND t.name = $%d)`, adTagsTable, tagsTable, adsTable, len(args)))
+	}
+	if params.UserID != 0 {
+		args = append(args, params.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	if params.PromotedOnly {
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (SELECT 1 FROM %s p
+			WHERE p.ad_id = %s.id AND p.revoked_at IS NULL AND p.starts_at <= NOW() AND p.ends_at > NOW())`,
+			promotionsTable, adsTable))
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
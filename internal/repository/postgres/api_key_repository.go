@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, last_used_at, revoked_at, created_at`
+
+// apiKeyTouchInterval - как часто обновляется время последнего использования ключа.
+// Скрипты синхронизации шлют много запросов подряд, писать в базу на каждый не нужно.
+const apiKeyTouchInterval = "1 minute"
+
+func scanAPIKey(row pgx.Row, key *models.APIKey) error {
+	return row.Scan(
+		&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash,
+		&key.Scopes, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt,
+	)
+}
+
+type apiKeyRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAPIKeyRepository(db *pgxpool.Pool) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) (int64, error) {
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, name, prefix, key_hash, scopes) 
+												VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`, apiKeysTable)
+	err := r.db.QueryRow(ctx, query, key.UserID, key.Name, key.Prefix, key.KeyHash, key.Scopes).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("repository.CreateAPIKey: %w", translateError(err))
+	}
+	return key.ID, nil
+}
+
+// GetAPIKeysByUserID возвращает неотозванные ключи пользователя, новые первыми.
+func (r *apiKeyRepository) GetAPIKeysByUserID(ctx context.Context, userID int64) ([]models.APIKey, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE user_id = $1 AND revoked_at IS NULL 
+												ORDER BY created_at DESC, id DESC`, apiKeyColumns, apiKeysTable)
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetAPIKeysByUserID: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		var key models.APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, fmt.Errorf("repository.GetAPIKeysByUserID: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.GetAPIKeysByUserID: %w", err)
+	}
+	return keys, nil
+}
+
+// GetAPIKeyByHash находит неотозванный ключ по хешу.
+func (r *apiKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE key_hash = $1 AND revoked_at IS NULL`, apiKeyColumns, apiKeysTable)
+
+	var key models.APIKey
+	if err := scanAPIKey(r.db.QueryRow(ctx, query, keyHash), &key); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("repository.GetAPIKeyByHash: %w", err)
+	}
+	return &key, nil
+}
+
+// TouchAPIKey отмечает использование ключа не чаще раза в apiKeyTouchInterval.
+func (r *apiKeyRepository) TouchAPIKey(ctx context.Context, id int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET last_used_at = NOW() 
+												WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '%s')`,
+		apiKeysTable, apiKeyTouchInterval)
+	if _, err := r.db.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("repository.TouchAPIKey: %w", err)
+	}
+	return nil
+}
+
+// RevokeAPIKey отзывает ключ пользователя. Чужой или уже отозванный ключ
+// не найдется.
+func (r *apiKeyRepository) RevokeAPIKey(ctx context.Context, id, userID int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, apiKeysTable)
+
+	tag, err := r.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("repository.RevokeAPIKey: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"marketplace/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type auditRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAuditRepository(db *pgxpool.Pool) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) CreateEntry(ctx context.Context, e *models.AuditEntry) (int64, error) {
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, action, ip, details) 
+												VALUES ($1, $2, $3, $4) RETURNING id`, auditLogTable)
+	var id int64
+	err := r.db.QueryRow(ctx, query, e.UserID, e.Action, e.IP, e.Details).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("repository.CreateEntry: %w", err)
+	}
+	return id, nil
+}
+
+// GetEntriesByUserID возвращает события журнала, связанные с пользователем.
+func (r *auditRepository) GetEntriesByUserID(ctx context.Context, userID int64) ([]models.AuditEntry, error) {
+	query := fmt.Sprintf(`SELECT id, user_id, action, ip, details, created_at 
+												FROM %s WHERE user_id = $1 ORDER BY created_at DESC`, auditLogTable)
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetEntriesByUserID: query error: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Action, &e.IP, &e.Details, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("repository.GetEntriesByUserID: row scan error: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.GetEntriesByUserID: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrVerificationTokenNotFound = errors.New("email verification token not found")
+)
+
+type emailVerificationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewEmailVerificationRepository(db *pgxpool.Pool) EmailVerificationRepository {
+	return &emailVerificationRepository{db: db}
+}
+
+// CreateVerificationToken сохраняет новый токен подтверждения почты. Ранее
+// отправленные ссылки пользователя при этом перестают действовать.
+func (r *emailVerificationRepository) CreateVerificationToken(ctx context.Context, token *models.EmailVerificationToken) (int64, error) {
+	const op = "repository.CreateVerificationToken"
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	expireQuery := fmt.Sprintf(`UPDATE %s SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`, emailVerificationsTable)
+	if _, err := tx.Exec(ctx, expireQuery, token.UserID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, email, token_hash, expires_at) VALUES ($1, $2, $3, $4) RETURNING id`, emailVerificationsTable)
+	if err := tx.QueryRow(ctx, query, token.UserID, token.Email, token.TokenHash, token.ExpiresAt).Scan(&token.ID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+	return token.ID, nil
+}
+
+// VerifyEmail гасит действующий токен с хешем tokenHash и отмечает почту владельца
+// подтвержденной. Токен, выданный для адреса, который с тех пор сменился, не
+// принимается. Возвращает подтвержденный адрес.
+func (r *emailVerificationRepository) VerifyEmail(ctx context.Context, tokenHash string) (string, error) {
+	const op = "repository.VerifyEmail"
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	consumeQuery := fmt.Sprintf(`UPDATE %s SET used_at = NOW() 
+												WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW() 
+												RETURNING user_id, email`, emailVerificationsTable)
+	var (
+		userID int64
+		email  string
+	)
+	if err := tx.QueryRow(ctx, consumeQuery, tokenHash).Scan(&userID, &email); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrVerificationTokenNotFound
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	updateQuery := fmt.Sprintf(`UPDATE %s SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW() 
+												WHERE id = $1 AND LOWER(email) = LOWER($2)`, usersTable)
+	tag, err := tx.Exec(ctx, updateQuery, userID, email)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return "", ErrVerificationTokenNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+	return email, nil
+}
@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ConstraintKind - вид нарушенного ограничения целостности.
+type ConstraintKind string
+
+const (
+	ConstraintUnique     ConstraintKind = "unique"
+	ConstraintForeignKey ConstraintKind = "foreign_key"
+	ConstraintCheck      ConstraintKind = "check"
+)
+
+// SQLSTATE нарушений ограничений целостности.
+const (
+	codeUniqueViolation     = "23505"
+	codeForeignKeyViolation = "23503"
+	codeCheckViolation      = "23514"
+)
+
+// ConstraintError - нарушение ограничения в базе данных. Field - поле запроса,
+// к которому относится ограничение, или пустая строка, если ограничение с
+// полем не сопоставлено. Err - доменная ошибка для известных ограничений
+// (например, ErrEmailTaken), поэтому errors.Is продолжает с ней работать.
+type ConstraintError struct {
+	Kind       ConstraintKind
+	Table      string
+	Constraint string
+	Field      string
+	Err        error
+
+	cause error
+}
+
+func (e *ConstraintError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s constraint %q violated on table %q", e.Kind, e.Constraint, e.Table)
+}
+
+// Unwrap возвращает доменную ошибку и исходную *pgconn.PgError.
+func (e *ConstraintError) Unwrap() []error {
+	var errs []error
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	if e.cause != nil {
+		errs = append(errs, e.cause)
+	}
+	return errs
+}
+
+type constraintInfo struct {
+	field string
+	err   error
+}
+
+// knownConstraints сопоставляет ограничения из миграций с полями запроса и
+// доменными ошибками. Имена ограничений, созданных без явного имени, Postgres
+// выводит из таблицы и столбца: <таблица>_<столбец>_key, _fkey, _check;
+// безымянная проверка на уровне таблицы получает имя <таблица>_check.
+var knownConstraints = map[string]constraintInfo{
+	"users_username_key": {field: "username", err: ErrUsernameTaken},
+	"idx_users_email":    {field: "email", err: ErrEmailTaken},
+	"users_role_check":   {field: "role"},
+
+	"ads_user_id_fkey":      {field: "user_id"},
+	"ads_title_check":       {field: "title"},
+	"ads_description_check": {field: "description"},
+	"ads_price_check":       {field: "price"},
+	"tags_name_check":       {field: "tags"},
+	"ad_tags_ad_id_fkey":    {err: ErrAdNotFound},
+
+	"ad_reports_ad_id_reporter_id_key":     {field: "ad_id", err: ErrReportExists},
+	"user_identities_provider_subject_key": {err: ErrIdentityLinked},
+	"user_identities_user_provider_key":    {field: "provider", err: ErrProviderAlreadyLinked},
+
+	"promotions_ad_id_fkey":      {err: ErrAdNotFound},
+	"promotions_granted_by_fkey": {err: ErrUserNotFound},
+	"promotions_check":           {field: "ends_at"},
+
+	"api_keys_user_id_fkey":                  {err: ErrUserNotFound},
+	"email_verification_tokens_user_id_fkey": {err: ErrUserNotFound},
+	"password_reset_tokens_user_id_fkey":     {err: ErrUserNotFound},
+}
+
+// translateError превращает нарушения уникальности (23505), внешнего ключа
+// (23503) и проверки (23514) в *ConstraintError. Остальные ошибки
+// возвращаются без изменений.
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	var kind ConstraintKind
+	switch pgErr.Code {
+	case codeUniqueViolation:
+		kind = ConstraintUnique
+	case codeForeignKeyViolation:
+		kind = ConstraintForeignKey
+	case codeCheckViolation:
+		kind = ConstraintCheck
+	default:
+		return err
+	}
+
+	info := knownConstraints[pgErr.ConstraintName]
+	field := info.field
+	if field == "" {
+		field = pgErr.ColumnName
+	}
+	return &ConstraintError{
+		Kind:       kind,
+		Table:      pgErr.TableName,
+		Constraint: pgErr.ConstraintName,
+		Field:      field,
+		Err:        info.err,
+		cause:      pgErr,
+	}
+}
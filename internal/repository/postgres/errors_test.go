@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslateError(t *testing.T) {
+	testCases := []struct {
+		name          string
+		pgErr         *pgconn.PgError
+		expectedKind  ConstraintKind
+		expectedField string
+		expectedErr   error
+	}{
+		{
+			name:          "Уникальность с доменной ошибкой",
+			pgErr:         &pgconn.PgError{Code: codeUniqueViolation, TableName: "users", ConstraintName: "users_username_key"},
+			expectedKind:  ConstraintUnique,
+			expectedField: "username",
+			expectedErr:   ErrUsernameTaken,
+		},
+		{
+			name:          "Уникальный индекс",
+			pgErr:         &pgconn.PgError{Code: codeUniqueViolation, TableName: "users", ConstraintName: "idx_users_email"},
+			expectedKind:  ConstraintUnique,
+			expectedField: "email",
+			expectedErr:   ErrEmailTaken,
+		},
+		{
+			name:          "Внешний ключ без доменной ошибки",
+			pgErr:         &pgconn.PgError{Code: codeForeignKeyViolation, TableName: "ads", ConstraintName: "ads_user_id_fkey"},
+			expectedKind:  ConstraintForeignKey,
+			expectedField: "user_id",
+		},
+		{
+			name:         "Внешний ключ с доменной ошибкой",
+			pgErr:        &pgconn.PgError{Code: codeForeignKeyViolation, TableName: "api_keys", ConstraintName: "api_keys_user_id_fkey"},
+			expectedKind: ConstraintForeignKey,
+			expectedErr:  ErrUserNotFound,
+		},
+		{
+			name:          "Проверка значения",
+			pgErr:         &pgconn.PgError{Code: codeCheckViolation, TableName: "ads", ConstraintName: "ads_price_check"},
+			expectedKind:  ConstraintCheck,
+			expectedField: "price",
+		},
+		{
+			name:          "Неизвестное ограничение берёт поле из столбца",
+			pgErr:         &pgconn.PgError{Code: codeUniqueViolation, TableName: "api_keys", ConstraintName: "api_keys_key_hash_key", ColumnName: "key_hash"},
+			expectedKind:  ConstraintUnique,
+			expectedField: "key_hash",
+		},
+		{
+			name:         "Неизвестное ограничение без столбца",
+			pgErr:        &pgconn.PgError{Code: codeCheckViolation, TableName: "ad_reports", ConstraintName: "ad_reports_status_check"},
+			expectedKind: ConstraintCheck,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := translateError(fmt.Errorf("scan: %w", tc.pgErr))
+
+			var constraint *ConstraintError
+			require.True(t, errors.As(err, &constraint))
+			assert.Equal(t, tc.expectedKind, constraint.Kind)
+			assert.Equal(t, tc.pgErr.TableName, constraint.Table)
+			assert.Equal(t, tc.pgErr.ConstraintName, constraint.Constraint)
+			assert.Equal(t, tc.expectedField, constraint.Field)
+			assert.Equal(t, tc.expectedErr, constraint.Err)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Equal(t, tc.expectedErr.Error(), err.Error())
+			}
+
+			// Исходная ошибка драйвера остаётся доступной через errors.As.
+			var pgErr *pgconn.PgError
+			require.True(t, errors.As(err, &pgErr))
+			assert.Same(t, tc.pgErr, pgErr)
+		})
+	}
+}
+
+// Ошибки, не связанные с ограничениями, возвращаются без изменений
+func TestTranslateError_Passthrough(t *testing.T) {
+	notNull := fmt.Errorf("scan: %w", &pgconn.PgError{Code: "23502", TableName: "ads", ColumnName: "title"})
+	plain := errors.New("connection refused")
+
+	assert.Same(t, notNull, translateError(notNull))
+	assert.Same(t, plain, translateError(plain))
+	assert.Nil(t, translateError(nil))
+}
@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrIdentityNotFound      = errors.New("external identity not found")
+	ErrIdentityLinked        = errors.New("external identity is already linked to a user")
+	ErrProviderAlreadyLinked = errors.New("user already has an identity from this provider")
+	ErrUsernameTaken         = errors.New("username already in use")
+)
+
+type identityRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewIdentityRepository(db *pgxpool.Pool) IdentityRepository {
+	return &identityRepository{db: db}
+}
+
+// GetUserByIdentity находит пользователя, к которому привязана учетная запись провайдера.
+func (r *identityRepository) GetUserByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE id = (SELECT user_id FROM %s WHERE provider = $1 AND subject = $2)`,
+		userColumns, usersTable, identitiesTable)
+	var user models.User
+	if err := scanUser(r.db.QueryRow(ctx, query, provider, subject), &user); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, fmt.Errorf("repository.GetUserByIdentity: %w", err)
+	}
+	return &user, nil
+}
+
+func (r *identityRepository) GetIdentitiesByUserID(ctx context.Context, userID int64) ([]models.ExternalIdentity, error) {
+	query := fmt.Sprintf(`SELECT id, user_id, provider, subject, email, created_at FROM %s 
+												WHERE user_id = $1 ORDER BY provider`, identitiesTable)
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetIdentitiesByUserID: %w", err)
+	}
+	defer rows.Close()
+
+	identities := make([]models.ExternalIdentity, 0)
+	for rows.Next() {
+		var i models.ExternalIdentity
+		if err := rows.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt); err != nil {
+			return nil, fmt.Errorf("repository.GetIdentitiesByUserID: %w", err)
+		}
+		identities = append(identities, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.GetIdentitiesByUserID: %w", err)
+	}
+	return identities, nil
+}
+
+// LinkIdentity привязывает учетную запись провайдера к существующему пользователю.
+func (r *identityRepository) LinkIdentity(ctx context.Context, identity *models.ExternalIdentity) (int64, error) {
+	if err := insertIdentity(ctx, r.db, identity); err != nil {
+		return 0, fmt.Errorf("repository.LinkIdentity: %w", err)
+	}
+	return identity.ID, nil
+}
+
+// CreateUserWithIdentity в одной транзакции создает пользователя, вошедшего
+// через провайдера, и привязывает к нему учетную запись провайдера. Почта,
+// подтвержденная провайдером, сразу считается подтвержденной.
+func (r *identityRepository) CreateUserWithIdentity(ctx context.Context, user *models.User, identity *models.ExternalIdentity) (int64, error) {
+	const op = "repository.CreateUserWithIdentity"
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf(`INSERT INTO %s (username, email, password_hash, role, email_verified_at) 
+												VALUES ($1, NULLIF($2, ''), $3, $4, $5) RETURNING id, created_at, updated_at`, usersTable)
+	err = tx.QueryRow(ctx, query, user.Username, user.Email, user.Password, user.Role, user.EmailVerifiedAt).
+		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	identity.UserID = user.ID
+	if err := insertIdentity(ctx, tx, identity); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+	return user.ID, nil
+}
+
+// queryRower - общее у пула соединений и транзакции.
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func insertIdentity(ctx context.Context, db queryRower, identity *models.ExternalIdentity) error {
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, provider, subject, email) 
+												VALUES ($1, $2, $3, $4) RETURNING id, created_at`, identitiesTable)
+	err := db.QueryRow(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email).
+		Scan(&identity.ID, &identity.CreatedAt)
+	return translateError(err)
+}
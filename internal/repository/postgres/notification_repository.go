@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"marketplace/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type notificationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewNotificationRepository(db *pgxpool.Pool) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) CreateNotification(ctx context.Context, n *models.Notification) (int64, error) {
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, type, message, ad_id) 
+												VALUES ($1, $2, $3, $4) RETURNING id`, notificationsTable)
+	var id int64
+	err := r.db.QueryRow(ctx, query, n.UserID, n.Type, n.Message, n.AdID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("repository.CreateNotification: %w", err)
+	}
+	return id, nil
+}
+
+func (r *notificationRepository) GetNotificationsByUserID(ctx context.Context, userID int64, limit int) ([]models.Notification, error) {
+	query := fmt.Sprintf(`SELECT id, user_id, type, message, ad_id, created_at 
+												FROM %s WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`, notificationsTable)
+
+	rows, err := r.db.Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetNotificationsByUserID: query error: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.Message, &n.AdID, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("repository.GetNotificationsByUserID: row scan error: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.GetNotificationsByUserID: %w", err)
+	}
+
+	return notifications, nil
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrResetTokenNotFound = errors.New("password reset token not found")
+)
+
+type passwordResetRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPasswordResetRepository(db *pgxpool.Pool) PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+// CreateResetToken сохраняет новый токен сброса. Ранее выданные и еще не
+// использованные токены пользователя при этом гасятся: действует только последний.
+func (r *passwordResetRepository) CreateResetToken(ctx context.Context, token *models.PasswordResetToken) (int64, error) {
+	const op = "repository.CreateResetToken"
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	expireQuery := fmt.Sprintf(`UPDATE %s SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`, passwordResetsTable)
+	if _, err := tx.Exec(ctx, expireQuery, token.UserID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, token_hash, expires_at) VALUES ($1, $2, $3) RETURNING id`, passwordResetsTable)
+	if err := tx.QueryRow(ctx, query, token.UserID, token.TokenHash, token.ExpiresAt).Scan(&token.ID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translateError(err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+	return token.ID, nil
+}
+
+// GetResetTokenUserID возвращает владельца действующего токена с хешем tokenHash,
+// не гася токен.
+func (r *passwordResetRepository) GetResetTokenUserID(ctx context.Context, tokenHash string) (int64, error) {
+	query := fmt.Sprintf(`SELECT user_id FROM %s WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()`, passwordResetsTable)
+	var userID int64
+	if err := r.db.QueryRow(ctx, query, tokenHash).Scan(&userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrResetTokenNotFound
+		}
+		return 0, fmt.Errorf("repository.GetResetTokenUserID: %w", err)
+	}
+	return userID, nil
+}
+
+// ResetPassword гасит действующий токен с хешем tokenHash и в той же транзакции
+// устанавливает владельцу новый хеш пароля. Возвращает ID пользователя.
+func (r *passwordResetRepository) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (int64, error) {
+	const op = "repository.ResetPassword"
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	consumeQuery := fmt.Sprintf(`UPDATE %s SET used_at = NOW() 
+												WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW() 
+												RETURNING user_id`, passwordResetsTable)
+	var userID int64
+	if err := tx.QueryRow(ctx, consumeQuery, tokenHash).Scan(&userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrResetTokenNotFound
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	updateQuery := fmt.Sprintf(`UPDATE %s SET password_hash = $1, updated_at = NOW() WHERE id = $2`, usersTable)
+	if _, err := tx.Exec(ctx, updateQuery, passwordHash, userID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+	return userID, nil
+}
@@ -10,8 +10,23 @@ import (
 )
 
 const (
-	usersTable = "users"
-	adsTable   = "ads"
+	usersTable  = "users"
+	adsTable    = "ads"
+	tagsTable   = "tags"
+	adTagsTable = "ad_tags"
+
+	promotionsTable    = "promotions"
+	reportsTable       = "ad_reports"
+	notificationsTable = "notifications"
+	refreshTokensTable = "refresh_tokens"
+
+	passwordResetsTable     = "password_reset_tokens"
+	emailVerificationsTable = "email_verification_tokens"
+	recoveryCodesTable      = "totp_recovery_codes"
+	auditLogTable           = "audit_log"
+	apiKeysTable            = "api_keys"
+	identitiesTable         = "user_identities"
+	sessionsTable           = "sessions"
 )
 
 func NewConnection(cfg config.Database, log *slog.Logger) (*pgxpool.Pool, error) {
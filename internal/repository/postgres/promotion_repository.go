@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrPromotionNotFound = errors.New("promotion not found")
+)
+
+type promotionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPromotionRepository(db *pgxpool.Pool) PromotionRepository {
+	return &promotionRepository{db: db}
+}
+
+func (r *promotionRepository) CreatePromotion(ctx context.Context, p *models.Promotion) (int64, error) {
+	query := fmt.Sprintf(`INSERT INTO %s (ad_id, granted_by, starts_at, ends_at) 
+												VALUES ($1, $2, $3, $4) RETURNING id, created_at`, promotionsTable)
+	var id int64
+	err := r.db.QueryRow(ctx, query, p.AdID, p.GrantedBy, p.StartsAt, p.EndsAt).Scan(&id, &p.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("repository.CreatePromotion: %w", translateError(err))
+	}
+	return id, nil
+}
+
+func (r *promotionRepository) GetPromotionsByAdID(ctx context.Context, adID int64) ([]models.Promotion, error) {
+	query := fmt.Sprintf(`SELECT id, ad_id, COALESCE(granted_by, 0), starts_at, ends_at, revoked_at, created_at 
+												FROM %s WHERE ad_id = $1 ORDER BY starts_at DESC`, promotionsTable)
+
+	rows, err := r.db.Query(ctx, query, adID)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetPromotionsByAdID: query error: %w", err)
+	}
+	defer rows.Close()
+
+	promotions := []models.Promotion{}
+	for rows.Next() {
+		var p models.Promotion
+		if err := rows.Scan(&p.ID, &p.AdID, &p.GrantedBy, &p.StartsAt, &p.EndsAt, &p.RevokedAt, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("repository.GetPromotionsByAdID: row scan error: %w", err)
+		}
+		promotions = append(promotions, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.GetPromotionsByAdID: %w", err)
+	}
+
+	return promotions, nil
+}
+
+func (r *promotionRepository) RevokePromotion(ctx context.Context, id int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, promotionsTable)
+	res, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("repository.RevokePromotion: %w", err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrPromotionNotFound
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenReused   = errors.New("refresh token reused")
+)
+
+// revokeFamilyQuery отзывает сессию и все еще действующие токены ее семейства.
+var revokeFamilyQuery = fmt.Sprintf(`WITH revoked AS (
+													UPDATE %s SET revoked_at = NOW() WHERE id = $1::uuid AND revoked_at IS NULL
+												)
+												UPDATE %s SET revoked_at = NOW() 
+												WHERE family_id = $1::uuid AND revoked_at IS NULL`, sessionsTable, refreshTokensTable)
+
+type refreshTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRefreshTokenRepository(db *pgxpool.Pool) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+// CreateRefreshToken открывает сессию session и сохраняет первый токен ее
+// семейства. Идентификатор сессии становится FamilyID токена.
+func (r *refreshTokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken, session *models.Session) (int64, error) {
+	const op = "repository.CreateRefreshToken"
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf(`INSERT INTO %s (id, user_id, user_agent, ip, expires_at) 
+												VALUES (gen_random_uuid(), $1, $2, $3, $4) RETURNING id::text, created_at, last_seen_at`, sessionsTable)
+	err = tx.QueryRow(ctx, query, token.UserID, session.UserAgent, session.IP, token.ExpiresAt).
+		Scan(&session.ID, &session.CreatedAt, &session.LastSeenAt)
+	if err != nil {
+		return 0, fmt.Errorf("%s: insert session: %w", op, err)
+	}
+	session.UserID = token.UserID
+	session.ExpiresAt = token.ExpiresAt
+
+	token.FamilyID = session.ID
+	query = fmt.Sprintf(`INSERT INTO %s (user_id, family_id, token_hash, expires_at) 
+												VALUES ($1, $2::uuid, $3, $4) RETURNING id`, refreshTokensTable)
+	if err := tx.QueryRow(ctx, query, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt).Scan(&token.ID); err != nil {
+		return 0, fmt.Errorf("%s: insert token: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+	return token.ID, nil
+}
+
+// RotateRefreshToken помечает токен с хешем hash использованным и сохраняет
+// next в том же семействе. Повторное предъявление уже использованного токена
+// означает, что он утек: все семейство отзывается и возвращается ErrRefreshTokenReused.
+func (r *refreshTokenRepository) RotateRefreshToken(ctx context.Context, hash string, next *models.RefreshToken) (*models.RefreshToken, error) {
+	const op = "repository.RotateRefreshToken"
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокировка строки не дает двум одновременным запросам обменять один токен дважды.
+	query := fmt.Sprintf(`SELECT id, user_id, family_id::text, token_hash, expires_at, used_at, revoked_at, created_at 
+												FROM %s WHERE token_hash = $1 FOR UPDATE`, refreshTokensTable)
+	var current models.RefreshToken
+	err = tx.QueryRow(ctx, query, hash).Scan(
+		&current.ID, &current.UserID, &current.FamilyID, &current.TokenHash,
+		&current.ExpiresAt, &current.UsedAt, &current.RevokedAt, &current.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if current.UsedAt != nil {
+		if err := revokeFamily(ctx, tx, current.FamilyID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, fmt.Errorf("%s: commit tx: %w", op, err)
+		}
+		return &current, ErrRefreshTokenReused
+	}
+
+	if current.RevokedAt != nil || !current.ExpiresAt.After(time.Now()) {
+		return nil, ErrRefreshTokenNotFound
+	}
+
+	markUsed := fmt.Sprintf(`UPDATE %s SET used_at = NOW() WHERE id = $1`, refreshTokensTable)
+	if _, err := tx.Exec(ctx, markUsed, current.ID); err != nil {
+		return nil, fmt.Errorf("%s: mark used: %w", op, err)
+	}
+
+	next.UserID = current.UserID
+	next.FamilyID = current.FamilyID
+	insert := fmt.Sprintf(`INSERT INTO %s (user_id, family_id, token_hash, expires_at) 
+												VALUES ($1, $2::uuid, $3, $4) RETURNING id`, refreshTokensTable)
+	if err := tx.QueryRow(ctx, insert, next.UserID, next.FamilyID, next.TokenHash, next.ExpiresAt).Scan(&next.ID); err != nil {
+		return nil, fmt.Errorf("%s: insert: %w", op, err)
+	}
+
+	touch := fmt.Sprintf(`UPDATE %s SET last_seen_at = NOW(), expires_at = $2 WHERE id = $1::uuid`, sessionsTable)
+	if _, err := tx.Exec(ctx, touch, next.FamilyID, next.ExpiresAt); err != nil {
+		return nil, fmt.Errorf("%s: touch session: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+	return &current, nil
+}
+
+// RevokeFamily отзывает все еще действующие токены семейства.
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	if _, err := r.db.Exec(ctx, revokeFamilyQuery, familyID); err != nil {
+		return fmt.Errorf("repository.RevokeFamily: %w", err)
+	}
+	return nil
+}
+
+// RevokeTokenFamily отзывает семейство, которому принадлежит токен пользователя с хешем hash.
+func (r *refreshTokenRepository) RevokeTokenFamily(ctx context.Context, userID int64, hash string) error {
+	query := fmt.Sprintf(`WITH family AS (
+													SELECT family_id FROM %s WHERE token_hash = $1 AND user_id = $2
+												), revoked AS (
+													UPDATE %s SET revoked_at = NOW() WHERE id IN (SELECT family_id FROM family) AND revoked_at IS NULL
+												)
+												UPDATE %s SET revoked_at = NOW() 
+												WHERE family_id IN (SELECT family_id FROM family) AND revoked_at IS NULL`,
+		refreshTokensTable, sessionsTable, refreshTokensTable)
+	if _, err := r.db.Exec(ctx, query, hash, userID); err != nil {
+		return fmt.Errorf("repository.RevokeTokenFamily: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserTokens отзывает все сессии и refresh-токены пользователя.
+func (r *refreshTokenRepository) RevokeUserTokens(ctx context.Context, userID int64) error {
+	query := fmt.Sprintf(`WITH revoked AS (
+													UPDATE %s SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL
+												)
+												UPDATE %s SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`,
+		sessionsTable, refreshTokensTable)
+	if _, err := r.db.Exec(ctx, query, userID); err != nil {
+		return fmt.Errorf("repository.RevokeUserTokens: %w", err)
+	}
+	return nil
+}
+
+func revokeFamily(ctx context.Context, tx pgx.Tx, familyID string) error {
+	if _, err := tx.Exec(ctx, revokeFamilyQuery, familyID); err != nil {
+		return fmt.Errorf("revoke family: %w", err)
+	}
+	return nil
+}
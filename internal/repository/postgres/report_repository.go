@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrReportNotFound   = errors.New("report not found")
+	ErrReportExists     = errors.New("ad already reported by this user")
+	ErrReportNotClaimed = errors.New("report is not claimed by this moderator")
+	ErrReportClaimed    = errors.New("report is already claimed or resolved")
+)
+
+const reportColumns = `id, ad_id, reporter_id, reason, comment, status, moderator_id, decision,
+	created_at, claimed_at, resolved_at`
+
+type reportRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewReportRepository(db *pgxpool.Pool) ReportRepository {
+	return &reportRepository{db: db}
+}
+
+func scanReport(row pgx.Row, r *models.Report) error {
+	return row.Scan(&r.ID, &r.AdID, &r.ReporterID, &r.Reason, &r.Comment, &r.Status, &r.ModeratorID, &r.Decision,
+		&r.CreatedAt, &r.ClaimedAt, &r.ResolvedAt)
+}
+
+func (r *reportRepository) CreateReport(ctx context.Context, report *models.Report) (int64, error) {
+	query := fmt.Sprintf(`INSERT INTO %s (ad_id, reporter_id, reason, comment) 
+												VALUES ($1, $2, $3, $4) RETURNING id`, reportsTable)
+	var id int64
+	err := r.db.QueryRow(ctx, query, report.AdID, report.ReporterID, report.Reason, report.Comment).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("repository.CreateReport: %w", translateError(err))
+	}
+	return id, nil
+}
+
+func (r *reportRepository) GetReports(ctx context.Context, status string, limit, offset int) ([]models.Report, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE status = $1 
+												ORDER BY created_at LIMIT $2 OFFSET $3`, reportColumns, reportsTable)
+
+	rows, err := r.db.Query(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetReports: query error: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []models.Report{}
+	for rows.Next() {
+		var report models.Report
+		if err := scanReport(rows, &report); err != nil {
+			return nil, fmt.Errorf("repository.GetReports: row scan error: %w", err)
+		}
+		reports = append(reports, report)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.GetReports: %w", err)
+	}
+
+	return reports, nil
+}
+
+// GetReportsByReporterID возвращает жалобы, поданные пользователем.
+func (r *reportRepository) GetReportsByReporterID(ctx context.Context, reporterID int64) ([]models.Report, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE reporter_id = $1 ORDER BY created_at`, reportColumns, reportsTable)
+
+	rows, err := r.db.Query(ctx, query, reporterID)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetReportsByReporterID: query error: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []models.Report{}
+	for rows.Next() {
+		var report models.Report
+		if err := scanReport(rows, &report); err != nil {
+			return nil, fmt.Errorf("repository.GetReportsByReporterID: row scan error: %w", err)
+		}
+		reports = append(reports, report)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.GetReportsByReporterID: %w", err)
+	}
+
+	return reports, nil
+}
+
+func (r *reportRepository) GetReportByID(ctx context.Context, id int64) (*models.Report, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE id = $1`, reportColumns, reportsTable)
+	var report models.Report
+	if err := scanReport(r.db.QueryRow(ctx, query, id), &report); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrReportNotFound
+		}
+		return nil, fmt.Errorf("repository.GetReportByID: %w", err)
+	}
+	return &report, nil
+}
+
+func (r *reportRepository) ClaimReport(ctx context.Context, id, moderatorID int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, moderator_id = $2, claimed_at = NOW()
+												WHERE id = $3 AND status = $4`, reportsTable)
+	res, err := r.db.Exec(ctx, query, models.ReportStatusClaimed, moderatorID, id, models.ReportStatusOpen)
+	if err != nil {
+		return fmt.Errorf("repository.ClaimReport: %w", err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrReportClaimed
+	}
+	return nil
+}
+
+// ResolveReport закрывает жалобу взятым ее модератором вместе со всеми
+// остальными открытыми жалобами на то же объявление и в той же транзакции
+// применяет решение: скрывает объявление, блокирует автора со всеми его
+// объявлениями или, при отклонении жалоб, публикует объявление с премодерации.
+func (r *reportRepository) ResolveReport(ctx context.Context, id, moderatorID int64, decision string) error {
+	const op = "repository.ResolveReport"
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var adID int64
+	lockQuery := fmt.Sprintf(`SELECT ad_id FROM %s WHERE id = $1 AND status = $2 AND moderator_id = $3 
+														FOR UPDATE`, reportsTable)
+	err = tx.QueryRow(ctx, lockQuery, id, models.ReportStatusClaimed, moderatorID).Scan(&adID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrReportNotClaimed
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Объявление на премодерации скрыто до решения модератора, и отклонение
+	// системной жалобы означает его публикацию. Объявление, которое модератор
+	// скрыл сам, при этом остается скрытым.
+	var pendingReview bool
+	pendingQuery := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s 
+														WHERE ad_id = $1 AND reason = $2 AND status <> $3)`, reportsTable)
+	err = tx.QueryRow(ctx, pendingQuery, adID, models.ReportReasonAutoScreening, models.ReportStatusResolved).Scan(&pendingReview)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	resolveQuery := fmt.Sprintf(`UPDATE %s SET status = $1, decision = $2, resolved_at = NOW(),
+												moderator_id = COALESCE(moderator_id, $3)
+												WHERE ad_id = $4 AND status <> $1`, reportsTable)
+	if _, err := tx.Exec(ctx, resolveQuery, models.ReportStatusResolved, decision, moderatorID, adID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	switch decision {
+	case models.DecisionDismiss:
+		if pendingReview {
+			publishQuery := fmt.Sprintf(`UPDATE %s SET hidden_at = NULL, hidden_by = NULL 
+												WHERE id = $1 AND hidden_by = $2`, adsTable)
+			if _, err := tx.Exec(ctx, publishQuery, adID, models.HiddenByScreening); err != nil {
+				return fmt.Errorf("%s: publish ad: %w", op, err)
+			}
+		}
+	case models.DecisionHide:
+		hideQuery := fmt.Sprintf(`UPDATE %s SET hidden_at = COALESCE(hidden_at, NOW()), hidden_by = $2 WHERE id = $1`, adsTable)
+		if _, err := tx.Exec(ctx, hideQuery, adID, models.HiddenByModerator); err != nil {
+			return fmt.Errorf("%s: hide ad: %w", op, err)
+		}
+	case models.DecisionBanAuthor:
+		banQuery := fmt.Sprintf(`UPDATE %s SET banned_at = NOW() 
+												WHERE id = (SELECT user_id FROM %s WHERE id = $1) AND banned_at IS NULL`, usersTable, adsTable)
+		if _, err := tx.Exec(ctx, banQuery, adID); err != nil {
+			return fmt.Errorf("%s: ban author: %w", op, err)
+		}
+		hideQuery := fmt.Sprintf(`UPDATE %s SET hidden_at = COALESCE(hidden_at, NOW()), hidden_by = $2 
+												WHERE user_id = (SELECT user_id FROM %s WHERE id = $1)`, adsTable, adsTable)
+		if _, err := tx.Exec(ctx, hideQuery, adID, models.HiddenByModerator); err != nil {
+			return fmt.Errorf("%s: hide author ads: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
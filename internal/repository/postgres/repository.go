@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"marketplace/internal/models"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -10,24 +11,144 @@ import (
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *models.User) (int64, error)
 	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	GetUserByID(ctx context.Context, id int64) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	UpdatePassword(ctx context.Context, id int64, passwordHash string) error
+	UpdateUser(ctx context.Context, user *models.User) error
+	DeleteUser(ctx context.Context, id int64) error
 }
 
 type AdRepository interface {
 	CreateAd(ctx context.Context, ad *models.Ad) (int64, error)
 	GetAllAds(ctx context.Context, params GetAllAdsParams) ([]models.Ad, error)
+	CountAds(ctx context.Context, params GetAllAdsParams) (int, error)
 	GetAdByID(ctx context.Context, id int64) (*models.Ad, error)
+	GetAdsByUserID(ctx context.Context, userID int64) ([]models.Ad, error)
 	UpdateAd(ctx context.Context, ad *models.Ad) error
 	DeleteAd(ctx context.Context, id, userID int64) error
+	HideAd(ctx context.Context, id int64) error
+	HasRecentDuplicate(ctx context.Context, q DuplicateQuery) (bool, error)
+	GetSimilarAdPairs(ctx context.Context, match DuplicateMatch) ([]SimilarPair, error)
+	PublishScheduled(ctx context.Context, now time.Time) ([]models.Ad, error)
+}
+
+type TagRepository interface {
+	GetPopularTags(ctx context.Context, limit int) ([]models.TagCount, error)
+}
+
+type PromotionRepository interface {
+	CreatePromotion(ctx context.Context, p *models.Promotion) (int64, error)
+	GetPromotionsByAdID(ctx context.Context, adID int64) ([]models.Promotion, error)
+	RevokePromotion(ctx context.Context, id int64) error
+}
+
+type ReportRepository interface {
+	CreateReport(ctx context.Context, report *models.Report) (int64, error)
+	GetReports(ctx context.Context, status string, limit, offset int) ([]models.Report, error)
+	GetReportByID(ctx context.Context, id int64) (*models.Report, error)
+	GetReportsByReporterID(ctx context.Context, reporterID int64) ([]models.Report, error)
+	ClaimReport(ctx context.Context, id, moderatorID int64) error
+	ResolveReport(ctx context.Context, id, moderatorID int64, decision string) error
+}
+
+type NotificationRepository interface {
+	CreateNotification(ctx context.Context, n *models.Notification) (int64, error)
+	GetNotificationsByUserID(ctx context.Context, userID int64, limit int) ([]models.Notification, error)
+}
+
+// RefreshTokenRepository хранит хеши выданных refresh-токенов и их ротацию.
+type RefreshTokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken, session *models.Session) (int64, error)
+	RotateRefreshToken(ctx context.Context, hash string, next *models.RefreshToken) (*models.RefreshToken, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeTokenFamily(ctx context.Context, userID int64, hash string) error
+	RevokeUserTokens(ctx context.Context, userID int64) error
+}
+
+// PasswordResetRepository хранит хеши одноразовых токенов сброса пароля.
+type PasswordResetRepository interface {
+	CreateResetToken(ctx context.Context, token *models.PasswordResetToken) (int64, error)
+	GetResetTokenUserID(ctx context.Context, tokenHash string) (int64, error)
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string) (int64, error)
+}
+
+// EmailVerificationRepository хранит хеши токенов подтверждения почты.
+type EmailVerificationRepository interface {
+	CreateVerificationToken(ctx context.Context, token *models.EmailVerificationToken) (int64, error)
+	VerifyEmail(ctx context.Context, tokenHash string) (string, error)
+}
+
+// TwoFactorRepository хранит секрет TOTP пользователя и хеши резервных кодов.
+type TwoFactorRepository interface {
+	GetTOTP(ctx context.Context, userID int64) (*models.TOTPSettings, error)
+	SetTOTPSecret(ctx context.Context, userID int64, secret string) error
+	EnableTOTP(ctx context.Context, userID int64, codeHashes []string, step int64) error
+	AcceptTOTPStep(ctx context.Context, userID, step int64) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error
+}
+
+// AuditRepository ведет журнал событий безопасности.
+type AuditRepository interface {
+	CreateEntry(ctx context.Context, e *models.AuditEntry) (int64, error)
+	GetEntriesByUserID(ctx context.Context, userID int64) ([]models.AuditEntry, error)
+}
+
+// APIKeyRepository хранит хеши API-ключей пользователей.
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey) (int64, error)
+	GetAPIKeysByUserID(ctx context.Context, userID int64) ([]models.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int64) error
+	RevokeAPIKey(ctx context.Context, id, userID int64) error
+}
+
+// IdentityRepository хранит привязки пользователей к внешним провайдерам OpenID Connect.
+type IdentityRepository interface {
+	GetUserByIdentity(ctx context.Context, provider, subject string) (*models.User, error)
+	GetIdentitiesByUserID(ctx context.Context, userID int64) ([]models.ExternalIdentity, error)
+	LinkIdentity(ctx context.Context, identity *models.ExternalIdentity) (int64, error)
+	CreateUserWithIdentity(ctx context.Context, user *models.User, identity *models.ExternalIdentity) (int64, error)
+}
+
+// SessionRepository хранит сессии пользователей. Сессии открываются и
+// продлеваются вместе с refresh-токенами в RefreshTokenRepository.
+type SessionRepository interface {
+	GetSessionsByUserID(ctx context.Context, userID int64, activeOnly bool) ([]models.Session, error)
+	RevokeSession(ctx context.Context, userID int64, id string) error
 }
 
 type Repository struct {
-	User UserRepository
-	Ad   AdRepository
+	User          UserRepository
+	Ad            AdRepository
+	Tag           TagRepository
+	Promotion     PromotionRepository
+	Report        ReportRepository
+	Notification  NotificationRepository
+	RefreshToken  RefreshTokenRepository
+	PasswordReset PasswordResetRepository
+	Verification  EmailVerificationRepository
+	TwoFactor     TwoFactorRepository
+	Audit         AuditRepository
+	APIKey        APIKeyRepository
+	Identity      IdentityRepository
+	Session       SessionRepository
 }
 
 func NewRepository(db *pgxpool.Pool) *Repository {
 	return &Repository{
-		User: NewUserRepository(db),
-		Ad:   NewAdRepository(db),
+		User:          NewUserRepository(db),
+		Ad:            NewAdRepository(db),
+		Tag:           NewTagRepository(db),
+		Promotion:     NewPromotionRepository(db),
+		Report:        NewReportRepository(db),
+		Notification:  NewNotificationRepository(db),
+		RefreshToken:  NewRefreshTokenRepository(db),
+		PasswordReset: NewPasswordResetRepository(db),
+		Verification:  NewEmailVerificationRepository(db),
+		TwoFactor:     NewTwoFactorRepository(db),
+		Audit:         NewAuditRepository(db),
+		APIKey:        NewAPIKeyRepository(db),
+		Identity:      NewIdentityRepository(db),
+		Session:       NewSessionRepository(db),
 	}
 }
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"marketplace/internal/models"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -28,6 +29,40 @@ func (m *MockUserRepository) GetUserByUsername(ctx context.Context, username str
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
+// GetUserByID симулирует получение пользователя по ID.
+func (m *MockUserRepository) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+// GetUserByEmail симулирует поиск пользователя по почте.
+func (m *MockUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	args := m.Called(ctx, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+// UpdatePassword симулирует смену хеша пароля.
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	args := m.Called(ctx, id, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) DeleteUser(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
 // MockAdRepository является мок-реализацией AdRepository.
 type MockAdRepository struct {
 	mock.Mock
@@ -48,6 +83,12 @@ func (m *MockAdRepository) GetAllAds(ctx context.Context, params GetAllAdsParams
 	return args.Get(0).([]models.Ad), args.Error(1)
 }
 
+// CountAds симулирует подсчет объявлений по фильтрам.
+func (m *MockAdRepository) CountAds(ctx context.Context, params GetAllAdsParams) (int, error) {
+	args := m.Called(ctx, params)
+	return args.Int(0), args.Error(1)
+}
+
 // GetAdByID симулирует получение объявления по ID.
 func (m *MockAdRepository) GetAdByID(ctx context.Context, id int64) (*models.Ad, error) {
 	args := m.Called(ctx, id)
@@ -68,3 +109,380 @@ func (m *MockAdRepository) DeleteAd(ctx context.Context, id, userID int64) error
 	args := m.Called(ctx, id, userID)
 	return args.Error(0)
 }
+
+// HideAd симулирует скрытие объявления.
+func (m *MockAdRepository) HideAd(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// HasRecentDuplicate симулирует поиск недавнего повтора объявления.
+func (m *MockAdRepository) HasRecentDuplicate(ctx context.Context, q DuplicateQuery) (bool, error) {
+	args := m.Called(ctx, q)
+	return args.Bool(0), args.Error(1)
+}
+
+// GetSimilarAdPairs симулирует поиск пар похожих объявлений.
+func (m *MockAdRepository) GetSimilarAdPairs(ctx context.Context, match DuplicateMatch) ([]SimilarPair, error) {
+	args := m.Called(ctx, match)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]SimilarPair), args.Error(1)
+}
+
+// PublishScheduled симулирует публикацию отложенных объявлений.
+func (m *MockAdRepository) PublishScheduled(ctx context.Context, now time.Time) ([]models.Ad, error) {
+	args := m.Called(ctx, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Ad), args.Error(1)
+}
+
+func (m *MockAdRepository) GetAdsByUserID(ctx context.Context, userID int64) ([]models.Ad, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Ad), args.Error(1)
+}
+
+// MockTagRepository является мок-реализацией TagRepository.
+type MockTagRepository struct {
+	mock.Mock
+}
+
+// GetPopularTags симулирует получение популярных тегов.
+func (m *MockTagRepository) GetPopularTags(ctx context.Context, limit int) ([]models.TagCount, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.TagCount), args.Error(1)
+}
+
+// MockPromotionRepository является мок-реализацией PromotionRepository.
+type MockPromotionRepository struct {
+	mock.Mock
+}
+
+// CreatePromotion симулирует создание продвижения.
+func (m *MockPromotionRepository) CreatePromotion(ctx context.Context, p *models.Promotion) (int64, error) {
+	args := m.Called(ctx, p)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// GetPromotionsByAdID симулирует получение продвижений объявления.
+func (m *MockPromotionRepository) GetPromotionsByAdID(ctx context.Context, adID int64) ([]models.Promotion, error) {
+	args := m.Called(ctx, adID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Promotion), args.Error(1)
+}
+
+// RevokePromotion симулирует отзыв продвижения.
+func (m *MockPromotionRepository) RevokePromotion(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// MockReportRepository является мок-реализацией ReportRepository.
+type MockReportRepository struct {
+	mock.Mock
+}
+
+// CreateReport симулирует создание жалобы.
+func (m *MockReportRepository) CreateReport(ctx context.Context, report *models.Report) (int64, error) {
+	args := m.Called(ctx, report)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// GetReports симулирует получение очереди жалоб.
+func (m *MockReportRepository) GetReports(ctx context.Context, status string, limit, offset int) ([]models.Report, error) {
+	args := m.Called(ctx, status, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Report), args.Error(1)
+}
+
+// GetReportByID симулирует получение жалобы по ID.
+func (m *MockReportRepository) GetReportByID(ctx context.Context, id int64) (*models.Report, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Report), args.Error(1)
+}
+
+func (m *MockReportRepository) GetReportsByReporterID(ctx context.Context, reporterID int64) ([]models.Report, error) {
+	args := m.Called(ctx, reporterID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Report), args.Error(1)
+}
+
+// ClaimReport симулирует взятие жалобы в работу.
+func (m *MockReportRepository) ClaimReport(ctx context.Context, id, moderatorID int64) error {
+	args := m.Called(ctx, id, moderatorID)
+	return args.Error(0)
+}
+
+// ResolveReport симулирует вынесение решения по жалобе.
+func (m *MockReportRepository) ResolveReport(ctx context.Context, id, moderatorID int64, decision string) error {
+	args := m.Called(ctx, id, moderatorID, decision)
+	return args.Error(0)
+}
+
+// MockNotificationRepository является мок-реализацией NotificationRepository.
+type MockNotificationRepository struct {
+	mock.Mock
+}
+
+// CreateNotification симулирует создание уведомления.
+func (m *MockNotificationRepository) CreateNotification(ctx context.Context, n *models.Notification) (int64, error) {
+	args := m.Called(ctx, n)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// GetNotificationsByUserID симулирует получение уведомлений пользователя.
+func (m *MockNotificationRepository) GetNotificationsByUserID(ctx context.Context, userID int64, limit int) ([]models.Notification, error) {
+	args := m.Called(ctx, userID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Notification), args.Error(1)
+}
+
+// MockRefreshTokenRepository является мок-реализацией RefreshTokenRepository.
+type MockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+// CreateRefreshToken симулирует сохранение первого токена семейства.
+func (m *MockRefreshTokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken, session *models.Session) (int64, error) {
+	args := m.Called(ctx, token, session)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// RotateRefreshToken симулирует обмен refresh-токена на новый.
+func (m *MockRefreshTokenRepository) RotateRefreshToken(ctx context.Context, hash string, next *models.RefreshToken) (*models.RefreshToken, error) {
+	args := m.Called(ctx, hash, next)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RefreshToken), args.Error(1)
+}
+
+// RevokeFamily симулирует отзыв семейства токенов.
+func (m *MockRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	args := m.Called(ctx, familyID)
+	return args.Error(0)
+}
+
+// RevokeTokenFamily симулирует отзыв семейства по одному из его токенов.
+func (m *MockRefreshTokenRepository) RevokeTokenFamily(ctx context.Context, userID int64, hash string) error {
+	args := m.Called(ctx, userID, hash)
+	return args.Error(0)
+}
+
+// RevokeUserTokens симулирует отзыв всех токенов пользователя.
+func (m *MockRefreshTokenRepository) RevokeUserTokens(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+// MockPasswordResetRepository является мок-реализацией PasswordResetRepository.
+type MockPasswordResetRepository struct {
+	mock.Mock
+}
+
+// CreateResetToken симулирует сохранение токена сброса пароля.
+func (m *MockPasswordResetRepository) CreateResetToken(ctx context.Context, token *models.PasswordResetToken) (int64, error) {
+	args := m.Called(ctx, token)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// ResetPassword симулирует сброс пароля по токену.
+func (m *MockPasswordResetRepository) GetResetTokenUserID(ctx context.Context, tokenHash string) (int64, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockPasswordResetRepository) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (int64, error) {
+	args := m.Called(ctx, tokenHash, passwordHash)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockEmailVerificationRepository является мок-реализацией EmailVerificationRepository.
+type MockEmailVerificationRepository struct {
+	mock.Mock
+}
+
+// CreateVerificationToken симулирует сохранение токена подтверждения почты.
+func (m *MockEmailVerificationRepository) CreateVerificationToken(ctx context.Context, token *models.EmailVerificationToken) (int64, error) {
+	args := m.Called(ctx, token)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// VerifyEmail симулирует подтверждение почты по токену.
+func (m *MockEmailVerificationRepository) VerifyEmail(ctx context.Context, tokenHash string) (string, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.String(0), args.Error(1)
+}
+
+// MockTwoFactorRepository является мок-реализацией TwoFactorRepository.
+type MockTwoFactorRepository struct {
+	mock.Mock
+}
+
+// GetTOTP симулирует получение настроек TOTP пользователя.
+func (m *MockTwoFactorRepository) GetTOTP(ctx context.Context, userID int64) (*models.TOTPSettings, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TOTPSettings), args.Error(1)
+}
+
+// SetTOTPSecret симулирует сохранение неподтвержденного секрета.
+func (m *MockTwoFactorRepository) SetTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	args := m.Called(ctx, userID, secret)
+	return args.Error(0)
+}
+
+// EnableTOTP симулирует включение двухфакторной аутентификации.
+func (m *MockTwoFactorRepository) EnableTOTP(ctx context.Context, userID int64, codeHashes []string, step int64) error {
+	args := m.Called(ctx, userID, codeHashes, step)
+	return args.Error(0)
+}
+
+// AcceptTOTPStep симулирует отметку использованного шага TOTP.
+func (m *MockTwoFactorRepository) AcceptTOTPStep(ctx context.Context, userID, step int64) error {
+	args := m.Called(ctx, userID, step)
+	return args.Error(0)
+}
+
+// UseRecoveryCode симулирует использование резервного кода.
+func (m *MockTwoFactorRepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	args := m.Called(ctx, userID, codeHash)
+	return args.Error(0)
+}
+
+// MockAuditRepository является мок-реализацией AuditRepository.
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+// CreateEntry симулирует запись в журнал событий безопасности.
+func (m *MockAuditRepository) CreateEntry(ctx context.Context, e *models.AuditEntry) (int64, error) {
+	args := m.Called(ctx, e)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAuditRepository) GetEntriesByUserID(ctx context.Context, userID int64) ([]models.AuditEntry, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.AuditEntry), args.Error(1)
+}
+
+// MockAPIKeyRepository является мок-реализацией APIKeyRepository.
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+// CreateAPIKey симулирует сохранение нового ключа.
+func (m *MockAPIKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) (int64, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// GetAPIKeysByUserID симулирует получение ключей пользователя.
+func (m *MockAPIKeyRepository) GetAPIKeysByUserID(ctx context.Context, userID int64) ([]models.APIKey, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+// GetAPIKeyByHash симулирует поиск ключа по хешу.
+func (m *MockAPIKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	args := m.Called(ctx, keyHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIKey), args.Error(1)
+}
+
+// TouchAPIKey симулирует отметку об использовании ключа.
+func (m *MockAPIKeyRepository) TouchAPIKey(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// RevokeAPIKey симулирует отзыв ключа.
+func (m *MockAPIKeyRepository) RevokeAPIKey(ctx context.Context, id, userID int64) error {
+	args := m.Called(ctx, id, userID)
+	return args.Error(0)
+}
+
+// MockIdentityRepository является мок-реализацией IdentityRepository.
+type MockIdentityRepository struct {
+	mock.Mock
+}
+
+// GetUserByIdentity симулирует поиск пользователя по учетной записи провайдера.
+func (m *MockIdentityRepository) GetUserByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	args := m.Called(ctx, provider, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+// GetIdentitiesByUserID симулирует получение привязанных провайдеров.
+func (m *MockIdentityRepository) GetIdentitiesByUserID(ctx context.Context, userID int64) ([]models.ExternalIdentity, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.ExternalIdentity), args.Error(1)
+}
+
+// LinkIdentity симулирует привязку провайдера к пользователю.
+func (m *MockIdentityRepository) LinkIdentity(ctx context.Context, identity *models.ExternalIdentity) (int64, error) {
+	args := m.Called(ctx, identity)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// CreateUserWithIdentity симулирует создание пользователя, вошедшего через провайдера.
+func (m *MockIdentityRepository) CreateUserWithIdentity(ctx context.Context, user *models.User, identity *models.ExternalIdentity) (int64, error) {
+	args := m.Called(ctx, user, identity)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockSessionRepository является мок-реализацией SessionRepository.
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+// GetSessionsByUserID симулирует выборку сессий пользователя.
+func (m *MockSessionRepository) GetSessionsByUserID(ctx context.Context, userID int64, activeOnly bool) ([]models.Session, error) {
+	args := m.Called(ctx, userID, activeOnly)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Session), args.Error(1)
+}
+
+// RevokeSession симулирует отзыв сессии вместе с ее refresh-токенами.
+func (m *MockSessionRepository) RevokeSession(ctx context.Context, userID int64, id string) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type sessionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSessionRepository(db *pgxpool.Pool) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+// GetSessionsByUserID возвращает сессии пользователя, начиная с последней активной.
+// С activeOnly возвращаются только не отозванные и не истекшие сессии.
+func (r *sessionRepository) GetSessionsByUserID(ctx context.Context, userID int64, activeOnly bool) ([]models.Session, error) {
+	query := fmt.Sprintf(`SELECT id::text, user_id, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at 
+												FROM %s WHERE user_id = $1`, sessionsTable)
+	if activeOnly {
+		query += ` AND revoked_at IS NULL AND expires_at > NOW()`
+	}
+	query += ` ORDER BY last_seen_at DESC`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetSessionsByUserID: query error: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt); err != nil {
+			return nil, fmt.Errorf("repository.GetSessionsByUserID: row scan error: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.GetSessionsByUserID: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession отзывает действующую сессию пользователя вместе с ее refresh-токенами.
+// Для чужой, уже отозванной или несуществующей сессии возвращается ErrSessionNotFound.
+func (r *sessionRepository) RevokeSession(ctx context.Context, userID int64, id string) error {
+	const op = "repository.RevokeSession"
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf(`UPDATE %s SET revoked_at = NOW() 
+												WHERE id = $1::uuid AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()`, sessionsTable)
+	res, err := tx.Exec(ctx, query, id, userID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+
+	query = fmt.Sprintf(`UPDATE %s SET revoked_at = NOW() WHERE family_id = $1::uuid AND revoked_at IS NULL`, refreshTokensTable)
+	if _, err := tx.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("%s: revoke tokens: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+	return nil
+}
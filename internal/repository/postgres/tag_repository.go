@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"marketplace/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type tagRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewTagRepository(db *pgxpool.Pool) TagRepository {
+	return &tagRepository{db: db}
+}
+
+func (r *tagRepository) GetPopularTags(ctx context.Context, limit int) ([]models.TagCount, error) {
+	query := fmt.Sprintf(`SELECT t.name, COUNT(*) AS ads_count
+												FROM %s t JOIN %s at ON at.tag_id = t.id
+												GROUP BY t.name
+												ORDER BY ads_count DESC, t.name
+												LIMIT $1`, tagsTable, adTagsTable)
+
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetPopularTags: query error: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []models.TagCount{}
+	for rows.Next() {
+		var tag models.TagCount
+		if err := rows.Scan(&tag.Name, &tag.AdsCount); err != nil {
+			return nil, fmt.Errorf("repository.GetPopularTags: row scan error: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.GetPopularTags: %w", err)
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrTOTPAlreadyEnabled   = errors.New("totp is already enabled")
+	ErrRecoveryCodeNotFound = errors.New("recovery code not found")
+	ErrTOTPCodeReused       = errors.New("totp code has already been used")
+)
+
+type twoFactorRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewTwoFactorRepository(db *pgxpool.Pool) TwoFactorRepository {
+	return &twoFactorRepository{db: db}
+}
+
+func (r *twoFactorRepository) GetTOTP(ctx context.Context, userID int64) (*models.TOTPSettings, error) {
+	query := fmt.Sprintf(`SELECT id, COALESCE(totp_secret, ''), totp_enabled_at FROM %s WHERE id = $1`, usersTable)
+
+	var settings models.TOTPSettings
+	err := r.db.QueryRow(ctx, query, userID).Scan(&settings.UserID, &settings.Secret, &settings.EnabledAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("repository.GetTOTP: %w", err)
+	}
+	return &settings, nil
+}
+
+// SetTOTPSecret сохраняет секрет, который еще предстоит подтвердить кодом.
+// Повторный вызов заменяет неподтвержденный секрет; включенную двухфакторную
+// аутентификацию он не затрагивает.
+func (r *twoFactorRepository) SetTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	query := fmt.Sprintf(`UPDATE %s SET totp_secret = $1, updated_at = NOW() WHERE id = $2 AND totp_enabled_at IS NULL`, usersTable)
+
+	tag, err := r.db.Exec(ctx, query, secret, userID)
+	if err != nil {
+		return fmt.Errorf("repository.SetTOTPSecret: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+	return nil
+}
+
+// EnableTOTP включает двухфакторную аутентификацию и в той же транзакции
+// заменяет резервные коды пользователя хешами codeHashes. Шаг step кода,
+// которым подтверждено подключение, считается использованным.
+func (r *twoFactorRepository) EnableTOTP(ctx context.Context, userID int64, codeHashes []string, step int64) error {
+	const op = "repository.EnableTOTP"
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: begin tx: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	enableQuery := fmt.Sprintf(`UPDATE %s SET totp_enabled_at = NOW(), totp_last_step = $2, updated_at = NOW() 
+											WHERE id = $1 AND totp_enabled_at IS NULL AND totp_secret IS NOT NULL`, usersTable)
+	tag, err := tx.Exec(ctx, enableQuery, userID, step)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, recoveryCodesTable)
+	if _, err := tx.Exec(ctx, deleteQuery, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	insertQuery := fmt.Sprintf(`INSERT INTO %s (user_id, code_hash) SELECT $1, UNNEST($2::text[])`, recoveryCodesTable)
+	if _, err := tx.Exec(ctx, insertQuery, userID, codeHashes); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: commit tx: %w", op, err)
+	}
+	return nil
+}
+
+// AcceptTOTPStep отмечает временной шаг принятого кода TOTP. Шаг должен быть
+// позже последнего принятого, иначе код уже предъявлялся и возвращается
+// ErrTOTPCodeReused. Проверка и запись выполняются одним запросом, поэтому
+// один код не пройдет и в параллельных запросах.
+func (r *twoFactorRepository) AcceptTOTPStep(ctx context.Context, userID, step int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2`, usersTable)
+
+	tag, err := r.db.Exec(ctx, query, userID, step)
+	if err != nil {
+		return fmt.Errorf("repository.AcceptTOTPStep: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTOTPCodeReused
+	}
+	return nil
+}
+
+// UseRecoveryCode гасит неиспользованный резервный код пользователя.
+func (r *twoFactorRepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	query := fmt.Sprintf(`UPDATE %s SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, recoveryCodesTable)
+
+	tag, err := r.db.Exec(ctx, query, userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("repository.UseRecoveryCode: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRecoveryCodeNotFound
+	}
+	return nil
+}
@@ -12,8 +12,21 @@ import (
 
 var (
 	ErrUserNotFound = errors.New("user not found")
+	ErrEmailTaken   = errors.New("email already in use")
 )
 
+const userColumns = `id, username, COALESCE(email, ''), password_hash, role, banned_at, created_at, updated_at,
+	email_verified_at, totp_enabled_at,
+	COALESCE(display_name, ''), COALESCE(avatar_url, ''), COALESCE(phone, ''), COALESCE(bio, ''), COALESCE(language, '')`
+
+func scanUser(row pgx.Row, user *models.User) error {
+	return row.Scan(
+		&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.BannedAt, &user.CreatedAt, &user.UpdatedAt,
+		&user.EmailVerifiedAt, &user.TOTPEnabledAt,
+		&user.DisplayName, &user.AvatarURL, &user.Phone, &user.Bio, &user.Language,
+	)
+}
+
 type userRepository struct {
 	db *pgxpool.Pool
 }
@@ -23,23 +36,20 @@ func NewUserRepository(db *pgxpool.Pool) UserRepository {
 }
 
 func (r *userRepository) CreateUser(ctx context.Context, user *models.User) (int64, error) {
-	query := fmt.Sprintf(`INSERT INTO %s (username, password_hash) VALUES ($1, $2) RETURNING id`, usersTable)
+	query := fmt.Sprintf(`INSERT INTO %s (username, email, password_hash, role) 
+												VALUES ($1, NULLIF($2, ''), $3, $4) RETURNING id`, usersTable)
 	var id int64
-	err := r.db.QueryRow(ctx, query, user.Username, user.Password).Scan(&id)
+	err := r.db.QueryRow(ctx, query, user.Username, user.Email, user.Password, user.Role).Scan(&id)
 	if err != nil {
-		return 0, fmt.Errorf("repository.CreateUser: %w", err)
+		return 0, fmt.Errorf("repository.CreateUser: %w", translateError(err))
 	}
 	return id, nil
 }
 
 func (r *userRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
-	query := fmt.Sprintf(`SELECT id, username, password_hash, created_at, updated_at 
-												FROM %s WHERE username = $1`, usersTable)
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE username = $1`, userColumns, usersTable)
 	var user models.User
-	err := r.db.QueryRow(ctx, query, username).Scan(
-		&user.ID, &user.Username, &user.Password, &user.CreatedAt, &user.UpdatedAt,
-	)
-	if err != nil {
+	if err := scanUser(r.db.QueryRow(ctx, query, username), &user); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrUserNotFound
 		}
@@ -47,3 +57,87 @@ func (r *userRepository) GetUserByUsername(ctx context.Context, username string)
 	}
 	return &user, nil
 }
+
+func (r *userRepository) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE id = $1`, userColumns, usersTable)
+	var user models.User
+	if err := scanUser(r.db.QueryRow(ctx, query, id), &user); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("repository.GetUserByID: %w", err)
+	}
+	return &user, nil
+}
+
+// GetUserByEmail ищет пользователя по адресу почты без учета регистра.
+func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE LOWER(email) = LOWER($1)`, userColumns, usersTable)
+	var user models.User
+	if err := scanUser(r.db.QueryRow(ctx, query, email), &user); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("repository.GetUserByEmail: %w", err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	query := fmt.Sprintf(`UPDATE %s SET password_hash = $1, updated_at = NOW() WHERE id = $2`, usersTable)
+	res, err := r.db.Exec(ctx, query, passwordHash, id)
+	if err != nil {
+		return fmt.Errorf("repository.UpdatePassword: %w", err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// UpdateUser сохраняет поля профиля пользователя. Пустые строки хранятся как NULL.
+func (r *userRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	query := fmt.Sprintf(`UPDATE %s SET display_name = NULLIF($1, ''), avatar_url = NULLIF($2, ''), phone = NULLIF($3, ''),
+		bio = NULLIF($4, ''), language = NULLIF($5, ''), updated_at = NOW() WHERE id = $6 RETURNING updated_at`, usersTable)
+	err := r.db.QueryRow(ctx, query, user.DisplayName, user.AvatarURL, user.Phone, user.Bio, user.Language, user.ID).
+		Scan(&user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("repository.UpdateUser: %w", translateError(err))
+	}
+	return nil
+}
+
+// DeleteUser удаляет пользователя. Объявления, токены, уведомления и прочие
+// связанные строки удаляются каскадом; записи журнала безопасности остаются
+// без привязки к пользователю, а IP-адрес и подробности из них стираются.
+func (r *userRepository) DeleteUser(ctx context.Context, id int64) error {
+	const op = "repository.DeleteUser"
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf(`UPDATE %s SET ip = '', details = '' WHERE user_id = $1`, auditLogTable)
+	if _, err := tx.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, usersTable)
+	res, err := tx.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
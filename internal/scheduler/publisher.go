@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"marketplace/internal/service"
+	"time"
+)
+
+// Publisher периодически публикует отложенные объявления, время которых наступило.
+// Публикация выполняется одним UPDATE в БД, поэтому несколько экземпляров
+// приложения могут работать одновременно без повторной публикации.
+type Publisher struct {
+	ads      service.AdService
+	interval time.Duration
+	log      *slog.Logger
+}
+
+func NewPublisher(ads service.AdService, interval time.Duration, log *slog.Logger) *Publisher {
+	return &Publisher{
+		ads:      ads,
+		interval: interval,
+		log:      log,
+	}
+}
+
+// Run выполняет публикацию с заданным интервалом, пока не будет отменен ctx.
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.publish(ctx)
+		}
+	}
+}
+
+func (p *Publisher) publish(ctx context.Context) {
+	published, err := p.ads.PublishScheduled(ctx)
+	if err != nil {
+		p.log.Error("failed to publish scheduled ads", slog.String("error", err.Error()))
+		return
+	}
+	if published > 0 {
+		p.log.Info("published scheduled ads", slog.Int("count", published))
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
+	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/hash"
+	"time"
+)
+
+// exportNotificationLimit - сколько последних уведомлений попадает в выгрузку.
+const exportNotificationLimit = 10000
+
+// deleteReauthWindow - насколько недавним должен быть вход пользователя без
+// пароля, чтобы он мог удалить учетную запись.
+const deleteReauthWindow = 10 * time.Minute
+
+var ErrReauthRequired = errors.New("recent sign-in required")
+
+type accountService struct {
+	userRepo         postgres.UserRepository
+	adRepo           postgres.AdRepository
+	reportRepo       postgres.ReportRepository
+	notificationRepo postgres.NotificationRepository
+	sessionRepo      postgres.SessionRepository
+	auditRepo        postgres.AuditRepository
+	apiKeyRepo       postgres.APIKeyRepository
+	identityRepo     postgres.IdentityRepository
+	suggestRepo      cache.SuggestRepository
+	auth             AuthService
+	passwords        *hash.Hasher
+	log              *slog.Logger
+}
+
+func NewAccountService(
+	repos *postgres.Repository,
+	suggestRepo cache.SuggestRepository,
+	auth AuthService,
+	passwords *hash.Hasher,
+	log *slog.Logger,
+) AccountService {
+	return &accountService{
+		userRepo:         repos.User,
+		adRepo:           repos.Ad,
+		reportRepo:       repos.Report,
+		notificationRepo: repos.Notification,
+		sessionRepo:      repos.Session,
+		auditRepo:        repos.Audit,
+		apiKeyRepo:       repos.APIKey,
+		identityRepo:     repos.Identity,
+		suggestRepo:      suggestRepo,
+		auth:             auth,
+		passwords:        passwords,
+		log:              log,
+	}
+}
+
+// Export собирает все данные, которые хранятся о пользователе: профиль,
+// объявления (включая скрытые и отложенные), жалобы, уведомления, API-ключи,
+// привязки к провайдерам, историю входов и события безопасности.
+func (s *accountService) Export(ctx context.Context, userID int64) (*models.AccountExport, error) {
+	const op = "service.Export"
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.AccountExport{ExportedAt: time.Now().UTC(), Profile: user}
+	if export.Ads, err = s.adRepo.GetAdsByUserID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if export.Reports, err = s.reportRepo.GetReportsByReporterID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if export.Notifications, err = s.notificationRepo.GetNotificationsByUserID(ctx, userID, exportNotificationLimit); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if export.APIKeys, err = s.apiKeyRepo.GetAPIKeysByUserID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if export.Identities, err = s.identityRepo.GetIdentitiesByUserID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if export.LoginHistory, err = s.sessionRepo.GetSessionsByUserID(ctx, userID, false); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if export.SecurityEvents, err = s.auditRepo.GetEntriesByUserID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return export, nil
+}
+
+// Delete удаляет учетную запись после проверки пароля. Пользователь, вошедший
+// через провайдера и не задавший пароль, подтверждает удаление свежим входом:
+// сессия sessionID должна быть открыта не раньше deleteReauthWindow назад.
+// Сначала завершаются все сессии, затем пользователь удаляется вместе с
+// объявлениями и остальными данными, а заголовки его объявлений убираются из
+// подсказок поиска.
+func (s *accountService) Delete(ctx context.Context, userID int64, sessionID, password string) error {
+	const op = "service.DeleteAccount"
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Password == "" {
+		if err := s.checkRecentSignIn(ctx, userID, sessionID); err != nil {
+			return err
+		}
+	} else if ok, _ := s.passwords.Verify(password, user.Password); !ok {
+		return ErrWrongPassword
+	}
+
+	ads, err := s.adRepo.GetAdsByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.auth.LogoutAll(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.userRepo.DeleteUser(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, ad := range ads {
+		if !titleIndexed(&ad) {
+			continue
+		}
+		if err := s.suggestRepo.RemoveTitle(ctx, ad.Title); err != nil {
+			s.log.Warn("failed to remove ad title from index", slog.String("error", err.Error()))
+		}
+	}
+
+	s.log.Info("account deleted", slog.Int64("user_id", userID), slog.Int("ads", len(ads)))
+	return nil
+}
+
+// checkRecentSignIn проверяет, что текущая сессия открыта недавно. Без пароля
+// это единственное подтверждение, что запрос делает владелец, а не тот, кому
+// достался долгоживущий refresh-токен.
+func (s *accountService) checkRecentSignIn(ctx context.Context, userID int64, sessionID string) error {
+	if sessionID == "" {
+		return ErrReauthRequired
+	}
+	sessions, err := s.sessionRepo.GetSessionsByUserID(ctx, userID, true)
+	if err != nil {
+		return fmt.Errorf("service.checkRecentSignIn: %w", err)
+	}
+	for _, session := range sessions {
+		if session.ID == sessionID && time.Since(session.CreatedAt) <= deleteReauthWindow {
+			return nil
+		}
+	}
+	return ErrReauthRequired
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
+	"marketplace/internal/repository/postgres"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type accountFixture struct {
+	repos   *postgres.Repository
+	users   *postgres.MockUserRepository
+	ads     *postgres.MockAdRepository
+	suggest *cache.MockSuggestRepository
+	auth    *MockAuthService
+	service AccountService
+}
+
+func newAccountFixture() *accountFixture {
+	f := &accountFixture{
+		repos: &postgres.Repository{
+			Report:       new(postgres.MockReportRepository),
+			Notification: new(postgres.MockNotificationRepository),
+			Session:      new(postgres.MockSessionRepository),
+			Audit:        new(postgres.MockAuditRepository),
+			APIKey:       new(postgres.MockAPIKeyRepository),
+			Identity:     new(postgres.MockIdentityRepository),
+		},
+		users:   new(postgres.MockUserRepository),
+		ads:     new(postgres.MockAdRepository),
+		suggest: new(cache.MockSuggestRepository),
+		auth:    new(MockAuthService),
+	}
+	f.repos.User, f.repos.Ad = f.users, f.ads
+	f.service = NewAccountService(f.repos, f.suggest, f.auth, testHasher, slog.New(slog.DiscardHandler))
+	return f
+}
+
+// Выгрузка собирает все разделы, включая скрытые объявления и историю входов
+func TestAccountService_Export(t *testing.T) {
+	f := newAccountFixture()
+	hiddenAt := time.Now().Add(-time.Hour)
+	userID := int64(7)
+
+	f.users.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: 7, Username: "seller"}, nil)
+	f.ads.On("GetAdsByUserID", mock.Anything, userID).Return([]models.Ad{{ID: 1}, {ID: 2, HiddenAt: &hiddenAt}}, nil)
+	f.repos.Report.(*postgres.MockReportRepository).On("GetReportsByReporterID", mock.Anything, userID).
+		Return([]models.Report{{ID: 3, ReporterID: &userID}}, nil)
+	f.repos.Notification.(*postgres.MockNotificationRepository).On("GetNotificationsByUserID", mock.Anything, userID, exportNotificationLimit).
+		Return([]models.Notification{}, nil)
+	f.repos.APIKey.(*postgres.MockAPIKeyRepository).On("GetAPIKeysByUserID", mock.Anything, userID).
+		Return([]models.APIKey{{ID: 4, Prefix: "mk_abcdefgh"}}, nil)
+	f.repos.Identity.(*postgres.MockIdentityRepository).On("GetIdentitiesByUserID", mock.Anything, userID).
+		Return([]models.ExternalIdentity{}, nil)
+	f.repos.Session.(*postgres.MockSessionRepository).On("GetSessionsByUserID", mock.Anything, userID, false).
+		Return([]models.Session{{ID: "session", UserAgent: "Firefox", CreatedAt: hiddenAt, LastSeenAt: hiddenAt}}, nil)
+	f.repos.Audit.(*postgres.MockAuditRepository).On("GetEntriesByUserID", mock.Anything, userID).
+		Return([]models.AuditEntry{{ID: 5, UserID: &userID, Action: models.AuditLoginLockout}}, nil)
+
+	export, err := f.service.Export(context.Background(), userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "seller", export.Profile.Username)
+	assert.Len(t, export.Ads, 2)
+	assert.Len(t, export.Reports, 1)
+	assert.Len(t, export.APIKeys, 1)
+	assert.Len(t, export.LoginHistory, 1)
+	assert.Len(t, export.SecurityEvents, 1)
+	assert.False(t, export.ExportedAt.IsZero())
+}
+
+func TestAccountService_Delete(t *testing.T) {
+	passwordHash, _ := testHasher.Hash("password123")
+	scheduled := time.Now().Add(time.Hour)
+
+	t.Run("Удаление с объявлениями", func(t *testing.T) {
+		f := newAccountFixture()
+		f.users.On("GetUserByID", mock.Anything, int64(7)).Return(&models.User{ID: 7, Password: passwordHash}, nil)
+		f.ads.On("GetAdsByUserID", mock.Anything, int64(7)).
+			Return([]models.Ad{{ID: 1, Title: "Диван"}, {ID: 2, Title: "Стол", PublishAt: &scheduled}}, nil)
+		f.auth.On("LogoutAll", mock.Anything, int64(7)).Return(nil)
+		f.users.On("DeleteUser", mock.Anything, int64(7)).Return(nil)
+		// Отложенное объявление еще не попало в подсказки.
+		f.suggest.On("RemoveTitle", mock.Anything, "Диван").Return(nil)
+
+		err := f.service.Delete(context.Background(), 7, "session", "password123")
+
+		assert.NoError(t, err)
+		f.users.AssertExpectations(t)
+		f.auth.AssertExpectations(t)
+		f.suggest.AssertExpectations(t)
+	})
+
+	t.Run("Неверный пароль", func(t *testing.T) {
+		f := newAccountFixture()
+		f.users.On("GetUserByID", mock.Anything, int64(7)).Return(&models.User{ID: 7, Password: passwordHash}, nil)
+
+		err := f.service.Delete(context.Background(), 7, "session", "wrong-password")
+
+		assert.ErrorIs(t, err, ErrWrongPassword)
+		f.users.AssertNotCalled(t, "DeleteUser", mock.Anything, mock.Anything)
+		f.auth.AssertNotCalled(t, "LogoutAll", mock.Anything, mock.Anything)
+	})
+
+	// Пользователь, вошедший через провайдера, подтверждает удаление свежим входом
+	reauthCases := []struct {
+		name      string
+		sessionID string
+		signedIn  time.Duration
+		expectErr error
+	}{
+		{name: "Без пароля после недавнего входа", sessionID: "fresh", signedIn: time.Minute},
+		{name: "Без пароля в давней сессии", sessionID: "fresh", signedIn: time.Hour, expectErr: ErrReauthRequired},
+		{name: "Без пароля в чужой сессии", sessionID: "other", signedIn: time.Minute, expectErr: ErrReauthRequired},
+	}
+
+	for _, tc := range reauthCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newAccountFixture()
+			f.users.On("GetUserByID", mock.Anything, int64(7)).Return(&models.User{ID: 7}, nil)
+			f.repos.Session.(*postgres.MockSessionRepository).On("GetSessionsByUserID", mock.Anything, int64(7), true).
+				Return([]models.Session{{ID: "fresh", CreatedAt: time.Now().Add(-tc.signedIn)}}, nil)
+			if tc.expectErr == nil {
+				f.ads.On("GetAdsByUserID", mock.Anything, int64(7)).Return([]models.Ad{}, nil)
+				f.auth.On("LogoutAll", mock.Anything, int64(7)).Return(nil)
+				f.users.On("DeleteUser", mock.Anything, int64(7)).Return(nil)
+			}
+
+			err := f.service.Delete(context.Background(), 7, tc.sessionID, "")
+
+			assert.ErrorIs(t, err, tc.expectErr)
+			f.users.AssertExpectations(t)
+			if tc.expectErr != nil {
+				f.users.AssertNotCalled(t, "DeleteUser", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
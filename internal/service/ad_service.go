@@ -1,69 +1,631 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
 	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/screening"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	maxTagsPerAd = 10
+	maxTagLength = 30
+
+	// maxPinnedAds - сколько продвигаемых объявлений закрепляется над первой страницей.
+	maxPinnedAds = 3
 )
 
+var (
+	ErrTooManyTags = errors.New("an ad can have at most 10 tags")
+	ErrInvalidTag  = errors.New("tag must be between 1 and 30 characters")
+	ErrDuplicateAd = errors.New("a similar ad was already published recently")
+
+	ErrPublishAtInPast    = errors.New("publish_at must be in the future")
+	ErrAdAlreadyPublished = errors.New("cannot schedule an ad that is already published")
+
+	ErrInvalidPatch    = errors.New("invalid patch")
+	ErrPatchTestFailed = errors.New("patch test operation failed")
+)
+
+// adValidator проверяет объявление по тегам binding, как это делает gin при создании.
+var adValidator = newAdValidator()
+
+func newAdValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		return strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+	})
+	return v
+}
+
+// InvalidAdError возвращается, когда объявление после изменения не проходит правила создания.
+type InvalidAdError struct {
+	Fields map[string]string
+}
+
+func (e *InvalidAdError) Error() string {
+	return fmt.Sprintf("invalid ad: %d field(s) failed validation", len(e.Fields))
+}
+
+func validateAd(req models.CreateAdRequest) error {
+	err := adValidator.Struct(req)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return fmt.Errorf("service.validateAd: %w", err)
+	}
+
+	fields := make(map[string]string, len(validationErrors))
+	for _, fe := range validationErrors {
+		fields[fe.Field()] = fmt.Sprintf("failed on the '%s' rule", fe.Tag())
+	}
+	return &InvalidAdError{Fields: fields}
+}
+
+// defaultDuplicateSimilarity - порог сходства текста, если он не задан в конфигурации.
+const defaultDuplicateSimilarity = 0.7
+
+// DuplicatePolicy задает реакцию на повторную публикацию объявления продавцом
+// в течение Window. Нулевое значение отключает проверку.
+//
+// Повтором считается объявление, текст которого похож не меньше чем на
+// Similarity, а цена отличается не больше чем на долю PriceTolerance.
+type DuplicatePolicy struct {
+	Action         screening.Action
+	Window         time.Duration
+	Similarity     float64
+	PriceTolerance float64
+}
+
+func (p DuplicatePolicy) enabled() bool {
+	return p.Action == screening.ActionReject || p.Action == screening.ActionPremoderate
+}
+
+func (p DuplicatePolicy) match() postgres.DuplicateMatch {
+	similarity := p.Similarity
+	if similarity <= 0 {
+		similarity = defaultDuplicateSimilarity
+	}
+	return postgres.DuplicateMatch{Similarity: similarity, PriceTolerance: p.PriceTolerance}
+}
+
+// ContentRejectedError возвращается, когда объявление не прошло автоматическую проверку.
+type ContentRejectedError struct {
+	Violations []screening.Violation
+}
+
+func (e *ContentRejectedError) Error() string {
+	return fmt.Sprintf("ad content rejected by %d screening rule(s)", len(e.Violations))
+}
+
+// Fields возвращает описание нарушения для каждого поля объявления.
+func (e *ContentRejectedError) Fields() map[string]string {
+	fields := make(map[string]string, len(e.Violations))
+	for _, v := range e.Violations {
+		if _, ok := fields[v.Field]; !ok {
+			fields[v.Field] = v.Message
+		}
+	}
+	return fields
+}
+
 type adService struct {
-	adRepo postgres.AdRepository
+	adRepo      postgres.AdRepository
+	suggestRepo cache.SuggestRepository
+	screener    *screening.Screener
+	duplicates  DuplicatePolicy
+	log         *slog.Logger
 }
 
-func NewAdService(adRepo postgres.AdRepository) *adService {
+func NewAdService(
+	adRepo postgres.AdRepository,
+	suggestRepo cache.SuggestRepository,
+	screener *screening.Screener,
+	duplicates DuplicatePolicy,
+	log *slog.Logger,
+) *adService {
 	return &adService{
-		adRepo: adRepo,
+		adRepo:      adRepo,
+		suggestRepo: suggestRepo,
+		screener:    screener,
+		duplicates:  duplicates,
+		log:         log,
 	}
 }
 
+// CreateAd сохраняет объявление после автоматической проверки содержимого и поиска
+// повторов среди недавних объявлений продавца. Объявление, требующее премодерации,
+// создается скрытым и попадает в очередь модерации.
 func (s *adService) CreateAd(ctx context.Context, ad *models.Ad) (int64, error) {
+	if ad.PublishAt != nil && !ad.PublishAt.After(time.Now()) {
+		return 0, ErrPublishAtInPast
+	}
+
+	tags, err := NormalizeTags(ad.Tags)
+	if err != nil {
+		return 0, err
+	}
+	ad.Tags = tags
+
+	if err := s.screen(ad); err != nil {
+		return 0, err
+	}
+
+	ad.NormalizedText = screening.Normalize(ad.Title, ad.Description)
+	if err := s.checkDuplicate(ctx, ad); err != nil {
+		return 0, err
+	}
+
 	id, err := s.adRepo.CreateAd(ctx, ad)
 	if err != nil {
 		return 0, fmt.Errorf("service.CreateAd: %w", err)
 	}
+
+	// Отложенное объявление попадет в подсказки при публикации, а отправленное
+	// на премодерацию - после одобрения модератором.
+	if titleIndexed(ad) {
+		s.indexTitle(ctx, ad.Title)
+	}
+
 	return id, nil
 }
 
 func (s *adService) GetAllAds(ctx context.Context, params postgres.GetAllAdsParams) ([]models.Ad, error) {
+	const op = "service.GetAllAds"
+
+	params.Tag = normalizeTag(params.Tag)
+
 	ads, err := s.adRepo.GetAllAds(ctx, params)
 	if err != nil {
-		return nil, fmt.Errorf("service.GetAllAds: %w", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if params.Offset == 0 {
+		ads, err = s.pinPromoted(ctx, params, ads)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if params.Search != "" {
+			// Учитываем запрос только на первой странице, чтобы пролистывание
+			// результатов не накручивало популярность. Повторы одного клиента
+			// отсеивает хранилище подсказок.
+			client := clientInfoFromContext(ctx).IP
+			if err := s.suggestRepo.RecordQuery(ctx, client, params.Search); err != nil {
+				s.log.Warn("failed to record search query", slog.String("error", err.Error()))
+			}
+		}
 	}
 
 	return ads, nil
 }
 
-func (s *adService) GetAdByID(ctx context.Context, id int64) (*models.Ad, error) {
-	return s.adRepo.GetAdByID(ctx, id)
+// screen прогоняет объявление через правила проверки. При нарушениях с действием
+// premoderate объявление помечается скрытым с заметкой для модератора.
+func (s *adService) screen(ad *models.Ad) error {
+	result := s.screener.Screen(screening.Content{Title: ad.Title, Description: ad.Description})
+
+	if rejections := result.Rejections(); len(rejections) > 0 {
+		return &ContentRejectedError{Violations: rejections}
+	}
+
+	if result.NeedsPremoderation() {
+		for _, v := range result.Violations {
+			markForReview(ad, fmt.Sprintf("%s (%s): %s", v.Rule, v.Field, v.Message))
+		}
+	}
+
+	return nil
+}
+
+// checkDuplicate ищет у продавца похожее объявление в пределах окна. Само
+// объявление при изменении в сравнении не участвует.
+func (s *adService) checkDuplicate(ctx context.Context, ad *models.Ad) error {
+	if !s.duplicates.enabled() {
+		return nil
+	}
+
+	duplicate, err := s.adRepo.HasRecentDuplicate(ctx, postgres.DuplicateQuery{
+		DuplicateMatch: s.duplicates.match(),
+		UserID:         ad.UserID,
+		ExcludeID:      ad.ID,
+		Text:           ad.NormalizedText,
+		Price:          ad.Price,
+		Since:          time.Now().Add(-s.duplicates.Window),
+	})
+	if err != nil {
+		return fmt.Errorf("service.checkDuplicate: %w", err)
+	}
+	if !duplicate {
+		return nil
+	}
+
+	if s.duplicates.Action == screening.ActionReject {
+		return ErrDuplicateAd
+	}
+	markForReview(ad, "duplicates: a similar ad was already published recently")
+	return nil
+}
+
+// markForReview скрывает объявление до проверки модератором и дополняет заметку для него.
+func markForReview(ad *models.Ad, note string) {
+	if ad.HiddenAt == nil {
+		now := time.Now()
+		ad.HiddenAt = &now
+	}
+	if ad.ScreeningNote != "" {
+		ad.ScreeningNote += "; "
+	}
+	ad.ScreeningNote += note
+}
+
+// pinPromoted закрепляет продвигаемые объявления над первой страницей выдачи.
+// Органическая выдача не сдвигается: страница 2 начинается с того же смещения,
+// а дубликаты закрепленных объявлений лишь убираются с первой страницы.
+func (s *adService) pinPromoted(ctx context.Context, params postgres.GetAllAdsParams, organic []models.Ad) ([]models.Ad, error) {
+	params.PromotedOnly = true
+	params.Limit = maxPinnedAds
+	promoted, err := s.adRepo.GetAllAds(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(promoted) == 0 {
+		return organic, nil
+	}
+
+	pinned := make(map[int64]struct{}, len(promoted))
+	result := make([]models.Ad, 0, len(promoted)+len(organic))
+	for _, ad := range promoted {
+		ad.Promoted = true
+		pinned[ad.ID] = struct{}{}
+		result = append(result, ad)
+	}
+	for _, ad := range organic {
+		if _, ok := pinned[ad.ID]; !ok {
+			result = append(result, ad)
+		}
+	}
+	return result, nil
 }
 
-func (s *adService) UpdateAd(ctx context.Context, id, userID int64, req models.UpdateAdRequest) (*models.Ad, error) {
+// GetAdByID возвращает объявление, если оно доступно пользователю: скрытые
+// модератором и еще не опубликованные объявления видят только их владельцы и модераторы.
+func (s *adService) GetAdByID(ctx context.Context, id int64, actor models.Actor) (*models.Ad, error) {
 	ad, err := s.adRepo.GetAdByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	if ad.UserID != userID {
-		return nil, postgres.ErrAdAccessDenied
+	restricted := ad.HiddenAt != nil || ad.IsScheduled(time.Now())
+	if restricted && ad.UserID != actor.UserID && !actor.Can(models.PermissionViewHiddenAds) {
+		return nil, postgres.ErrAdNotFound
 	}
 
+	return ad, nil
+}
+
+// UpdateAd частично обновляет объявление: меняются только переданные поля.
+func (s *adService) UpdateAd(ctx context.Context, id int64, actor models.Actor, req models.UpdateAdRequest) (*models.Ad, error) {
+	ad, err := s.getEditableAd(ctx, id, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	next := editableAd(ad)
 	if req.Title != nil {
-		ad.Title = *req.Title
+		next.Title = *req.Title
 	}
 	if req.Description != nil {
-		ad.Description = *req.Description
+		next.Description = *req.Description
 	}
 	if req.Price != nil {
-		ad.Price = *req.Price
+		next.Price = *req.Price
+	}
+	if req.Tags != nil {
+		next.Tags = *req.Tags
+	}
+
+	return s.saveUpdate(ctx, ad, next)
+}
+
+// PatchAd применяет к объявлению JSON Merge Patch (RFC 7396) или JSON Patch (RFC 6902).
+// Патч применяется к документу с редактируемыми полями объявления, а результат
+// проверяется по тем же правилам, что и при создании.
+func (s *adService) PatchAd(ctx context.Context, id int64, actor models.Actor, patch models.AdPatch) (*models.Ad, error) {
+	ad, err := s.getEditableAd(ctx, id, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := json.Marshal(editableAd(ad))
+	if err != nil {
+		return nil, fmt.Errorf("service.PatchAd: %w", err)
+	}
+
+	patched, err := applyPatch(patch, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var next models.CreateAdRequest
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&next); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	return s.saveUpdate(ctx, ad, next)
+}
+
+func applyPatch(patch models.AdPatch, doc []byte) ([]byte, error) {
+	switch patch.ContentType {
+	case models.MergePatchContentType:
+		patched, err := jsonpatch.MergePatch(doc, patch.Body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		return patched, nil
+	case models.JSONPatchContentType:
+		ops, err := jsonpatch.DecodePatch(patch.Body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		patched, err := ops.Apply(doc)
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return nil, fmt.Errorf("%w: %v", ErrPatchTestFailed, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		return patched, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported content type %q", ErrInvalidPatch, patch.ContentType)
+	}
+}
+
+// getEditableAd возвращает объявление, если пользователь может его изменять.
+// Модератор редактирует чужое объявление от имени владельца.
+func (s *adService) getEditableAd(ctx context.Context, id int64, actor models.Actor) (*models.Ad, error) {
+	ad, err := s.adRepo.GetAdByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !actor.CanEditAd(ad) {
+		return nil, postgres.ErrAdAccessDenied
+	}
+	return ad, nil
+}
+
+// editableAd возвращает редактируемые поля объявления в виде запроса на создание,
+// чтобы новое состояние проверялось по тем же правилам.
+func editableAd(ad *models.Ad) models.CreateAdRequest {
+	tags := ad.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return models.CreateAdRequest{
+		Title:       ad.Title,
+		Description: ad.Description,
+		Price:       ad.Price,
+		ImageURL:    ad.ImageURL,
+		Tags:        tags,
+		PublishAt:   ad.PublishAt,
+	}
+}
+
+// saveUpdate проверяет новое состояние объявления и сохраняет его.
+func (s *adService) saveUpdate(ctx context.Context, ad *models.Ad, next models.CreateAdRequest) (*models.Ad, error) {
+	if err := validateAd(next); err != nil {
+		return nil, err
+	}
+
+	tags, err := NormalizeTags(next.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	wasIndexed := titleIndexed(ad)
+	if err := reschedule(ad, next.PublishAt, time.Now()); err != nil {
+		return nil, err
+	}
+
+	oldTitle := ad.Title
+	ad.Title = next.Title
+	ad.Description = next.Description
+	ad.Price = next.Price
+	ad.ImageURL = next.ImageURL
+	ad.Tags = tags
+
+	if err := s.screen(ad); err != nil {
+		return nil, err
+	}
+	ad.NormalizedText = screening.Normalize(ad.Title, ad.Description)
+	if err := s.checkDuplicate(ctx, ad); err != nil {
+		return nil, err
 	}
 
 	if err := s.adRepo.UpdateAd(ctx, ad); err != nil {
 		return nil, err
 	}
+
+	switch isIndexed := titleIndexed(ad); {
+	case !wasIndexed && isIndexed:
+		s.indexTitle(ctx, ad.Title)
+	case wasIndexed && !isIndexed:
+		s.removeTitle(ctx, oldTitle)
+	case wasIndexed && ad.Title != oldTitle:
+		s.removeTitle(ctx, oldTitle)
+		s.indexTitle(ctx, ad.Title)
+	}
+
 	return ad, nil
 }
 
+// reschedule применяет новое время публикации. Перенести или отменить отложенную
+// публикацию (null - опубликовать сразу) можно, пока объявление не опубликовано.
+func reschedule(ad *models.Ad, publishAt *time.Time, now time.Time) error {
+	if publishAt == nil && ad.PublishAt == nil {
+		return nil
+	}
+	if publishAt != nil && ad.PublishAt != nil && publishAt.Equal(*ad.PublishAt) {
+		return nil
+	}
+
+	if publishAt != nil {
+		if !ad.IsScheduled(now) {
+			return ErrAdAlreadyPublished
+		}
+		if !publishAt.After(now) {
+			return ErrPublishAtInPast
+		}
+	}
+	ad.PublishAt = publishAt
+	return nil
+}
+
+// PublishScheduled публикует отложенные объявления, время которых наступило,
+// и добавляет заголовки нескрытых из них в подсказки. Возвращает число опубликованных объявлений.
+func (s *adService) PublishScheduled(ctx context.Context) (int, error) {
+	ads, err := s.adRepo.PublishScheduled(ctx, time.Now())
+	if err != nil {
+		if len(ads) == 0 {
+			return 0, fmt.Errorf("service.PublishScheduled: %w", err)
+		}
+		// Объявления уже опубликованы, не удалось лишь сбросить кеш списка.
+		s.log.Warn("failed to invalidate ad list cache", slog.String("error", err.Error()))
+	}
+
+	for _, ad := range ads {
+		// Скрытое модератором объявление публикуется по расписанию, но в
+		// подсказки не попадает.
+		if ad.HiddenAt != nil {
+			continue
+		}
+		s.indexTitle(ctx, ad.Title)
+	}
+
+	return len(ads), nil
+}
+
 func (s *adService) DeleteAd(ctx context.Context, id, userID int64) error {
-	return s.adRepo.DeleteAd(ctx, id, userID)
+	ad, err := s.adRepo.GetAdByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.adRepo.DeleteAd(ctx, id, userID); err != nil {
+		return err
+	}
+
+	if titleIndexed(ad) {
+		s.removeTitle(ctx, ad.Title)
+	}
+
+	return nil
+}
+
+// HideAd снимает объявление с публикации без жалобы. Доступно только
+// пользователям с разрешением PermissionHideAnyAd.
+func (s *adService) HideAd(ctx context.Context, id int64, actor models.Actor) error {
+	if !actor.Can(models.PermissionHideAnyAd) {
+		return postgres.ErrAdAccessDenied
+	}
+
+	ad, err := s.adRepo.GetAdByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.adRepo.HideAd(ctx, id); err != nil {
+		if errors.Is(err, postgres.ErrAdNotFound) {
+			return err
+		}
+		return fmt.Errorf("service.HideAd: %w", err)
+	}
+
+	if titleIndexed(ad) {
+		s.removeTitle(ctx, ad.Title)
+	}
+	return nil
+}
+
+func (s *adService) Suggest(ctx context.Context, prefix string, limit int) (*models.SuggestResponse, error) {
+	const op = "service.Suggest"
+
+	titles, err := s.suggestRepo.SuggestTitles(ctx, prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	queries, err := s.suggestRepo.SuggestQueries(ctx, prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &models.SuggestResponse{Titles: titles, Queries: queries}, nil
+}
+
+// titleIndexed сообщает, есть ли заголовок объявления в индексе подсказок:
+// туда попадают только опубликованные и не скрытые объявления.
+func titleIndexed(ad *models.Ad) bool {
+	return ad.PublishAt == nil && ad.HiddenAt == nil
+}
+
+// indexTitle и removeTitle поддерживают индекс подсказок. Индекс вторичен
+// по отношению к БД, поэтому его ошибки только логируются.
+func (s *adService) indexTitle(ctx context.Context, title string) {
+	if err := s.suggestRepo.IndexTitle(ctx, title); err != nil {
+		s.log.Warn("failed to index ad title", slog.String("error", err.Error()))
+	}
+}
+
+func (s *adService) removeTitle(ctx context.Context, title string) {
+	if err := s.suggestRepo.RemoveTitle(ctx, title); err != nil {
+		s.log.Warn("failed to remove ad title from index", slog.String("error", err.Error()))
+	}
+}
+
+// NormalizeTags приводит теги к нижнему регистру, убирает лишние пробелы и
+// дубликаты, сохраняя порядок, и проверяет ограничения на их количество и длину.
+func NormalizeTags(tags []string) ([]string, error) {
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		normalized := normalizeTag(tag)
+		if normalized == "" || utf8.RuneCountInString(normalized) > maxTagLength {
+			return nil, ErrInvalidTag
+		}
+		if _, ok := seen[normalized]; ok {
+			continue
+		}
+		seen[normalized] = struct{}{}
+		result = append(result, normalized)
+	}
+
+	if len(result) > maxTagsPerAd {
+		return nil, ErrTooManyTags
+	}
+	return result, nil
+}
+
+func normalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), " ")
 }
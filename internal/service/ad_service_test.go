@@ -2,9 +2,16 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
 	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/screening"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -14,7 +21,8 @@ import (
 func TestAdService_CreateAd_Success(t *testing.T) {
 	// 1. Настройка
 	mockAdRepo := new(postgres.MockAdRepository)
-	adService := NewAdService(mockAdRepo)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
 
 	ad := &models.Ad{
 		UserID:      1,
@@ -25,6 +33,8 @@ func TestAdService_CreateAd_Success(t *testing.T) {
 
 	// Ожидаем вызов CreateAd и возвращаем ID 1
 	mockAdRepo.On("CreateAd", mock.Anything, ad).Return(int64(1), nil)
+	// После сохранения заголовок попадает в индекс подсказок
+	mockSuggestRepo.On("IndexTitle", mock.Anything, "Test Ad").Return(nil)
 
 	// 2. Действие
 	id, err := adService.CreateAd(context.Background(), ad)
@@ -33,13 +43,15 @@ func TestAdService_CreateAd_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), id)
 	mockAdRepo.AssertExpectations(t)
+	mockSuggestRepo.AssertExpectations(t)
 }
 
 // Тестирование успешного обновления объявления владельцем
 func TestAdService_UpdateAd_Success(t *testing.T) {
 	// 1. Настройка
 	mockAdRepo := new(postgres.MockAdRepository)
-	adService := NewAdService(mockAdRepo)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
 
 	adID := int64(1)
 	userID := int64(1) // Владелец
@@ -65,22 +77,27 @@ func TestAdService_UpdateAd_Success(t *testing.T) {
 	mockAdRepo.On("UpdateAd", mock.Anything, mock.MatchedBy(func(ad *models.Ad) bool {
 		return ad.Title == newTitle && ad.ID == adID
 	})).Return(nil)
+	// Заголовок изменился, поэтому индекс подсказок обновляется
+	mockSuggestRepo.On("RemoveTitle", mock.Anything, "Old Title").Return(nil)
+	mockSuggestRepo.On("IndexTitle", mock.Anything, newTitle).Return(nil)
 
 	// 2. Действие
-	updatedAd, err := adService.UpdateAd(context.Background(), adID, userID, updateReq)
+	updatedAd, err := adService.UpdateAd(context.Background(), adID, models.Actor{UserID: userID, Role: models.RoleUser}, updateReq)
 
 	// 3. Утверждение
 	assert.NoError(t, err)
 	assert.NotNil(t, updatedAd)
 	assert.Equal(t, newTitle, updatedAd.Title)
 	mockAdRepo.AssertExpectations(t)
+	mockSuggestRepo.AssertExpectations(t)
 }
 
 // Тестирование попытки обновления чужого объявления
 func TestAdService_UpdateAd_AccessDenied(t *testing.T) {
 	// 1. Настройка
 	mockAdRepo := new(postgres.MockAdRepository)
-	adService := NewAdService(mockAdRepo)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
 
 	adID := int64(1)
 	ownerID := int64(1)    // Владелец
@@ -95,7 +112,7 @@ func TestAdService_UpdateAd_AccessDenied(t *testing.T) {
 	// Метод UpdateAd не должен быть вызван!
 
 	// 2. Действие
-	_, err := adService.UpdateAd(context.Background(), adID, notOwnerID, updateReq)
+	_, err := adService.UpdateAd(context.Background(), adID, models.Actor{UserID: notOwnerID, Role: models.RoleUser}, updateReq)
 
 	// 3. Утверждение
 	assert.Error(t, err)
@@ -103,17 +120,91 @@ func TestAdService_UpdateAd_AccessDenied(t *testing.T) {
 	mockAdRepo.AssertExpectations(t)
 }
 
+// Модератор может изменить чужое объявление, владелец при этом не меняется
+func TestAdService_UpdateAd_Moderator(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	existingAd := &models.Ad{ID: 1, UserID: 1, Title: "Old Title", Description: "Old Description", Price: 100}
+	newTitle := "New Title"
+
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(existingAd, nil)
+	mockAdRepo.On("UpdateAd", mock.Anything, mock.MatchedBy(func(ad *models.Ad) bool {
+		return ad.Title == newTitle && ad.UserID == 1
+	})).Return(nil)
+	mockSuggestRepo.On("RemoveTitle", mock.Anything, "Old Title").Return(nil)
+	mockSuggestRepo.On("IndexTitle", mock.Anything, newTitle).Return(nil)
+
+	moderator := models.Actor{UserID: 5, Role: models.RoleModerator}
+	_, err := adService.UpdateAd(context.Background(), 1, moderator, models.UpdateAdRequest{Title: &newTitle})
+
+	assert.NoError(t, err)
+	mockAdRepo.AssertExpectations(t)
+}
+
+func TestAdService_HideAd(t *testing.T) {
+	testCases := []struct {
+		name      string
+		role      string
+		expectErr error
+	}{
+		{name: "Модератор", role: models.RoleModerator},
+		{name: "Администратор", role: models.RoleAdmin},
+		{name: "Обычный пользователь", role: models.RoleUser, expectErr: postgres.ErrAdAccessDenied},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockAdRepo := new(postgres.MockAdRepository)
+			mockSuggestRepo := new(cache.MockSuggestRepository)
+			adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+			if tc.expectErr == nil {
+				mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(&models.Ad{ID: 1, UserID: 2, Title: "Диван"}, nil)
+				mockAdRepo.On("HideAd", mock.Anything, int64(1)).Return(nil)
+				mockSuggestRepo.On("RemoveTitle", mock.Anything, "Диван").Return(nil)
+			}
+
+			err := adService.HideAd(context.Background(), 1, models.Actor{UserID: 5, Role: tc.role})
+
+			assert.ErrorIs(t, err, tc.expectErr)
+			mockAdRepo.AssertExpectations(t)
+			mockSuggestRepo.AssertExpectations(t)
+		})
+	}
+}
+
+// Заголовок уже скрытого объявления в подсказках отсутствует и не удаляется повторно
+func TestAdService_HideAd_AlreadyHidden(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	hiddenAt := time.Now()
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(&models.Ad{ID: 1, UserID: 2, Title: "Диван", HiddenAt: &hiddenAt}, nil)
+	mockAdRepo.On("HideAd", mock.Anything, int64(1)).Return(nil)
+
+	err := adService.HideAd(context.Background(), 1, models.Actor{UserID: 5, Role: models.RoleModerator})
+
+	assert.NoError(t, err)
+	mockSuggestRepo.AssertNotCalled(t, "RemoveTitle", mock.Anything, mock.Anything)
+}
+
 // Тестирование успешного удаления объявления владельцем
 func TestAdService_DeleteAd_Success(t *testing.T) {
 	// 1. Настройка
 	mockAdRepo := new(postgres.MockAdRepository)
-	adService := NewAdService(mockAdRepo)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
 
 	adID := int64(1)
 	userID := int64(1)
 
+	// Сервис сначала получает объявление, чтобы убрать его заголовок из индекса
+	mockAdRepo.On("GetAdByID", mock.Anything, adID).Return(&models.Ad{ID: adID, UserID: userID, Title: "Test Ad"}, nil)
 	// Ожидаем вызов DeleteAd с правильными ID
 	mockAdRepo.On("DeleteAd", mock.Anything, adID, userID).Return(nil)
+	mockSuggestRepo.On("RemoveTitle", mock.Anything, "Test Ad").Return(nil)
 
 	// 2. Действие
 	err := adService.DeleteAd(context.Background(), adID, userID)
@@ -121,4 +212,485 @@ func TestAdService_DeleteAd_Success(t *testing.T) {
 	// 3. Утверждение
 	assert.NoError(t, err)
 	mockAdRepo.AssertExpectations(t)
+	mockSuggestRepo.AssertExpectations(t)
+}
+
+// Ошибка индекса подсказок не должна ломать создание объявления
+func TestAdService_CreateAd_IndexFailureIgnored(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	ad := &models.Ad{UserID: 1, Title: "Test Ad", Description: "Test Description", Price: 100.0}
+
+	mockAdRepo.On("CreateAd", mock.Anything, ad).Return(int64(1), nil)
+	mockSuggestRepo.On("IndexTitle", mock.Anything, "Test Ad").Return(errors.New("redis is down"))
+
+	id, err := adService.CreateAd(context.Background(), ad)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+	mockSuggestRepo.AssertExpectations(t)
+}
+
+// Тестирование получения подсказок
+func TestAdService_Suggest(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	mockSuggestRepo.On("SuggestTitles", mock.Anything, "ipho", 5).Return([]string{"iphone 13", "iphone 12"}, nil)
+	mockSuggestRepo.On("SuggestQueries", mock.Anything, "ipho", 5).Return([]string{"iphone"}, nil)
+
+	res, err := adService.Suggest(context.Background(), "ipho", 5)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"iphone 13", "iphone 12"}, res.Titles)
+	assert.Equal(t, []string{"iphone"}, res.Queries)
+	mockSuggestRepo.AssertExpectations(t)
+}
+
+// Тестирование нормализации тегов
+func TestNormalizeTags(t *testing.T) {
+	testCases := []struct {
+		name        string
+		input       []string
+		expected    []string
+		expectedErr error
+	}{
+		{
+			name:     "Регистр, пробелы и дубликаты",
+			input:    []string{" Vintage ", "HandMade", "vintage", "hand   made"},
+			expected: []string{"vintage", "handmade", "hand made"},
+		},
+		{
+			name:        "Пустой тег",
+			input:       []string{"vintage", "   "},
+			expectedErr: ErrInvalidTag,
+		},
+		{
+			name:        "Слишком длинный тег",
+			input:       []string{"очень-длинный-тег-который-не-влезает"},
+			expectedErr: ErrInvalidTag,
+		},
+		{
+			name:        "Слишком много тегов",
+			input:       []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"},
+			expectedErr: ErrTooManyTags,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tags, err := NormalizeTags(tc.input)
+			assert.Equal(t, tc.expectedErr, err)
+			if tc.expectedErr == nil {
+				assert.Equal(t, tc.expected, tags)
+			}
+		})
+	}
+}
+
+// Объявление с некорректными тегами не должно попадать в БД
+func TestAdService_CreateAd_InvalidTags(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	ad := &models.Ad{UserID: 1, Title: "Test Ad", Tags: []string{""}}
+
+	_, err := adService.CreateAd(context.Background(), ad)
+
+	assert.ErrorIs(t, err, ErrInvalidTag)
+	mockAdRepo.AssertNotCalled(t, "CreateAd", mock.Anything, mock.Anything)
+}
+
+// Продвигаемые объявления закрепляются над первой страницей без дубликатов
+func TestAdService_GetAllAds_PinsPromoted(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	params := postgres.GetAllAdsParams{Limit: 3, Offset: 0, SortBy: "created_at", SortOrder: "desc"}
+	promotedParams := params
+	promotedParams.PromotedOnly = true
+	promotedParams.Limit = maxPinnedAds
+
+	mockAdRepo.On("GetAllAds", mock.Anything, params).
+		Return([]models.Ad{{ID: 1}, {ID: 2}, {ID: 3}}, nil)
+	mockAdRepo.On("GetAllAds", mock.Anything, promotedParams).
+		Return([]models.Ad{{ID: 7}, {ID: 2}}, nil)
+
+	ads, err := adService.GetAllAds(context.Background(), params)
+
+	assert.NoError(t, err)
+	var ids []int64
+	for _, ad := range ads {
+		ids = append(ids, ad.ID)
+	}
+	assert.Equal(t, []int64{7, 2, 1, 3}, ids)
+	assert.True(t, ads[0].Promoted)
+	assert.True(t, ads[1].Promoted)
+	assert.False(t, ads[2].Promoted)
+	mockAdRepo.AssertExpectations(t)
+}
+
+// На последующих страницах продвигаемые объявления не запрашиваются
+func TestAdService_GetAllAds_NextPageNotPinned(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	params := postgres.GetAllAdsParams{Limit: 3, Offset: 3, SortBy: "created_at", SortOrder: "desc"}
+	mockAdRepo.On("GetAllAds", mock.Anything, params).Return([]models.Ad{{ID: 4}}, nil)
+
+	ads, err := adService.GetAllAds(context.Background(), params)
+
+	assert.NoError(t, err)
+	assert.Len(t, ads, 1)
+	mockAdRepo.AssertNumberOfCalls(t, "GetAllAds", 1)
+}
+
+// Поисковый запрос первой страницы учитывается от имени клиента, чтобы
+// хранилище подсказок отсеивало его повторы
+func TestAdService_GetAllAds_RecordsQuery(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	params := postgres.GetAllAdsParams{Limit: 3, Search: "диван"}
+	mockAdRepo.On("GetAllAds", mock.Anything, mock.AnythingOfType("postgres.GetAllAdsParams")).Return([]models.Ad{}, nil)
+	mockSuggestRepo.On("RecordQuery", mock.Anything, "203.0.113.7", "диван").Return(errors.New("redis is down"))
+
+	ctx := WithClientInfo(context.Background(), models.ClientInfo{IP: "203.0.113.7"})
+	_, err := adService.GetAllAds(ctx, params)
+
+	// Ошибка учета запроса не мешает выдаче
+	assert.NoError(t, err)
+	mockSuggestRepo.AssertExpectations(t)
+
+	params.Offset = 3
+	_, err = adService.GetAllAds(ctx, params)
+	assert.NoError(t, err)
+	mockSuggestRepo.AssertNumberOfCalls(t, "RecordQuery", 1)
+}
+
+// Скрытое объявление видят только владелец и модераторы
+func TestAdService_GetAdByID_Hidden(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	hiddenAt := time.Now()
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(&models.Ad{ID: 1, UserID: 10, HiddenAt: &hiddenAt}, nil)
+
+	_, err := adService.GetAdByID(context.Background(), 1, models.Actor{})
+	assert.ErrorIs(t, err, postgres.ErrAdNotFound)
+
+	_, err = adService.GetAdByID(context.Background(), 1, models.Actor{UserID: 20, Role: models.RoleUser})
+	assert.ErrorIs(t, err, postgres.ErrAdNotFound)
+
+	ad, err := adService.GetAdByID(context.Background(), 1, models.Actor{UserID: 10, Role: models.RoleUser})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), ad.ID)
+
+	_, err = adService.GetAdByID(context.Background(), 1, models.Actor{UserID: 30, Role: models.RoleModerator})
+	assert.NoError(t, err)
+}
+
+func newTestScreener() *screening.Screener {
+	return screening.NewScreener().
+		Add(screening.NewBannedWordsRule([]string{"казино"}), screening.ActionReject).
+		Add(screening.NewContactsRule(), screening.ActionPremoderate).
+		Add(screening.NewCapsRule(0.7, 10), screening.ActionReject)
+}
+
+// Объявление с запрещенными словами (в том числе замаскированными латиницей)
+// и заголовком капсом отклоняется с ошибками по полям и не сохраняется
+func TestAdService_CreateAd_Rejected(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, newTestScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	ad := &models.Ad{
+		UserID:      1,
+		Title:       "СРОЧНО ПРОДАМ ДИВАН",
+		Description: "Приглашаю в онлайн-кaзинo",
+		Price:       100.0,
+	}
+
+	_, err := adService.CreateAd(context.Background(), ad)
+
+	var rejected *ContentRejectedError
+	assert.True(t, errors.As(err, &rejected))
+	assert.Equal(t, map[string]string{
+		screening.FieldTitle:       "too many capital letters",
+		screening.FieldDescription: "contains a prohibited word",
+	}, rejected.Fields())
+	mockAdRepo.AssertNotCalled(t, "CreateAd", mock.Anything, mock.Anything)
+}
+
+// Объявление с телефоном в описании создается скрытым и уходит на премодерацию
+func TestAdService_CreateAd_Premoderation(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, newTestScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	ad := &models.Ad{
+		UserID:      1,
+		Title:       "Продам диван",
+		Description: "Звоните: +7 (912) 345-67-89",
+		Price:       100.0,
+	}
+
+	mockAdRepo.On("CreateAd", mock.Anything, mock.MatchedBy(func(a *models.Ad) bool {
+		return a.HiddenAt != nil && a.ScreeningNote != ""
+	})).Return(int64(1), nil)
+
+	id, err := adService.CreateAd(context.Background(), ad)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+	assert.Contains(t, ad.ScreeningNote, "contacts")
+	mockAdRepo.AssertExpectations(t)
+	mockSuggestRepo.AssertNotCalled(t, "IndexTitle", mock.Anything, mock.Anything)
+}
+
+// Повтор недавнего объявления продавца отклоняется без сохранения
+func TestAdService_CreateAd_DuplicateRejected(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	policy := DuplicatePolicy{Action: screening.ActionReject, Window: 72 * time.Hour}
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), policy, slog.New(slog.DiscardHandler))
+
+	ad := &models.Ad{UserID: 1, Title: "Продам  Диван!", Description: "Почти новый", Price: 1000}
+	// Текст сравнивается без учета регистра, пунктуации и лишних пробелов,
+	// а пороги без явной настройки берутся по умолчанию
+	mockAdRepo.On("HasRecentDuplicate", mock.Anything, mock.MatchedBy(func(q postgres.DuplicateQuery) bool {
+		return q.UserID == 1 && q.ExcludeID == 0 && q.Text == screening.Normalize("продам диван", "почти новый") && q.Price == 1000 &&
+			q.Similarity == defaultDuplicateSimilarity && time.Since(q.Since) >= 72*time.Hour
+	})).Return(true, nil)
+
+	_, err := adService.CreateAd(context.Background(), ad)
+
+	assert.ErrorIs(t, err, ErrDuplicateAd)
+	mockAdRepo.AssertNotCalled(t, "CreateAd", mock.Anything, mock.Anything)
+}
+
+// При действии premoderate повтор создается скрытым и уходит модератору
+func TestAdService_CreateAd_DuplicatePremoderation(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	policy := DuplicatePolicy{Action: screening.ActionPremoderate, Window: 72 * time.Hour}
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), policy, slog.New(slog.DiscardHandler))
+
+	ad := &models.Ad{UserID: 1, Title: "Продам диван", Description: "Почти новый", Price: 1000}
+	mockAdRepo.On("HasRecentDuplicate", mock.Anything, mock.AnythingOfType("postgres.DuplicateQuery")).Return(true, nil)
+	mockAdRepo.On("CreateAd", mock.Anything, mock.MatchedBy(func(a *models.Ad) bool {
+		return a.HiddenAt != nil && a.NormalizedText != ""
+	})).Return(int64(2), nil)
+
+	id, err := adService.CreateAd(context.Background(), ad)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), id)
+	assert.Contains(t, ad.ScreeningNote, "duplicates")
+	mockAdRepo.AssertExpectations(t)
+	mockSuggestRepo.AssertNotCalled(t, "IndexTitle", mock.Anything, mock.Anything)
+}
+
+// Изменение, превращающее объявление в повтор другого, проверяется так же, как
+// создание; само объявление в сравнении не участвует
+func TestAdService_UpdateAd_Duplicate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		action screening.Action
+		err    error
+	}{
+		{name: "Отклонение", action: screening.ActionReject, err: ErrDuplicateAd},
+		{name: "Премодерация", action: screening.ActionPremoderate},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockAdRepo := new(postgres.MockAdRepository)
+			mockSuggestRepo := new(cache.MockSuggestRepository)
+			policy := DuplicatePolicy{Action: tc.action, Window: 72 * time.Hour, Similarity: 0.8, PriceTolerance: 0.1}
+			adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), policy, slog.New(slog.DiscardHandler))
+
+			existingAd := &models.Ad{ID: 7, UserID: 1, Title: "Продам кресло", Description: "Почти новое", Price: 900}
+			mockAdRepo.On("GetAdByID", mock.Anything, int64(7)).Return(existingAd, nil)
+			mockAdRepo.On("HasRecentDuplicate", mock.Anything, mock.MatchedBy(func(q postgres.DuplicateQuery) bool {
+				return q.ExcludeID == 7 && q.UserID == 1 && q.Text == screening.Normalize("Продам диван", "Почти новое") &&
+					q.Similarity == 0.8 && q.PriceTolerance == 0.1
+			})).Return(true, nil)
+			mockAdRepo.On("UpdateAd", mock.Anything, mock.MatchedBy(func(a *models.Ad) bool {
+				return a.HiddenAt != nil && strings.Contains(a.ScreeningNote, "duplicates")
+			})).Return(nil)
+			mockSuggestRepo.On("RemoveTitle", mock.Anything, "Продам кресло").Return(nil)
+
+			title := "Продам диван"
+			_, err := adService.UpdateAd(context.Background(), 7, models.Actor{UserID: 1, Role: models.RoleUser}, models.UpdateAdRequest{Title: &title})
+
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				mockAdRepo.AssertNotCalled(t, "UpdateAd", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			mockAdRepo.AssertExpectations(t)
+			mockSuggestRepo.AssertExpectations(t)
+		})
+	}
+}
+
+// JSON Merge Patch меняет заголовок и очищает ссылку на изображение через null
+func TestAdService_PatchAd_MergePatch(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	existingAd := &models.Ad{ID: 1, UserID: 1, Title: "Old Title", Description: "Desc", Price: 100, ImageURL: "https://example.com/a.png", Tags: []string{"мебель"}}
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(existingAd, nil)
+	mockAdRepo.On("UpdateAd", mock.Anything, mock.MatchedBy(func(ad *models.Ad) bool {
+		return ad.Title == "New Title" && ad.ImageURL == "" && ad.Price == 100 && len(ad.Tags) == 1
+	})).Return(nil)
+	mockSuggestRepo.On("RemoveTitle", mock.Anything, "Old Title").Return(nil)
+	mockSuggestRepo.On("IndexTitle", mock.Anything, "New Title").Return(nil)
+
+	patch := models.AdPatch{
+		ContentType: models.MergePatchContentType,
+		Body:        []byte(`{"title": "New Title", "image_url": null}`),
+	}
+	ad, err := adService.PatchAd(context.Background(), 1, models.Actor{UserID: 1, Role: models.RoleUser}, patch)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "New Title", ad.Title)
+	mockAdRepo.AssertExpectations(t)
+}
+
+// Результат патча проверяется по правилам создания и не сохраняется при ошибках
+func TestAdService_PatchAd_Invalid(t *testing.T) {
+	testCases := []struct {
+		name      string
+		patch     models.AdPatch
+		expectErr func(t *testing.T, err error)
+	}{
+		{
+			name:  "Удаление обязательного поля",
+			patch: models.AdPatch{ContentType: models.MergePatchContentType, Body: []byte(`{"title": null}`)},
+			expectErr: func(t *testing.T, err error) {
+				var invalid *InvalidAdError
+				assert.True(t, errors.As(err, &invalid))
+				assert.Contains(t, invalid.Fields, "title")
+			},
+		},
+		{
+			name:  "Нередактируемое поле",
+			patch: models.AdPatch{ContentType: models.JSONPatchContentType, Body: []byte(`[{"op": "add", "path": "/user_id", "value": 2}]`)},
+			expectErr: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrInvalidPatch)
+			},
+		},
+		{
+			name:  "Не выполнена операция test",
+			patch: models.AdPatch{ContentType: models.JSONPatchContentType, Body: []byte(`[{"op": "test", "path": "/price", "value": 50}, {"op": "replace", "path": "/price", "value": 60}]`)},
+			expectErr: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrPatchTestFailed)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockAdRepo := new(postgres.MockAdRepository)
+			mockSuggestRepo := new(cache.MockSuggestRepository)
+			adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+			existingAd := &models.Ad{ID: 1, UserID: 1, Title: "Title", Description: "Desc", Price: 100}
+			mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(existingAd, nil)
+
+			_, err := adService.PatchAd(context.Background(), 1, models.Actor{UserID: 1, Role: models.RoleUser}, tc.patch)
+
+			tc.expectErr(t, err)
+			mockAdRepo.AssertNotCalled(t, "UpdateAd", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// Отложенное объявление не попадает в подсказки до публикации, а время в прошлом отклоняется
+func TestAdService_CreateAd_Scheduled(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	past := time.Now().Add(-time.Minute)
+	_, err := adService.CreateAd(context.Background(), &models.Ad{UserID: 1, Title: "Диван", PublishAt: &past})
+	assert.ErrorIs(t, err, ErrPublishAtInPast)
+
+	future := time.Now().Add(24 * time.Hour)
+	ad := &models.Ad{UserID: 1, Title: "Диван", Description: "Почти новый", Price: 1000, PublishAt: &future}
+	mockAdRepo.On("CreateAd", mock.Anything, ad).Return(int64(1), nil)
+
+	id, err := adService.CreateAd(context.Background(), ad)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+	mockSuggestRepo.AssertNotCalled(t, "IndexTitle", mock.Anything, mock.Anything)
+}
+
+// До времени публикации объявление видят только владелец и модераторы
+func TestAdService_GetAdByID_Scheduled(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	publishAt := time.Now().Add(time.Hour)
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(&models.Ad{ID: 1, UserID: 10, PublishAt: &publishAt}, nil)
+
+	_, err := adService.GetAdByID(context.Background(), 1, models.Actor{UserID: 20, Role: models.RoleUser})
+	assert.ErrorIs(t, err, postgres.ErrAdNotFound)
+
+	_, err = adService.GetAdByID(context.Background(), 1, models.Actor{UserID: 10, Role: models.RoleUser})
+	assert.NoError(t, err)
+
+	_, err = adService.GetAdByID(context.Background(), 1, models.Actor{UserID: 30, Role: models.RoleModerator})
+	assert.NoError(t, err)
+}
+
+// Опубликованное объявление нельзя снова сделать отложенным
+func TestAdService_PatchAd_ScheduleAlreadyPublished(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	existingAd := &models.Ad{ID: 1, UserID: 1, Title: "Title", Description: "Desc", Price: 100}
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(existingAd, nil)
+
+	body := fmt.Sprintf(`{"publish_at": %q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	patch := models.AdPatch{ContentType: models.MergePatchContentType, Body: []byte(body)}
+	_, err := adService.PatchAd(context.Background(), 1, models.Actor{UserID: 1, Role: models.RoleUser}, patch)
+
+	assert.ErrorIs(t, err, ErrAdAlreadyPublished)
+	mockAdRepo.AssertNotCalled(t, "UpdateAd", mock.Anything, mock.Anything)
+}
+
+// Планировщик публикует объявления и добавляет в подсказки заголовки нескрытых
+func TestAdService_PublishScheduled(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	adService := NewAdService(mockAdRepo, mockSuggestRepo, screening.NewScreener(), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	hiddenAt := time.Now()
+	mockAdRepo.On("PublishScheduled", mock.Anything, mock.AnythingOfType("time.Time")).
+		Return([]models.Ad{{ID: 1, Title: "Диван"}, {ID: 2, Title: "Стол"}, {ID: 3, Title: "Шкаф", HiddenAt: &hiddenAt}}, nil)
+	mockSuggestRepo.On("IndexTitle", mock.Anything, "Диван").Return(nil)
+	mockSuggestRepo.On("IndexTitle", mock.Anything, "Стол").Return(nil)
+
+	published, err := adService.PublishScheduled(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, published)
+	mockSuggestRepo.AssertExpectations(t)
+	mockSuggestRepo.AssertNotCalled(t, "IndexTitle", mock.Anything, "Шкаф")
 }
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/auth"
+	"sort"
+)
+
+var (
+	ErrInvalidAPIKey = errors.New("invalid api key")
+)
+
+type apiKeyService struct {
+	keyRepo  postgres.APIKeyRepository
+	userRepo postgres.UserRepository
+	log      *slog.Logger
+}
+
+func NewAPIKeyService(keyRepo postgres.APIKeyRepository, userRepo postgres.UserRepository, log *slog.Logger) APIKeyService {
+	return &apiKeyService{keyRepo: keyRepo, userRepo: userRepo, log: log}
+}
+
+// Create выпускает ключ с областями scopes и возвращает его вместе с самим
+// ключом. Ключ нигде не сохраняется, поэтому показать его повторно нельзя.
+func (s *apiKeyService) Create(ctx context.Context, userID int64, name string, scopes []string) (*models.APIKey, string, error) {
+	const op = "service.CreateAPIKey"
+
+	raw, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	key := &models.APIKey{
+		UserID:  userID,
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: auth.HashOpaqueToken(raw),
+		Scopes:  normalizeScopes(scopes),
+	}
+	if _, err := s.keyRepo.CreateAPIKey(ctx, key); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+	return key, raw, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context, userID int64) ([]models.APIKey, error) {
+	keys, err := s.keyRepo.GetAPIKeysByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service.ListAPIKeys: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, userID, id int64) error {
+	if err := s.keyRepo.RevokeAPIKey(ctx, id, userID); err != nil {
+		if errors.Is(err, postgres.ErrAPIKeyNotFound) {
+			return err
+		}
+		return fmt.Errorf("service.RevokeAPIKey: %w", err)
+	}
+	return nil
+}
+
+// Authenticate находит владельца ключа. Ключи заблокированного пользователя
+// не принимаются. Время последнего использования обновляется попутно: его
+// ошибка только логируется.
+//
+// Ключ действует с правами обычного пользователя независимо от роли владельца:
+// области ключа ограничивают действия с собственными объявлениями, а права
+// модератора и администратора доступны только по access-токену.
+func (s *apiKeyService) Authenticate(ctx context.Context, rawKey string) (*models.APIKeyPrincipal, error) {
+	const op = "service.AuthenticateAPIKey"
+
+	key, err := s.keyRepo.GetAPIKeyByHash(ctx, auth.HashOpaqueToken(rawKey))
+	if err != nil {
+		if errors.Is(err, postgres.ErrAPIKeyNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, postgres.ErrUserNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if user.BannedAt != nil {
+		return nil, ErrUserBanned
+	}
+
+	if err := s.keyRepo.TouchAPIKey(ctx, key.ID); err != nil {
+		s.log.Warn("failed to record api key usage",
+			slog.Int64("api_key_id", key.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	return &models.APIKeyPrincipal{
+		Key:   key,
+		Actor: models.Actor{UserID: user.ID, Role: models.RoleUser},
+	}, nil
+}
+
+// normalizeScopes убирает повторы и упорядочивает области ключа.
+func normalizeScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	out := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			out = append(out, scope)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
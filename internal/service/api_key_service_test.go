@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/auth"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Создание возвращает ключ один раз, а в базу попадает только его хеш
+func TestAPIKeyService_Create(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	mockKeyRepo := new(postgres.MockAPIKeyRepository)
+	svc := NewAPIKeyService(mockKeyRepo, new(postgres.MockUserRepository), logger)
+
+	var stored *models.APIKey
+	mockKeyRepo.On("CreateAPIKey", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*models.APIKey) }).
+		Return(int64(3), nil)
+
+	key, raw, err := svc.Create(context.Background(), 7, "inventory sync", []string{"ads:write", "ads:read", "ads:write"})
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(raw, auth.APIKeyPrefix))
+	assert.True(t, strings.HasPrefix(raw, key.Prefix))
+	assert.Equal(t, auth.HashOpaqueToken(raw), stored.KeyHash)
+	assert.NotContains(t, stored.KeyHash, raw)
+	assert.Equal(t, []string{"ads:read", "ads:write"}, stored.Scopes)
+	assert.Equal(t, int64(7), stored.UserID)
+}
+
+func TestAPIKeyService_Authenticate(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	const raw = "mk_secret"
+	bannedAt := time.Now()
+
+	testCases := []struct {
+		name      string
+		key       *models.APIKey
+		keyErr    error
+		user      *models.User
+		touchErr  error
+		expectErr error
+	}{
+		{
+			name: "Действующий ключ",
+			key:  &models.APIKey{ID: 3, UserID: 7, Scopes: []string{models.ScopeAdsRead}},
+			user: &models.User{ID: 7, Role: models.RoleUser},
+		},
+		{
+			name: "Ключ модератора не дает прав модератора",
+			key:  &models.APIKey{ID: 3, UserID: 7, Scopes: []string{models.ScopeAdsWrite}},
+			user: &models.User{ID: 7, Role: models.RoleModerator},
+		},
+		{
+			name:     "Ошибка отметки использования не мешает входу",
+			key:      &models.APIKey{ID: 3, UserID: 7},
+			user:     &models.User{ID: 7, Role: models.RoleUser},
+			touchErr: errors.New("db is down"),
+		},
+		{name: "Неизвестный или отозванный ключ", keyErr: postgres.ErrAPIKeyNotFound, expectErr: ErrInvalidAPIKey},
+		{
+			name:      "Владелец заблокирован",
+			key:       &models.APIKey{ID: 3, UserID: 7},
+			user:      &models.User{ID: 7, Role: models.RoleUser, BannedAt: &bannedAt},
+			expectErr: ErrUserBanned,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockKeyRepo := new(postgres.MockAPIKeyRepository)
+			mockUserRepo := new(postgres.MockUserRepository)
+			svc := NewAPIKeyService(mockKeyRepo, mockUserRepo, logger)
+
+			mockKeyRepo.On("GetAPIKeyByHash", mock.Anything, auth.HashOpaqueToken(raw)).Return(tc.key, tc.keyErr)
+			if tc.user != nil {
+				mockUserRepo.On("GetUserByID", mock.Anything, tc.user.ID).Return(tc.user, nil)
+			}
+			mockKeyRepo.On("TouchAPIKey", mock.Anything, int64(3)).Return(tc.touchErr).Maybe()
+
+			principal, err := svc.Authenticate(context.Background(), raw)
+
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+				mockKeyRepo.AssertNotCalled(t, "TouchAPIKey", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, models.Actor{UserID: 7, Role: models.RoleUser}, principal.Actor)
+			mockKeyRepo.AssertCalled(t, "TouchAPIKey", mock.Anything, int64(3))
+		})
+	}
+}
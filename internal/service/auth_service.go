@@ -4,30 +4,79 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
 	"marketplace/internal/repository/postgres"
 	"marketplace/pkg/auth"
 	"marketplace/pkg/hash"
+	"marketplace/pkg/passwordpolicy"
+	"time"
 )
 
 var (
 	ErrUserExists         = errors.New("user with this username already exists")
+	ErrEmailTaken         = errors.New("email already in use")
 	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUserBanned         = errors.New("user is banned")
+
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+
+	ErrInvalidChallenge = errors.New("invalid or expired two-factor challenge")
 )
 
+// maxChallengeAttempts - сколько раз можно ввести неверный код для одного
+// challenge-токена, прежде чем придется снова войти по паролю.
+const maxChallengeAttempts = 5
+
 type authService struct {
-	userRepo     postgres.UserRepository
-	tokenManager *auth.TokenManager
+	userRepo      postgres.UserRepository
+	refreshRepo   postgres.RefreshTokenRepository
+	tokenRepo     cache.TokenRepository
+	challengeRepo cache.ChallengeRepository
+	twoFactor     TwoFactorService
+	tokenManager  *auth.TokenManager
+	passwords     *hash.Hasher
+	policy        *passwordpolicy.Policy
+	challengeTTL  time.Duration
+	guard         *LoginGuard
+	external      *ExternalLogin
+	log           *slog.Logger
 }
 
-func NewAuthService(userRepo postgres.UserRepository, tm *auth.TokenManager) AuthService {
+func NewAuthService(
+	userRepo postgres.UserRepository,
+	refreshRepo postgres.RefreshTokenRepository,
+	tokenRepo cache.TokenRepository,
+	challengeRepo cache.ChallengeRepository,
+	twoFactor TwoFactorService,
+	tm *auth.TokenManager,
+	passwords *hash.Hasher,
+	policy *passwordpolicy.Policy,
+	challengeTTL time.Duration,
+	guard *LoginGuard,
+	external *ExternalLogin,
+	log *slog.Logger,
+) AuthService {
 	return &authService{
-		userRepo:     userRepo,
-		tokenManager: tm,
+		userRepo:      userRepo,
+		refreshRepo:   refreshRepo,
+		tokenRepo:     tokenRepo,
+		challengeRepo: challengeRepo,
+		twoFactor:     twoFactor,
+		tokenManager:  tm,
+		passwords:     passwords,
+		policy:        policy,
+		challengeTTL:  challengeTTL,
+		guard:         guard,
+		external:      external,
+		log:           log,
 	}
 }
 
-func (s *authService) Register(ctx context.Context, username, password string) (*models.User, error) {
+func (s *authService) Register(ctx context.Context, username, password, email string) (*models.User, error) {
 	_, err := s.userRepo.GetUserByUsername(ctx, username)
 	if err == nil {
 		return nil, ErrUserExists
@@ -35,19 +84,32 @@ func (s *authService) Register(ctx context.Context, username, password string) (
 	if !errors.Is(err, postgres.ErrUserNotFound) {
 		return nil, fmt.Errorf("service.Register: %w", err)
 	}
+	if err := checkPasswordPolicy(s.policy, password, username); err != nil {
+		return nil, fmt.Errorf("service.Register: %w", err)
+	}
 
-	hashedPassword, err := hash.HashPassword(password)
+	hashedPassword, err := s.passwords.Hash(password)
 	if err != nil {
 		return nil, fmt.Errorf("service.Register: %w", err)
 	}
 
 	user := &models.User{
 		Username: username,
+		Email:    email,
 		Password: hashedPassword,
+		Role:     models.RoleUser,
 	}
 
 	id, err := s.userRepo.CreateUser(ctx, user)
 	if err != nil {
+		// Предварительная проверка имени не защищает от одновременной регистрации:
+		// проигравший запрос получает нарушение уникальности от базы.
+		if errors.Is(err, postgres.ErrUsernameTaken) {
+			return nil, ErrUserExists
+		}
+		if errors.Is(err, postgres.ErrEmailTaken) {
+			return nil, ErrEmailTaken
+		}
 		return nil, fmt.Errorf("service.Register: %w", err)
 	}
 	user.ID = id
@@ -56,25 +118,392 @@ func (s *authService) Register(ctx context.Context, username, password string) (
 	return user, nil
 }
 
-func (s *authService) Login(ctx context.Context, username, password string) (string, error) {
+// Login проверяет пароль. Если у пользователя включена двухфакторная
+// аутентификация, вместо токенов возвращается challenge-токен для LoginTwoFactor.
+// После серии неудачных попыток с того же имени или IP-адреса возвращается
+// *LoginLockedError, и пароль не проверяется до конца блокировки. Счетчик
+// неудач по имени сбрасывается только после входа целиком: при включенной 2FA -
+// после верного кода в LoginTwoFactor.
+// Хеш пароля, созданный bcrypt или с устаревшими параметрами argon2id,
+// после успешной проверки пересчитывается с текущими параметрами.
+func (s *authService) Login(ctx context.Context, username, password, clientIP string) (*models.LoginResult, error) {
 	const op = "service.Login"
 
+	if err := s.guard.Check(ctx, username, clientIP); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetUserByUsername(ctx, username)
 	if err != nil {
 		if errors.Is(err, postgres.ErrUserNotFound) {
-			return "", ErrInvalidCredentials
+			s.guard.Failed(ctx, username, clientIP, nil)
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ok, needsRehash := s.passwords.Verify(password, user.Password)
+	if !ok {
+		s.guard.Failed(ctx, username, clientIP, &user.ID)
+		return nil, ErrInvalidCredentials
+	}
+	if needsRehash {
+		s.rehashPassword(ctx, user.ID, password)
+	}
+
+	if user.BannedAt != nil {
+		return nil, ErrUserBanned
+	}
+
+	result, err := s.completeLogin(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if result.Challenge == nil {
+		s.guard.Succeeded(ctx, username)
+	}
+	return result, nil
+}
+
+// rehashPassword сохраняет хеш пароля с текущими параметрами. Ошибка не
+// мешает входу: хеш будет пересчитан при следующем входе.
+func (s *authService) rehashPassword(ctx context.Context, userID int64, password string) {
+	hashedPassword, err := s.passwords.Hash(password)
+	if err == nil {
+		err = s.userRepo.UpdatePassword(ctx, userID, hashedPassword)
+	}
+	if err != nil {
+		s.log.Error("failed to rehash password",
+			slog.Int64("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// BeginExternalLogin начинает вход через провайдера OpenID Connect и
+// возвращает адрес страницы входа у провайдера. Если linkUserID не 0,
+// после возврата провайдер будет привязан к этому пользователю.
+func (s *authService) BeginExternalLogin(ctx context.Context, provider string, linkUserID int64) (string, error) {
+	url, err := s.external.begin(ctx, provider, linkUserID)
+	if err != nil {
+		if errors.Is(err, ErrUnknownProvider) || errors.Is(err, ErrProviderUnavailable) {
+			return "", err
+		}
+		return "", fmt.Errorf("service.BeginExternalLogin: %w", err)
+	}
+	return url, nil
+}
+
+// CompleteExternalLogin обрабатывает возврат от провайдера. Учетная запись
+// провайдера, к которой еще никто не привязан, регистрирует нового
+// пользователя. Как и при входе по паролю, при включенной двухфакторной
+// аутентификации вместо токенов возвращается challenge-токен.
+func (s *authService) CompleteExternalLogin(ctx context.Context, provider, state, code string) (*models.ExternalLoginResult, error) {
+	const op = "service.CompleteExternalLogin"
+
+	attempt, identity, err := s.external.complete(ctx, provider, state, code)
+	if err != nil {
+		if errors.Is(err, ErrUnknownProvider) || errors.Is(err, ErrInvalidOIDCState) || errors.Is(err, ErrExternalAuthFailed) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if attempt.LinkUserID != 0 {
+		link := &models.ExternalIdentity{
+			UserID:   attempt.LinkUserID,
+			Provider: provider,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		}
+		if _, err := s.external.identityRepo.LinkIdentity(ctx, link); err != nil {
+			if errors.Is(err, postgres.ErrIdentityLinked) || errors.Is(err, postgres.ErrProviderAlreadyLinked) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return &models.ExternalLoginResult{Linked: link}, nil
+	}
+
+	user, err := s.external.identityRepo.GetUserByIdentity(ctx, provider, identity.Subject)
+	if errors.Is(err, postgres.ErrIdentityNotFound) {
+		user, err = s.external.register(ctx, provider, identity)
+	}
+	if err != nil {
+		if errors.Is(err, ErrExternalEmailTaken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if user.BannedAt != nil {
+		return nil, ErrUserBanned
+	}
+
+	result, err := s.completeLogin(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &models.ExternalLoginResult{Login: result}, nil
+}
+
+// ExternalIdentities возвращает провайдеров, привязанных к пользователю.
+func (s *authService) ExternalIdentities(ctx context.Context, userID int64) ([]models.ExternalIdentity, error) {
+	if s.external == nil {
+		return []models.ExternalIdentity{}, nil
+	}
+	identities, err := s.external.identityRepo.GetIdentitiesByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service.ExternalIdentities: %w", err)
+	}
+	return identities, nil
+}
+
+// completeLogin завершает вход уже опознанного пользователя: выдает пару
+// токенов или, если включена двухфакторная аутентификация, challenge-токен.
+func (s *authService) completeLogin(ctx context.Context, user *models.User) (*models.LoginResult, error) {
+	if user.TOTPEnabledAt != nil {
+		challenge, err := auth.GenerateOpaqueToken()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.challengeRepo.CreateChallenge(ctx, auth.HashOpaqueToken(challenge), user.ID, s.challengeTTL); err != nil {
+			return nil, err
+		}
+		return &models.LoginResult{
+			Challenge: &models.TwoFactorChallenge{Token: challenge, ExpiresIn: s.challengeTTL},
+		}, nil
+	}
+
+	tokens, err := s.startSession(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LoginResult{Tokens: tokens}, nil
+}
+
+// LoginTwoFactor завершает вход: обменивает challenge-токен и код второго
+// фактора на пару токенов. Challenge-токен действует один раз и допускает
+// ограниченное число неверных кодов. Неверные коды учитываются LoginGuard по
+// имени пользователя и IP-адресу, как неверные пароли, поэтому новый
+// challenge после повторного входа по паролю не дает новых попыток.
+//
+// Токен извлекается из хранилища до проверки кода, поэтому параллельные
+// запросы с одним токеном не проверяют коды одновременно. Если вход не
+// завершился, токен возвращается в хранилище на оставшийся срок.
+func (s *authService) LoginTwoFactor(ctx context.Context, challenge, code string) (*models.TokenPair, error) {
+	const op = "service.LoginTwoFactor"
+
+	challengeHash := auth.HashOpaqueToken(challenge)
+	userID, ttl, err := s.challengeRepo.TakeChallenge(ctx, challengeHash)
+	if err != nil {
+		if errors.Is(err, cache.ErrChallengeNotFound) {
+			return nil, ErrInvalidChallenge
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	restore := func() {
+		if err := s.challengeRepo.CreateChallenge(ctx, challengeHash, userID, ttl); err != nil {
+			s.log.Warn("failed to restore two-factor challenge", slog.String("error", err.Error()))
 		}
-		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	if !hash.CheckPasswordHash(password, user.Password) {
-		return "", ErrInvalidCredentials
+	attempts, err := s.challengeRepo.IncrChallengeAttempts(ctx, challengeHash, ttl)
+	if err != nil {
+		restore()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if attempts > maxChallengeAttempts {
+		if err := s.challengeRepo.DeleteChallenge(ctx, challengeHash); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		restore()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	clientIP := clientInfoFromContext(ctx).IP
+	if err := s.guard.Check(ctx, user.Username, clientIP); err != nil {
+		restore()
+		return nil, err
+	}
+
+	if err := s.twoFactor.Verify(ctx, userID, code); err != nil {
+		restore()
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			s.guard.Failed(ctx, user.Username, clientIP, &user.ID)
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	s.guard.Succeeded(ctx, user.Username)
+	if err := s.challengeRepo.DeleteChallenge(ctx, challengeHash); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	token, err := s.tokenManager.GenerateToken(user.ID, user.Username)
+	// Пока вводился код, пользователя могли заблокировать.
+	if user.BannedAt != nil {
+		return nil, ErrUserBanned
+	}
+
+	tokens, err := s.startSession(ctx, user)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	return tokens, nil
+}
 
-	return token, nil
+// Refresh обменивает refresh-токен на новую пару токенов. Каждый refresh-токен
+// действует один раз: повторное предъявление отзывает все семейство, и
+// владельцу придется войти заново.
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	const op = "service.Refresh"
+
+	nextToken, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	next := &models.RefreshToken{
+		TokenHash: auth.HashOpaqueToken(nextToken),
+		ExpiresAt: time.Now().Add(s.tokenManager.RefreshTokenTTL()),
+	}
+
+	current, err := s.refreshRepo.RotateRefreshToken(ctx, auth.HashOpaqueToken(refreshToken), next)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrRefreshTokenNotFound):
+			return nil, ErrInvalidRefreshToken
+		case errors.Is(err, postgres.ErrRefreshTokenReused):
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Роль и блокировка могли измениться с момента входа, поэтому берем их из базы.
+	user, err := s.userRepo.GetUserByID(ctx, current.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if user.BannedAt != nil {
+		if err := s.refreshRepo.RevokeFamily(ctx, current.FamilyID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return nil, ErrUserBanned
+	}
+
+	return s.issueTokens(ctx, user, nextToken, current.FamilyID)
+}
+
+// Logout отзывает access-токен запроса до истечения его срока и завершает его
+// сессию: остальные access-токены сессии перестают проходить проверку, а ее
+// refresh-токены отзываются, даже если refresh-токен не передан. Переданный
+// refresh-токен отзывается вместе со своим семейством.
+func (s *authService) Logout(ctx context.Context, claims *auth.Claims, refreshToken string) error {
+	const op = "service.Logout"
+
+	if claims.ExpiresAt != nil {
+		if err := s.tokenRepo.DenyToken(ctx, claims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if claims.SessionID != "" {
+		if err := s.tokenRepo.DenySession(ctx, claims.SessionID, s.tokenManager.AccessTokenTTL()); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := s.refreshRepo.RevokeFamily(ctx, claims.SessionID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if refreshToken != "" {
+		if err := s.refreshRepo.RevokeTokenFamily(ctx, claims.UserID, auth.HashOpaqueToken(refreshToken)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// LogoutAll завершает все сессии пользователя: выданные ранее access-токены
+// перестают проходить проверку, а refresh-токены отзываются.
+func (s *authService) LogoutAll(ctx context.Context, userID int64) error {
+	const op = "service.LogoutAll"
+
+	if _, err := s.tokenRepo.IncrTokenGeneration(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.refreshRepo.RevokeUserTokens(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// CheckToken проверяет, не отозван ли access-токен выходом из системы
+// или завершением его сессии.
+func (s *authService) CheckToken(ctx context.Context, claims *auth.Claims) error {
+	const op = "service.CheckToken"
+
+	denied, err := s.tokenRepo.IsTokenDenied(ctx, claims.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if denied {
+		return ErrTokenRevoked
+	}
+
+	if claims.SessionID != "" {
+		denied, err := s.tokenRepo.IsSessionDenied(ctx, claims.SessionID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if denied {
+			return ErrTokenRevoked
+		}
+	}
+
+	generation, err := s.tokenRepo.TokenGeneration(ctx, claims.UserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if claims.Generation < generation {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// startSession открывает новую сессию с устройства из контекста запроса:
+// сохраняет хеш нового refresh-токена и выдает к нему access-токен.
+func (s *authService) startSession(ctx context.Context, user *models.User) (*models.TokenPair, error) {
+	refreshToken, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	client := clientInfoFromContext(ctx)
+	token := &models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashOpaqueToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.tokenManager.RefreshTokenTTL()),
+	}
+	session := &models.Session{UserAgent: client.UserAgent, IP: client.IP}
+	if _, err := s.refreshRepo.CreateRefreshToken(ctx, token, session); err != nil {
+		return nil, err
+	}
+	return s.issueTokens(ctx, user, refreshToken, token.FamilyID)
+}
+
+// issueTokens дополняет refresh-токен сессии sessionID новым access-токеном.
+func (s *authService) issueTokens(ctx context.Context, user *models.User, refreshToken, sessionID string) (*models.TokenPair, error) {
+	generation, err := s.tokenRepo.TokenGeneration(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("service.issueTokens: %w", err)
+	}
+	accessToken, err := s.tokenManager.GenerateToken(user.ID, user.Username, user.Role, generation, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("service.issueTokens: %w", err)
+	}
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.tokenManager.AccessTokenTTL(),
+	}, nil
 }
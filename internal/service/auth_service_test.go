@@ -2,28 +2,39 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"marketplace/internal/config"
 	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
 	"marketplace/internal/repository/postgres"
 	"marketplace/pkg/auth"
 	"marketplace/pkg/hash"
+	"marketplace/pkg/passwordpolicy"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// testHasher использует минимальные параметры argon2id, чтобы тесты не тратили
+// на хеширование по 64 МиБ памяти.
+var testHasher = hash.NewHasher(hash.Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+
 // Тестирование успешной регистрации
 func TestAuthService_Register_Success(t *testing.T) {
 	// 1. Настройка (Arrange)
 	cfg := config.Auth{
-		JWTSecret: "secret",
-		TokenTTL:  time.Hour,
+		JWTSecret:      "secret",
+		AccessTokenTTL: time.Hour,
 	}
 	mockUserRepo := new(postgres.MockUserRepository)
 	tm, _ := auth.NewTokenManager(cfg)
-	authService := NewAuthService(mockUserRepo, tm)
+	authService := NewAuthService(mockUserRepo, new(postgres.MockRefreshTokenRepository), new(cache.MockTokenRepository), new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
 
 	username := "testuser"
 	password := "password123"
@@ -36,7 +47,7 @@ func TestAuthService_Register_Success(t *testing.T) {
 	mockUserRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*models.User")).Return(int64(1), nil)
 
 	// 2. Действие (Act)
-	user, err := authService.Register(context.Background(), username, password)
+	user, err := authService.Register(context.Background(), username, password, "")
 
 	// 3. Утверждение (Assert)
 	assert.NoError(t, err)
@@ -50,12 +61,12 @@ func TestAuthService_Register_Success(t *testing.T) {
 func TestAuthService_Register_UserExists(t *testing.T) {
 	// 1. Настройка
 	cfg := config.Auth{
-		JWTSecret: "secret",
-		TokenTTL:  time.Hour,
+		JWTSecret:      "secret",
+		AccessTokenTTL: time.Hour,
 	}
 	mockUserRepo := new(postgres.MockUserRepository)
 	tm, _ := auth.NewTokenManager(cfg)
-	authService := NewAuthService(mockUserRepo, tm)
+	authService := NewAuthService(mockUserRepo, new(postgres.MockRefreshTokenRepository), new(cache.MockTokenRepository), new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
 
 	username := "existinguser"
 	password := "password123"
@@ -65,7 +76,7 @@ func TestAuthService_Register_UserExists(t *testing.T) {
 	mockUserRepo.On("GetUserByUsername", mock.Anything, username).Return(existingUser, nil)
 
 	// 2. Действие
-	user, err := authService.Register(context.Background(), username, password)
+	user, err := authService.Register(context.Background(), username, password, "")
 
 	// 3. Утверждение
 	assert.Error(t, err)
@@ -74,21 +85,64 @@ func TestAuthService_Register_UserExists(t *testing.T) {
 	mockUserRepo.AssertExpectations(t)
 }
 
+// Если имя заняли между проверкой и вставкой, проигравший запрос получает
+// ErrUserExists, а не внутреннюю ошибку
+func TestAuthService_Register_ConcurrentUsername(t *testing.T) {
+	mockUserRepo := new(postgres.MockUserRepository)
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Hour})
+	authService := NewAuthService(mockUserRepo, new(postgres.MockRefreshTokenRepository), new(cache.MockTokenRepository), new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
+
+	mockUserRepo.On("GetUserByUsername", mock.Anything, "newuser").Return(nil, postgres.ErrUserNotFound)
+	mockUserRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*models.User")).
+		Return(int64(0), fmt.Errorf("repository.CreateUser: %w", &postgres.ConstraintError{
+			Kind: postgres.ConstraintUnique, Table: "users", Constraint: "users_username_key", Field: "username", Err: postgres.ErrUsernameTaken,
+		}))
+
+	user, err := authService.Register(context.Background(), "newuser", "password123", "new@example.com")
+
+	assert.Nil(t, user)
+	assert.ErrorIs(t, err, ErrUserExists)
+}
+
+// Пароль, нарушающий политику, не дает зарегистрироваться, и пользователь не создается
+func TestAuthService_Register_WeakPassword(t *testing.T) {
+	mockUserRepo := new(postgres.MockUserRepository)
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Hour})
+	policy := &passwordpolicy.Policy{MinLength: 10, RequireUppercase: true, ForbidUsername: true}
+	authService := NewAuthService(mockUserRepo, new(postgres.MockRefreshTokenRepository), new(cache.MockTokenRepository), new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, policy, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
+
+	mockUserRepo.On("GetUserByUsername", mock.Anything, "newuser").Return(nil, postgres.ErrUserNotFound)
+
+	user, err := authService.Register(context.Background(), "newuser", "newuser-password", "new@example.com")
+
+	assert.Nil(t, user)
+	var weak *WeakPasswordError
+	if assert.ErrorAs(t, err, &weak) {
+		assert.Equal(t, []passwordpolicy.Violation{
+			{Code: passwordpolicy.CodeMissingUppercase, Message: "password must contain an uppercase letter"},
+			{Code: passwordpolicy.CodeContainsUsername, Message: "password must not contain the username"},
+		}, weak.Violations)
+	}
+	mockUserRepo.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+}
+
 // Тестирование успешного входа
 func TestAuthService_Login_Success(t *testing.T) {
 	// 1. Настройка
 	cfg := config.Auth{
-		JWTSecret: "secret",
-		TokenTTL:  time.Hour,
+		JWTSecret:      "secret",
+		AccessTokenTTL: time.Hour,
 	}
 	mockUserRepo := new(postgres.MockUserRepository)
+	mockRefreshRepo := new(postgres.MockRefreshTokenRepository)
+	mockTokenRepo := new(cache.MockTokenRepository)
 	tm, _ := auth.NewTokenManager(cfg)
-	authService := NewAuthService(mockUserRepo, tm)
+	authService := NewAuthService(mockUserRepo, mockRefreshRepo, mockTokenRepo, new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
 
 	username := "testuser"
 	password := "password123"
 	// Пароль, который хранится в базе (хэшированный)
-	hashedPassword, _ := hash.HashPassword(password)
+	hashedPassword, _ := testHasher.Hash(password)
 
 	userFromDB := &models.User{
 		ID:       1,
@@ -98,32 +152,105 @@ func TestAuthService_Login_Success(t *testing.T) {
 
 	// Симулируем, что пользователь найден
 	mockUserRepo.On("GetUserByUsername", mock.Anything, username).Return(userFromDB, nil)
+	// В базу попадает только хеш refresh-токена
+	var stored *models.RefreshToken
+	var session *models.Session
+	mockRefreshRepo.On("CreateRefreshToken", mock.Anything, mock.AnythingOfType("*models.RefreshToken"), mock.AnythingOfType("*models.Session")).
+		Run(func(args mock.Arguments) {
+			stored, session = args.Get(1).(*models.RefreshToken), args.Get(2).(*models.Session)
+			stored.FamilyID = "0d1e7c2a-5b8f-4c3e-9a61-2f4b8d7e6c10"
+		}).
+		Return(int64(1), nil)
+	mockTokenRepo.On("TokenGeneration", mock.Anything, int64(1)).Return(int64(0), nil)
 
 	// 2. Действие
-	token, err := authService.Login(context.Background(), username, password)
+	ctx := WithClientInfo(context.Background(), models.ClientInfo{IP: "192.0.2.1", UserAgent: "Firefox/128.0"})
+	result, err := authService.Login(ctx, username, password, "192.0.2.1")
 
 	// 3. Утверждение
 	assert.NoError(t, err)
-	assert.NotEmpty(t, token)
+	assert.Nil(t, result.Challenge)
+	tokens := result.Tokens
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	assert.Equal(t, time.Hour, tokens.ExpiresIn)
+	assert.Equal(t, auth.HashOpaqueToken(tokens.RefreshToken), stored.TokenHash)
+	assert.Equal(t, int64(1), stored.UserID)
+	// Сессия запоминает устройство, а ее ID попадает в access-токен.
+	assert.Equal(t, &models.Session{IP: "192.0.2.1", UserAgent: "Firefox/128.0"}, session)
+	claims, err := tm.ParseToken(tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, stored.FamilyID, claims.SessionID)
 	mockUserRepo.AssertExpectations(t)
+	mockRefreshRepo.AssertExpectations(t)
+}
+
+// Хеш bcrypt или argon2id с устаревшими параметрами пересчитывается при входе,
+// а ошибка сохранения нового хеша не мешает войти.
+func TestAuthService_Login_RehashesOutdatedHash(t *testing.T) {
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	weakHash, _ := hash.NewHasher(hash.Params{Memory: 32, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}).Hash("password123")
+	currentHash, _ := testHasher.Hash("password123")
+
+	testCases := []struct {
+		name       string
+		storedHash string
+		expectSave bool
+		saveErr    error
+	}{
+		{name: "bcrypt", storedHash: string(bcryptHash), expectSave: true},
+		{name: "outdated argon2id params", storedHash: weakHash, expectSave: true},
+		{name: "current params", storedHash: currentHash},
+		{name: "save failed", storedHash: string(bcryptHash), expectSave: true, saveErr: errors.New("db down")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUserRepo := new(postgres.MockUserRepository)
+			mockRefreshRepo := new(postgres.MockRefreshTokenRepository)
+			mockTokenRepo := new(cache.MockTokenRepository)
+			tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Hour})
+			authService := NewAuthService(mockUserRepo, mockRefreshRepo, mockTokenRepo, new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
+
+			mockUserRepo.On("GetUserByUsername", mock.Anything, "testuser").
+				Return(&models.User{ID: 1, Username: "testuser", Password: tc.storedHash}, nil)
+			if tc.expectSave {
+				mockUserRepo.On("UpdatePassword", mock.Anything, int64(1), mock.MatchedBy(func(h string) bool {
+					ok, needsRehash := testHasher.Verify("password123", h)
+					return strings.HasPrefix(h, "$argon2id$v=19$m=64,t=1,p=1$") && ok && !needsRehash
+				})).Return(tc.saveErr)
+			}
+			mockRefreshRepo.On("CreateRefreshToken", mock.Anything, mock.Anything, mock.Anything).Return(int64(1), nil)
+			mockTokenRepo.On("TokenGeneration", mock.Anything, int64(1)).Return(int64(0), nil)
+
+			result, err := authService.Login(context.Background(), "testuser", "password123", "192.0.2.1")
+
+			assert.NoError(t, err)
+			assert.NotNil(t, result.Tokens)
+			mockUserRepo.AssertExpectations(t)
+			if !tc.expectSave {
+				mockUserRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
 }
 
 // Тестирование входа с неверным паролем
 func TestAuthService_Login_InvalidCredentials(t *testing.T) {
 	// 1. Настройка
 	cfg := config.Auth{
-		JWTSecret: "secret",
-		TokenTTL:  time.Hour,
+		JWTSecret:      "secret",
+		AccessTokenTTL: time.Hour,
 	}
 	mockUserRepo := new(postgres.MockUserRepository)
 	tm, _ := auth.NewTokenManager(cfg)
-	authService := NewAuthService(mockUserRepo, tm)
+	authService := NewAuthService(mockUserRepo, new(postgres.MockRefreshTokenRepository), new(cache.MockTokenRepository), new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
 
 	username := "testuser"
 	correctPassword := "password123"
 	wrongPassword := "wrongpassword"
 
-	hashedPassword, _ := hash.HashPassword(correctPassword)
+	hashedPassword, _ := testHasher.Hash(correctPassword)
 	userFromDB := &models.User{
 		ID:       1,
 		Username: username,
@@ -133,11 +260,306 @@ func TestAuthService_Login_InvalidCredentials(t *testing.T) {
 	mockUserRepo.On("GetUserByUsername", mock.Anything, username).Return(userFromDB, nil)
 
 	// 2. Действие
-	token, err := authService.Login(context.Background(), username, wrongPassword)
+	tokens, err := authService.Login(context.Background(), username, wrongPassword, "192.0.2.1")
 
 	// 3. Утверждение
 	assert.Error(t, err)
-	assert.Empty(t, token)
+	assert.Nil(t, tokens)
 	assert.Equal(t, ErrInvalidCredentials, err)
 	mockUserRepo.AssertExpectations(t)
 }
+
+// Заблокированный пользователь не может войти даже с верным паролем
+func TestAuthService_Login_Banned(t *testing.T) {
+	cfg := config.Auth{
+		JWTSecret:      "secret",
+		AccessTokenTTL: time.Hour,
+	}
+	mockUserRepo := new(postgres.MockUserRepository)
+	tm, _ := auth.NewTokenManager(cfg)
+	authService := NewAuthService(mockUserRepo, new(postgres.MockRefreshTokenRepository), new(cache.MockTokenRepository), new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
+
+	hashedPassword, _ := testHasher.Hash("password123")
+	bannedAt := time.Now()
+	userFromDB := &models.User{ID: 1, Username: "banned", Password: hashedPassword, BannedAt: &bannedAt}
+
+	mockUserRepo.On("GetUserByUsername", mock.Anything, "banned").Return(userFromDB, nil)
+
+	tokens, err := authService.Login(context.Background(), "banned", "password123", "192.0.2.1")
+
+	assert.ErrorIs(t, err, ErrUserBanned)
+	assert.Nil(t, tokens)
+}
+
+// При включенной 2FA вход по паролю выдает только challenge-токен
+func TestAuthService_Login_TwoFactor(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Hour})
+	mockUserRepo := new(postgres.MockUserRepository)
+	mockRefreshRepo := new(postgres.MockRefreshTokenRepository)
+	mockChallengeRepo := new(cache.MockChallengeRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshRepo, new(cache.MockTokenRepository), mockChallengeRepo, new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
+
+	hashedPassword, _ := testHasher.Hash("password123")
+	enabledAt := time.Now()
+	mockUserRepo.On("GetUserByUsername", mock.Anything, "seller").
+		Return(&models.User{ID: 7, Username: "seller", Password: hashedPassword, TOTPEnabledAt: &enabledAt}, nil)
+	var storedHash string
+	mockChallengeRepo.On("CreateChallenge", mock.Anything, mock.AnythingOfType("string"), int64(7), 5*time.Minute).
+		Run(func(args mock.Arguments) { storedHash = args.String(1) }).
+		Return(nil)
+
+	result, err := authService.Login(context.Background(), "seller", "password123", "192.0.2.1")
+
+	assert.NoError(t, err)
+	assert.Nil(t, result.Tokens)
+	if assert.NotNil(t, result.Challenge) {
+		assert.Equal(t, auth.HashOpaqueToken(result.Challenge.Token), storedHash)
+		assert.Equal(t, 5*time.Minute, result.Challenge.ExpiresIn)
+	}
+	mockRefreshRepo.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthService_LoginTwoFactor(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Hour})
+	challengeHash := auth.HashOpaqueToken("challenge")
+
+	testCases := []struct {
+		name          string
+		attempts      int64
+		verifyErr     error
+		expectErr     error
+		expectDrop    bool
+		expectRestore bool
+	}{
+		{name: "Верный код", attempts: 1, expectDrop: true},
+		{name: "Неверный код", attempts: 1, verifyErr: ErrInvalidTwoFactorCode, expectErr: ErrInvalidTwoFactorCode, expectRestore: true},
+		{name: "Попытки исчерпаны", attempts: maxChallengeAttempts + 1, expectErr: ErrInvalidChallenge, expectDrop: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUserRepo := new(postgres.MockUserRepository)
+			mockRefreshRepo := new(postgres.MockRefreshTokenRepository)
+			mockTokenRepo := new(cache.MockTokenRepository)
+			mockChallengeRepo := new(cache.MockChallengeRepository)
+			mockTwoFactor := new(MockTwoFactorService)
+			authService := NewAuthService(mockUserRepo, mockRefreshRepo, mockTokenRepo, mockChallengeRepo, mockTwoFactor, tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
+
+			// Токен извлекается до проверки кода, и попытки живут не дольше его остатка
+			mockChallengeRepo.On("TakeChallenge", mock.Anything, challengeHash).Return(int64(7), 3*time.Minute, nil)
+			mockChallengeRepo.On("IncrChallengeAttempts", mock.Anything, challengeHash, 3*time.Minute).Return(tc.attempts, nil)
+			if tc.attempts <= maxChallengeAttempts {
+				mockUserRepo.On("GetUserByID", mock.Anything, int64(7)).Return(&models.User{ID: 7, Username: "seller"}, nil)
+				mockTwoFactor.On("Verify", mock.Anything, int64(7), "123456").Return(tc.verifyErr)
+			}
+			if tc.expectDrop {
+				mockChallengeRepo.On("DeleteChallenge", mock.Anything, challengeHash).Return(nil)
+			}
+			if tc.expectRestore {
+				mockChallengeRepo.On("CreateChallenge", mock.Anything, challengeHash, int64(7), 3*time.Minute).Return(nil)
+			}
+			if tc.expectErr == nil {
+				mockRefreshRepo.On("CreateRefreshToken", mock.Anything, mock.AnythingOfType("*models.RefreshToken"), mock.AnythingOfType("*models.Session")).Return(int64(1), nil)
+				mockTokenRepo.On("TokenGeneration", mock.Anything, int64(7)).Return(int64(0), nil)
+			}
+
+			tokens, err := authService.LoginTwoFactor(context.Background(), "challenge", "123456")
+
+			assert.ErrorIs(t, err, tc.expectErr)
+			if tc.expectErr == nil {
+				assert.NotEmpty(t, tokens.AccessToken)
+			}
+			mockChallengeRepo.AssertExpectations(t)
+			mockTwoFactor.AssertExpectations(t)
+			if !tc.expectRestore {
+				mockChallengeRepo.AssertNotCalled(t, "CreateChallenge", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+// Второй запрос с тем же токеном, пришедший во время проверки кода, не находит
+// токен и не получает собственную попытку
+func TestAuthService_LoginTwoFactor_ChallengeTaken(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Hour})
+	mockChallengeRepo := new(cache.MockChallengeRepository)
+	mockTwoFactor := new(MockTwoFactorService)
+	authService := NewAuthService(new(postgres.MockUserRepository), new(postgres.MockRefreshTokenRepository), new(cache.MockTokenRepository), mockChallengeRepo, mockTwoFactor, tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
+
+	mockChallengeRepo.On("TakeChallenge", mock.Anything, auth.HashOpaqueToken("challenge")).Return(int64(0), time.Duration(0), cache.ErrChallengeNotFound)
+
+	_, err := authService.LoginTwoFactor(context.Background(), "challenge", "123456")
+
+	assert.ErrorIs(t, err, ErrInvalidChallenge)
+	mockChallengeRepo.AssertNotCalled(t, "IncrChallengeAttempts", mock.Anything, mock.Anything, mock.Anything)
+	mockTwoFactor.AssertNotCalled(t, "Verify", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Обновление выдает новую пару токенов и сохраняет новый refresh-токен в том же семействе
+func TestAuthService_Refresh_Success(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour})
+	mockUserRepo := new(postgres.MockUserRepository)
+	mockRefreshRepo := new(postgres.MockRefreshTokenRepository)
+	mockTokenRepo := new(cache.MockTokenRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshRepo, mockTokenRepo, new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
+
+	current := &models.RefreshToken{ID: 1, UserID: 7, FamilyID: "family"}
+	var next *models.RefreshToken
+	mockRefreshRepo.On("RotateRefreshToken", mock.Anything, auth.HashOpaqueToken("old-token"), mock.AnythingOfType("*models.RefreshToken")).
+		Run(func(args mock.Arguments) { next = args.Get(2).(*models.RefreshToken) }).
+		Return(current, nil)
+	mockUserRepo.On("GetUserByID", mock.Anything, int64(7)).
+		Return(&models.User{ID: 7, Username: "seller", Role: models.RoleModerator}, nil)
+	mockTokenRepo.On("TokenGeneration", mock.Anything, int64(7)).Return(int64(3), nil)
+
+	tokens, err := authService.Refresh(context.Background(), "old-token")
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, "old-token", tokens.RefreshToken)
+	assert.Equal(t, auth.HashOpaqueToken(tokens.RefreshToken), next.TokenHash)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), next.ExpiresAt, time.Minute)
+
+	claims, err := tm.ParseToken(tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), claims.UserID)
+	assert.Equal(t, models.RoleModerator, claims.Role)
+	assert.Equal(t, int64(3), claims.Generation)
+	mockRefreshRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Refresh_Errors(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour})
+
+	testCases := []struct {
+		name      string
+		repoErr   error
+		expectErr error
+	}{
+		{name: "Неизвестный или истекший токен", repoErr: postgres.ErrRefreshTokenNotFound, expectErr: ErrInvalidRefreshToken},
+		{name: "Повторное использование", repoErr: postgres.ErrRefreshTokenReused, expectErr: ErrRefreshTokenReused},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUserRepo := new(postgres.MockUserRepository)
+			mockRefreshRepo := new(postgres.MockRefreshTokenRepository)
+			mockTokenRepo := new(cache.MockTokenRepository)
+			authService := NewAuthService(mockUserRepo, mockRefreshRepo, mockTokenRepo, new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
+
+			mockRefreshRepo.On("RotateRefreshToken", mock.Anything, mock.Anything, mock.Anything).Return(nil, tc.repoErr)
+
+			tokens, err := authService.Refresh(context.Background(), "token")
+
+			assert.ErrorIs(t, err, tc.expectErr)
+			assert.Nil(t, tokens)
+			mockUserRepo.AssertNotCalled(t, "GetUserByID", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// Заблокированный пользователь теряет все токены семейства при попытке обновления
+func TestAuthService_Refresh_Banned(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour})
+	mockUserRepo := new(postgres.MockUserRepository)
+	mockRefreshRepo := new(postgres.MockRefreshTokenRepository)
+	mockTokenRepo := new(cache.MockTokenRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshRepo, mockTokenRepo, new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
+
+	bannedAt := time.Now()
+	mockRefreshRepo.On("RotateRefreshToken", mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.RefreshToken{ID: 1, UserID: 7, FamilyID: "family"}, nil)
+	mockUserRepo.On("GetUserByID", mock.Anything, int64(7)).Return(&models.User{ID: 7, BannedAt: &bannedAt}, nil)
+	mockRefreshRepo.On("RevokeFamily", mock.Anything, "family").Return(nil)
+
+	tokens, err := authService.Refresh(context.Background(), "token")
+
+	assert.ErrorIs(t, err, ErrUserBanned)
+	assert.Nil(t, tokens)
+	mockRefreshRepo.AssertExpectations(t)
+}
+
+// Отозванный токен, токен завершенной сессии и токен старого поколения не проходят проверку
+func TestAuthService_CheckToken(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour})
+
+	testCases := []struct {
+		name          string
+		denied        bool
+		sessionDenied bool
+		generation    int64
+		expectErr     error
+	}{
+		{name: "Действующий токен", generation: 2},
+		{name: "Токен в denylist", denied: true, generation: 2, expectErr: ErrTokenRevoked},
+		{name: "Сессия завершена", sessionDenied: true, generation: 2, expectErr: ErrTokenRevoked},
+		{name: "Выход на всех устройствах", generation: 3, expectErr: ErrTokenRevoked},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockTokenRepo := new(cache.MockTokenRepository)
+			authService := NewAuthService(new(postgres.MockUserRepository), new(postgres.MockRefreshTokenRepository), mockTokenRepo, new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
+
+			claims := &auth.Claims{UserID: 7, Generation: 2, SessionID: "session"}
+			claims.ID = "jti"
+			mockTokenRepo.On("IsTokenDenied", mock.Anything, "jti").Return(tc.denied, nil)
+			mockTokenRepo.On("IsSessionDenied", mock.Anything, "session").Return(tc.sessionDenied, nil).Maybe()
+			mockTokenRepo.On("TokenGeneration", mock.Anything, int64(7)).Return(tc.generation, nil).Maybe()
+
+			err := authService.CheckToken(context.Background(), claims)
+
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// Выход добавляет jti и сессию в denylist до истечения токенов и отзывает семейство refresh-токена
+func TestAuthService_Logout(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour})
+	mockRefreshRepo := new(postgres.MockRefreshTokenRepository)
+	mockTokenRepo := new(cache.MockTokenRepository)
+	authService := NewAuthService(new(postgres.MockUserRepository), mockRefreshRepo, mockTokenRepo, new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
+
+	token, _ := tm.GenerateToken(7, "seller", models.RoleUser, 0, "session")
+	claims, _ := tm.ParseToken(token)
+
+	mockTokenRepo.On("DenyToken", mock.Anything, claims.ID, mock.MatchedBy(func(ttl time.Duration) bool {
+		return ttl > 0 && ttl <= time.Minute
+	})).Return(nil)
+	mockTokenRepo.On("DenySession", mock.Anything, "session", time.Minute).Return(nil)
+	mockRefreshRepo.On("RevokeFamily", mock.Anything, "session").Return(nil)
+	mockRefreshRepo.On("RevokeTokenFamily", mock.Anything, int64(7), auth.HashOpaqueToken("refresh")).Return(nil)
+
+	err := authService.Logout(context.Background(), claims, "refresh")
+
+	assert.NoError(t, err)
+	mockTokenRepo.AssertExpectations(t)
+	mockRefreshRepo.AssertExpectations(t)
+}
+
+// Без refresh-токена сессия все равно завершается: ее refresh-токены
+// больше нельзя обменять, а в списке сессий она не считается активной
+func TestAuthService_Logout_WithoutRefreshToken(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour})
+	mockRefreshRepo := new(postgres.MockRefreshTokenRepository)
+	mockTokenRepo := new(cache.MockTokenRepository)
+	authService := NewAuthService(new(postgres.MockUserRepository), mockRefreshRepo, mockTokenRepo, new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, nil, slog.New(slog.DiscardHandler))
+
+	token, _ := tm.GenerateToken(7, "seller", models.RoleUser, 0, "session")
+	claims, _ := tm.ParseToken(token)
+
+	mockTokenRepo.On("DenyToken", mock.Anything, claims.ID, mock.AnythingOfType("time.Duration")).Return(nil)
+	mockTokenRepo.On("DenySession", mock.Anything, "session", time.Minute).Return(nil)
+	mockRefreshRepo.On("RevokeFamily", mock.Anything, "session").Return(nil)
+
+	err := authService.Logout(context.Background(), claims, "")
+
+	assert.NoError(t, err)
+	mockTokenRepo.AssertExpectations(t)
+	mockRefreshRepo.AssertExpectations(t)
+	mockRefreshRepo.AssertNotCalled(t, "RevokeTokenFamily", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
+	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/auth"
+	"marketplace/pkg/oidc"
+	"math/rand/v2"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrUnknownProvider      = errors.New("unknown identity provider")
+	ErrProviderUnavailable  = errors.New("identity provider is unavailable")
+	ErrInvalidOIDCState     = errors.New("invalid or expired external login state")
+	ErrExternalAuthFailed   = errors.New("external authentication failed")
+	ErrExternalEmailTaken   = errors.New("an account with this email already exists")
+	ErrExternalUsernameBusy = errors.New("could not pick a free username")
+)
+
+const (
+	// externalUsernameMin и externalUsernameMax повторяют ограничения имени при регистрации.
+	externalUsernameMin = 4
+	externalUsernameMax = 32
+	// externalUsernameAttempts - сколько случайных суффиксов пробуется, если имя занято.
+	externalUsernameAttempts = 5
+)
+
+// ExternalLogin хранит провайдеров OpenID Connect и незавершенные попытки
+// входа через них. Nil или пустой набор провайдеров отключает внешний вход.
+type ExternalLogin struct {
+	providers    oidc.Providers
+	identityRepo postgres.IdentityRepository
+	stateRepo    cache.OIDCStateRepository
+	stateTTL     time.Duration
+}
+
+func NewExternalLogin(
+	providers oidc.Providers,
+	identityRepo postgres.IdentityRepository,
+	stateRepo cache.OIDCStateRepository,
+	stateTTL time.Duration,
+) *ExternalLogin {
+	return &ExternalLogin{
+		providers:    providers,
+		identityRepo: identityRepo,
+		stateRepo:    stateRepo,
+		stateTTL:     stateTTL,
+	}
+}
+
+func (e *ExternalLogin) provider(name string) (*oidc.Provider, error) {
+	if e == nil {
+		return nil, ErrUnknownProvider
+	}
+	p, ok := e.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// begin сохраняет новую попытку входа и возвращает адрес страницы провайдера.
+func (e *ExternalLogin) begin(ctx context.Context, providerName string, linkUserID int64) (string, error) {
+	p, err := e.provider(providerName)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	attempt := &models.OIDCState{
+		Provider:   providerName,
+		Nonce:      nonce,
+		Verifier:   oidc.NewVerifier(),
+		LinkUserID: linkUserID,
+	}
+
+	url, err := p.AuthCodeURL(ctx, state, attempt.Nonce, attempt.Verifier)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	if err := e.stateRepo.SaveState(ctx, auth.HashOpaqueToken(state), attempt, e.stateTTL); err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// complete гасит попытку входа по state и обменивает код на проверенную
+// учетную запись провайдера.
+func (e *ExternalLogin) complete(ctx context.Context, providerName, state, code string) (*models.OIDCState, *oidc.Identity, error) {
+	p, err := e.provider(providerName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attempt, err := e.stateRepo.TakeState(ctx, auth.HashOpaqueToken(state))
+	if err != nil {
+		if errors.Is(err, cache.ErrOIDCStateNotFound) {
+			return nil, nil, ErrInvalidOIDCState
+		}
+		return nil, nil, err
+	}
+	if attempt.Provider != providerName {
+		return nil, nil, ErrInvalidOIDCState
+	}
+
+	identity, err := p.Exchange(ctx, code, attempt.Verifier, attempt.Nonce)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrExternalAuthFailed, err)
+	}
+	return attempt, identity, nil
+}
+
+// register создает пользователя для учетной записи провайдера, которая еще
+// ни к кому не привязана. Почта, не подтвержденная провайдером, пользователю
+// не присваивается: иначе через провайдера можно было бы занять чужой адрес.
+func (e *ExternalLogin) register(ctx context.Context, providerName string, identity *oidc.Identity) (*models.User, error) {
+	user := &models.User{Role: models.RoleUser}
+	if identity.EmailVerified && identity.Email != "" {
+		now := time.Now()
+		user.Email = identity.Email
+		user.EmailVerifiedAt = &now
+	}
+
+	base := externalUsername(providerName, identity)
+	for attempt := 0; attempt <= externalUsernameAttempts; attempt++ {
+		user.Username = base
+		if attempt > 0 {
+			user.Username = fmt.Sprintf("%s-%04d", base[:min(len(base), externalUsernameMax-5)], rand.IntN(10000))
+		}
+
+		link := &models.ExternalIdentity{Provider: providerName, Subject: identity.Subject, Email: identity.Email}
+		_, err := e.identityRepo.CreateUserWithIdentity(ctx, user, link)
+		switch {
+		case err == nil:
+			return user, nil
+		case errors.Is(err, postgres.ErrUsernameTaken):
+			continue
+		case errors.Is(err, postgres.ErrEmailTaken):
+			return nil, ErrExternalEmailTaken
+		default:
+			return nil, err
+		}
+	}
+	return nil, ErrExternalUsernameBusy
+}
+
+// externalUsername подбирает имя пользователя по утверждениям провайдера:
+// preferred_username, затем начало адреса почты, затем имя.
+func externalUsername(providerName string, identity *oidc.Identity) string {
+	local, _, _ := strings.Cut(identity.Email, "@")
+	for _, candidate := range []string{identity.PreferredUsername, local, identity.Name} {
+		name := sanitizeUsername(candidate)
+		if len(name) >= externalUsernameMin {
+			return name
+		}
+	}
+	return providerName + "-user"
+}
+
+// sanitizeUsername оставляет в имени латинские буквы, цифры, точку, дефис и подчеркивание.
+func sanitizeUsername(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+		case r == '.' || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('_')
+		}
+		if b.Len() == externalUsernameMax {
+			break
+		}
+	}
+	return strings.Trim(b.String(), ".-_")
+}
@@ -0,0 +1,389 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"marketplace/internal/config"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
+	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/auth"
+	"marketplace/pkg/oidc"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	fakeClientID     = "marketplace"
+	fakeClientSecret = "client-secret"
+	fakeRedirectURL  = "http://localhost/api/v1/auth/oidc/fake/callback"
+)
+
+// fakeGrant - вход пользователя у провайдера, который ждет обмена кода на токены.
+type fakeGrant struct {
+	challenge     string
+	nonce         string
+	subject       string
+	email         string
+	emailVerified bool
+}
+
+// fakeIdP - провайдер OpenID Connect в памяти: discovery, JWKS и обмен кода с проверкой PKCE.
+type fakeIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]fakeGrant
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdP{key: key, grants: make(map[string]fakeGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		base := idp.server.URL
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                base,
+			"authorization_endpoint":                base + "/authorize",
+			"token_endpoint":                        base + "/token",
+			"jwks_uri":                              base + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "fake", "alg": "RS256", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.serveToken)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *fakeIdP) provider() *oidc.Provider {
+	return oidc.NewProvider(config.OIDCProvider{
+		Name:         "fake",
+		Issuer:       idp.server.URL,
+		ClientID:     fakeClientID,
+		ClientSecret: fakeClientSecret,
+		RedirectURL:  fakeRedirectURL,
+		Scopes:       []string{"email"},
+	})
+}
+
+// authorize имитирует вход пользователя на странице провайдера и возвращает
+// код и state, с которыми провайдер перенаправил бы пользователя обратно.
+func (idp *fakeIdP) authorize(t *testing.T, authURL string, grant fakeGrant) (code, state string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	assert.Equal(t, fakeClientID, q.Get("client_id"))
+	assert.Equal(t, fakeRedirectURL, q.Get("redirect_uri"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+	assert.Contains(t, q.Get("scope"), "openid")
+
+	grant.challenge = q.Get("code_challenge")
+	if grant.nonce == "" {
+		grant.nonce = q.Get("nonce")
+	}
+	code = rand.Text()
+
+	idp.mu.Lock()
+	idp.grants[code] = grant
+	idp.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func (idp *fakeIdP) serveToken(w http.ResponseWriter, r *http.Request) {
+	if id, secret, ok := r.BasicAuth(); !ok || id != fakeClientID || secret != fakeClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	idp.mu.Lock()
+	grant, ok := idp.grants[r.PostFormValue("code")]
+	delete(idp.grants, r.PostFormValue("code"))
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"sub":            grant.subject,
+		"aud":            fakeClientID,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"nonce":          grant.nonce,
+		"email":          grant.email,
+		"email_verified": grant.emailVerified,
+	})
+	token.Header["kid"] = "fake"
+	idToken, _ := token.SignedString(idp.key)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "provider-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// memoryStates - хранилище попыток входа в памяти с той же однократной выдачей, что и в Redis.
+type memoryStates struct {
+	mu     sync.Mutex
+	states map[string]*models.OIDCState
+}
+
+func (m *memoryStates) SaveState(_ context.Context, hash string, state *models.OIDCState, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[hash] = state
+	return nil
+}
+
+func (m *memoryStates) TakeState(_ context.Context, hash string) (*models.OIDCState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.states[hash]
+	if !ok {
+		return nil, cache.ErrOIDCStateNotFound
+	}
+	delete(m.states, hash)
+	return state, nil
+}
+
+type externalLoginFixture struct {
+	idp          *fakeIdP
+	service      AuthService
+	identityRepo *postgres.MockIdentityRepository
+	refreshRepo  *postgres.MockRefreshTokenRepository
+	tokenRepo    *cache.MockTokenRepository
+	challenges   *cache.MockChallengeRepository
+}
+
+func newExternalLoginFixture(t *testing.T) *externalLoginFixture {
+	f := &externalLoginFixture{
+		idp:          newFakeIdP(t),
+		identityRepo: new(postgres.MockIdentityRepository),
+		refreshRepo:  new(postgres.MockRefreshTokenRepository),
+		tokenRepo:    new(cache.MockTokenRepository),
+		challenges:   new(cache.MockChallengeRepository),
+	}
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Hour, RefreshTokenTTL: 24 * time.Hour})
+	external := NewExternalLogin(oidc.Providers{"fake": f.idp.provider()}, f.identityRepo, &memoryStates{states: make(map[string]*models.OIDCState)}, 10*time.Minute)
+	f.service = NewAuthService(
+		new(postgres.MockUserRepository), f.refreshRepo, f.tokenRepo, f.challenges,
+		new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, nil, external, slog.New(slog.DiscardHandler),
+	)
+
+	f.refreshRepo.On("CreateRefreshToken", mock.Anything, mock.Anything, mock.Anything).Return(int64(1), nil).Maybe()
+	f.tokenRepo.On("TokenGeneration", mock.Anything, mock.Anything).Return(int64(0), nil).Maybe()
+	return f
+}
+
+// login проходит вход у провайдера целиком и возвращает результат возврата.
+func (f *externalLoginFixture) login(t *testing.T, linkUserID int64, grant fakeGrant) (*models.ExternalLoginResult, error) {
+	authURL, err := f.service.BeginExternalLogin(context.Background(), "fake", linkUserID)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	code, state := f.idp.authorize(t, authURL, grant)
+	return f.service.CompleteExternalLogin(context.Background(), "fake", state, code)
+}
+
+// Первый вход через провайдера регистрирует пользователя с подтвержденной провайдером почтой
+func TestAuthService_ExternalLogin_Register(t *testing.T) {
+	testCases := []struct {
+		name          string
+		grant         fakeGrant
+		expectedName  string
+		expectedEmail string
+	}{
+		{
+			name:          "Подтвержденная почта",
+			grant:         fakeGrant{subject: "alice-sub", email: "Alice.Smith@example.com", emailVerified: true},
+			expectedName:  "Alice.Smith",
+			expectedEmail: "Alice.Smith@example.com",
+		},
+		{
+			name:         "Неподтвержденная почта не присваивается",
+			grant:        fakeGrant{subject: "bob-sub", email: "bob@example.com"},
+			expectedName: "fake-user",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newExternalLoginFixture(t)
+			f.identityRepo.On("GetUserByIdentity", mock.Anything, "fake", tc.grant.subject).Return(nil, postgres.ErrIdentityNotFound)
+			var created *models.User
+			var linked *models.ExternalIdentity
+			f.identityRepo.On("CreateUserWithIdentity", mock.Anything, mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) {
+					created = args.Get(1).(*models.User)
+					created.ID = 42
+					linked = args.Get(2).(*models.ExternalIdentity)
+				}).
+				Return(int64(42), nil)
+
+			result, err := f.login(t, 0, tc.grant)
+
+			assert.NoError(t, err)
+			assert.NotEmpty(t, result.Login.Tokens.AccessToken)
+			if tc.expectedName != "fake-user" {
+				assert.Equal(t, tc.expectedName, created.Username)
+			}
+			assert.Equal(t, tc.expectedEmail, created.Email)
+			assert.Equal(t, tc.expectedEmail != "", created.EmailVerifiedAt != nil)
+			assert.Equal(t, tc.grant.subject, linked.Subject)
+		})
+	}
+}
+
+// Занятое имя пользователя дополняется случайным суффиксом, а занятая почта требует привязки
+func TestAuthService_ExternalLogin_RegisterConflicts(t *testing.T) {
+	f := newExternalLoginFixture(t)
+	f.identityRepo.On("GetUserByIdentity", mock.Anything, "fake", mock.Anything).Return(nil, postgres.ErrIdentityNotFound)
+	var usernames []string
+	f.identityRepo.On("CreateUserWithIdentity", mock.Anything, mock.MatchedBy(func(u *models.User) bool { return u.Email == "" }), mock.Anything).
+		Run(func(args mock.Arguments) { usernames = append(usernames, args.Get(1).(*models.User).Username) }).
+		Return(int64(0), postgres.ErrUsernameTaken).Once()
+	f.identityRepo.On("CreateUserWithIdentity", mock.Anything, mock.MatchedBy(func(u *models.User) bool { return u.Email == "" }), mock.Anything).
+		Run(func(args mock.Arguments) { usernames = append(usernames, args.Get(1).(*models.User).Username) }).
+		Return(int64(43), nil).Once()
+	f.identityRepo.On("CreateUserWithIdentity", mock.Anything, mock.MatchedBy(func(u *models.User) bool { return u.Email != "" }), mock.Anything).
+		Return(int64(0), postgres.ErrEmailTaken)
+
+	_, err := f.login(t, 0, fakeGrant{subject: "carol-sub", email: "carol@example.com"})
+	assert.NoError(t, err)
+	if assert.Len(t, usernames, 2) {
+		assert.Equal(t, "carol", usernames[0])
+		assert.Regexp(t, `^carol-\d{4}$`, usernames[1])
+	}
+
+	_, err = f.login(t, 0, fakeGrant{subject: "dave-sub", email: "dave@example.com", emailVerified: true})
+	assert.ErrorIs(t, err, ErrExternalEmailTaken)
+}
+
+func TestAuthService_ExternalLogin_ExistingUser(t *testing.T) {
+	enabledAt := time.Now()
+	bannedAt := time.Now()
+
+	testCases := []struct {
+		name      string
+		user      *models.User
+		expectErr error
+		challenge bool
+	}{
+		{name: "Вход привязанного пользователя", user: &models.User{ID: 7, Username: "seller", Role: models.RoleUser}},
+		{name: "Включена 2FA", user: &models.User{ID: 7, Username: "seller", TOTPEnabledAt: &enabledAt}, challenge: true},
+		{name: "Пользователь заблокирован", user: &models.User{ID: 7, Username: "seller", BannedAt: &bannedAt}, expectErr: ErrUserBanned},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newExternalLoginFixture(t)
+			f.identityRepo.On("GetUserByIdentity", mock.Anything, "fake", "seller-sub").Return(tc.user, nil)
+			f.challenges.On("CreateChallenge", mock.Anything, mock.Anything, int64(7), 5*time.Minute).Return(nil).Maybe()
+
+			result, err := f.login(t, 0, fakeGrant{subject: "seller-sub"})
+
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.challenge, result.Login.Challenge != nil)
+			assert.Equal(t, !tc.challenge, result.Login.Tokens != nil)
+			f.identityRepo.AssertNotCalled(t, "CreateUserWithIdentity", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// Вход, начатый вошедшим пользователем, привязывает к нему учетную запись провайдера
+func TestAuthService_ExternalLogin_Link(t *testing.T) {
+	f := newExternalLoginFixture(t)
+	f.identityRepo.On("LinkIdentity", mock.Anything, mock.MatchedBy(func(i *models.ExternalIdentity) bool {
+		return i.UserID == 7 && i.Provider == "fake" && i.Subject == "seller-sub"
+	})).Return(int64(5), nil).Once()
+	f.identityRepo.On("LinkIdentity", mock.Anything, mock.Anything).Return(int64(0), postgres.ErrIdentityLinked)
+
+	result, err := f.login(t, 7, fakeGrant{subject: "seller-sub"})
+	assert.NoError(t, err)
+	assert.Nil(t, result.Login)
+	assert.Equal(t, "seller-sub", result.Linked.Subject)
+
+	_, err = f.login(t, 8, fakeGrant{subject: "seller-sub"})
+	assert.ErrorIs(t, err, postgres.ErrIdentityLinked)
+	f.identityRepo.AssertNotCalled(t, "GetUserByIdentity", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthService_ExternalLogin_Rejected(t *testing.T) {
+	t.Run("Неизвестный провайдер", func(t *testing.T) {
+		f := newExternalLoginFixture(t)
+		_, err := f.service.BeginExternalLogin(context.Background(), "unknown", 0)
+		assert.ErrorIs(t, err, ErrUnknownProvider)
+	})
+
+	t.Run("Чужой nonce в ID-токене", func(t *testing.T) {
+		f := newExternalLoginFixture(t)
+		_, err := f.login(t, 0, fakeGrant{subject: "sub", nonce: "replayed-nonce"})
+		assert.ErrorIs(t, err, ErrExternalAuthFailed)
+	})
+
+	t.Run("Повторное использование state", func(t *testing.T) {
+		f := newExternalLoginFixture(t)
+		f.identityRepo.On("GetUserByIdentity", mock.Anything, "fake", "sub").Return(&models.User{ID: 7}, nil)
+
+		authURL, _ := f.service.BeginExternalLogin(context.Background(), "fake", 0)
+		code, state := f.idp.authorize(t, authURL, fakeGrant{subject: "sub"})
+		_, err := f.service.CompleteExternalLogin(context.Background(), "fake", state, code)
+		assert.NoError(t, err)
+
+		_, err = f.service.CompleteExternalLogin(context.Background(), "fake", state, code)
+		assert.ErrorIs(t, err, ErrInvalidOIDCState)
+	})
+
+	t.Run("Код без верного code_verifier", func(t *testing.T) {
+		f := newExternalLoginFixture(t)
+		authURL, _ := f.service.BeginExternalLogin(context.Background(), "fake", 0)
+		code, _ := f.idp.authorize(t, authURL, fakeGrant{subject: "sub"})
+
+		// Злоумышленник подставляет перехваченный код в свою попытку входа.
+		otherURL, _ := f.service.BeginExternalLogin(context.Background(), "fake", 0)
+		_, otherState := f.idp.authorize(t, otherURL, fakeGrant{subject: "attacker"})
+		_, err := f.service.CompleteExternalLogin(context.Background(), "fake", otherState, code)
+		assert.ErrorIs(t, err, ErrExternalAuthFailed)
+	})
+}
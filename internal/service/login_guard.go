@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
+	"marketplace/internal/repository/postgres"
+	"strings"
+	"time"
+)
+
+// LoginPolicy задает пороги защиты входа от подбора пароля.
+type LoginPolicy struct {
+	// MaxAttempts - сколько неудач подряд допускается для одного имени пользователя.
+	MaxAttempts int
+	// IPMaxAttempts - то же для одного IP-адреса, по всем именам.
+	IPMaxAttempts int
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+	Window        time.Duration
+}
+
+// delay возвращает длительность блокировки после excess неудач сверх порога:
+// BaseDelay, затем вдвое больше за каждую следующую неудачу, но не дольше MaxDelay.
+func (p LoginPolicy) delay(excess int64) time.Duration {
+	d := p.BaseDelay
+	for i := int64(0); i < excess && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxDelay)
+}
+
+// LoginLockedError возвращается, пока вход временно заблокирован из-за
+// слишком большого числа неудачных попыток.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry after %s", e.RetryAfter)
+}
+
+// LoginGuard считает неудачные попытки входа по имени пользователя и по IP-адресу
+// и временно блокирует вход по ключу, превысившему порог. Если хранилище счетчиков
+// недоступно, вход не блокируется. Нулевой указатель отключает защиту.
+type LoginGuard struct {
+	attempts  cache.LoginAttemptRepository
+	auditRepo postgres.AuditRepository
+	policy    LoginPolicy
+	log       *slog.Logger
+}
+
+func NewLoginGuard(
+	attempts cache.LoginAttemptRepository,
+	auditRepo postgres.AuditRepository,
+	policy LoginPolicy,
+	log *slog.Logger,
+) *LoginGuard {
+	return &LoginGuard{
+		attempts:  attempts,
+		auditRepo: auditRepo,
+		policy:    policy,
+		log:       log,
+	}
+}
+
+// guardKey - ключ счетчика с порогом для него.
+type guardKey struct {
+	key       string
+	threshold int
+}
+
+func (g *LoginGuard) keys(username, ip string) []guardKey {
+	keys := []guardKey{{key: "user:" + strings.ToLower(username), threshold: g.policy.MaxAttempts}}
+	if ip != "" {
+		keys = append(keys, guardKey{key: "ip:" + ip, threshold: g.policy.IPMaxAttempts})
+	}
+	return keys
+}
+
+// Check возвращает *LoginLockedError, если вход заблокирован по имени или по IP.
+func (g *LoginGuard) Check(ctx context.Context, username, ip string) error {
+	if g == nil {
+		return nil
+	}
+
+	var retryAfter time.Duration
+	for _, k := range g.keys(username, ip) {
+		d, err := g.attempts.LockedFor(ctx, k.key)
+		if err != nil {
+			g.log.Warn("login attempts storage unavailable", slog.String("error", err.Error()))
+			return nil
+		}
+		retryAfter = max(retryAfter, d)
+	}
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Failed учитывает неудачную попытку. Ключ, достигший порога, блокируется,
+// а блокировка записывается в журнал безопасности. userID пуст, если
+// пользователя с таким именем нет.
+func (g *LoginGuard) Failed(ctx context.Context, username, ip string, userID *int64) {
+	if g == nil {
+		return
+	}
+
+	for _, k := range g.keys(username, ip) {
+		failures, err := g.attempts.RegisterFailure(ctx, k.key, g.policy.Window)
+		if err != nil {
+			g.log.Warn("login attempts storage unavailable", slog.String("error", err.Error()))
+			return
+		}
+		if failures < int64(k.threshold) {
+			continue
+		}
+
+		d := g.policy.delay(failures - int64(k.threshold))
+		if err := g.attempts.Lock(ctx, k.key, d); err != nil {
+			g.log.Warn("failed to lock login", slog.String("key", k.key), slog.String("error", err.Error()))
+			continue
+		}
+		g.audit(ctx, &models.AuditEntry{
+			UserID:  userID,
+			Action:  models.AuditLoginLockout,
+			IP:      ip,
+			Details: fmt.Sprintf("key=%s failures=%d locked_for=%s", k.key, failures, d),
+		})
+	}
+}
+
+// Succeeded сбрасывает счетчик неудач по имени пользователя. Счетчик по IP
+// не сбрасывается, чтобы вход в свой аккаунт не открывал перебор чужих.
+func (g *LoginGuard) Succeeded(ctx context.Context, username string) {
+	if g == nil {
+		return
+	}
+	if err := g.attempts.Reset(ctx, g.keys(username, "")[0].key); err != nil {
+		g.log.Warn("failed to reset login attempts", slog.String("error", err.Error()))
+	}
+}
+
+// audit пишет событие в журнал. Блокировка уже действует, поэтому ошибка только логируется.
+func (g *LoginGuard) audit(ctx context.Context, e *models.AuditEntry) {
+	if _, err := g.auditRepo.CreateEntry(ctx, e); err != nil {
+		g.log.Error("failed to write audit entry",
+			slog.String("action", e.Action),
+			slog.String("error", err.Error()),
+		)
+	}
+}
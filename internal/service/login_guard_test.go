@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"marketplace/internal/config"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
+	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/auth"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testLoginPolicy = LoginPolicy{
+	MaxAttempts:   3,
+	IPMaxAttempts: 10,
+	BaseDelay:     30 * time.Second,
+	MaxDelay:      5 * time.Minute,
+	Window:        time.Hour,
+}
+
+func TestLoginPolicy_Delay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, testLoginPolicy.delay(0))
+	assert.Equal(t, time.Minute, testLoginPolicy.delay(1))
+	assert.Equal(t, 4*time.Minute, testLoginPolicy.delay(3))
+	assert.Equal(t, 5*time.Minute, testLoginPolicy.delay(100))
+}
+
+// Пока действует блокировка, пароль не проверяется
+func TestAuthService_Login_Locked(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Hour})
+	mockUserRepo := new(postgres.MockUserRepository)
+	mockAttempts := new(cache.MockLoginAttemptRepository)
+	guard := NewLoginGuard(mockAttempts, new(postgres.MockAuditRepository), testLoginPolicy, slog.New(slog.DiscardHandler))
+	authService := NewAuthService(mockUserRepo, new(postgres.MockRefreshTokenRepository), new(cache.MockTokenRepository), new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, guard, nil, slog.New(slog.DiscardHandler))
+
+	mockAttempts.On("LockedFor", mock.Anything, "user:seller").Return(time.Duration(0), nil)
+	mockAttempts.On("LockedFor", mock.Anything, "ip:192.0.2.1").Return(90*time.Second, nil)
+
+	result, err := authService.Login(context.Background(), "Seller", "password123", "192.0.2.1")
+
+	var locked *LoginLockedError
+	if assert.ErrorAs(t, err, &locked) {
+		assert.Equal(t, 90*time.Second, locked.RetryAfter)
+	}
+	assert.Nil(t, result)
+	mockUserRepo.AssertNotCalled(t, "GetUserByUsername", mock.Anything, mock.Anything)
+}
+
+// Неудача, достигшая порога, блокирует вход по имени и попадает в журнал
+func TestAuthService_Login_Lockout(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Hour})
+	mockUserRepo := new(postgres.MockUserRepository)
+	mockAttempts := new(cache.MockLoginAttemptRepository)
+	mockAudit := new(postgres.MockAuditRepository)
+	guard := NewLoginGuard(mockAttempts, mockAudit, testLoginPolicy, slog.New(slog.DiscardHandler))
+	authService := NewAuthService(mockUserRepo, new(postgres.MockRefreshTokenRepository), new(cache.MockTokenRepository), new(cache.MockChallengeRepository), new(MockTwoFactorService), tm, testHasher, nil, 5*time.Minute, guard, nil, slog.New(slog.DiscardHandler))
+
+	mockAttempts.On("LockedFor", mock.Anything, mock.Anything).Return(time.Duration(0), nil)
+	mockUserRepo.On("GetUserByUsername", mock.Anything, "seller").Return(&models.User{ID: 7, Username: "seller", Password: "hash"}, nil)
+	mockAttempts.On("RegisterFailure", mock.Anything, "user:seller", time.Hour).Return(int64(4), nil)
+	mockAttempts.On("RegisterFailure", mock.Anything, "ip:192.0.2.1", time.Hour).Return(int64(4), nil)
+	mockAttempts.On("Lock", mock.Anything, "user:seller", time.Minute).Return(nil)
+	mockAudit.On("CreateEntry", mock.Anything, mock.MatchedBy(func(e *models.AuditEntry) bool {
+		return e.Action == models.AuditLoginLockout && e.UserID != nil && *e.UserID == 7 && e.IP == "192.0.2.1"
+	})).Return(int64(1), nil)
+
+	_, err := authService.Login(context.Background(), "seller", "wrong-password", "192.0.2.1")
+
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	mockAttempts.AssertExpectations(t)
+	mockAudit.AssertExpectations(t)
+	mockAttempts.AssertNotCalled(t, "Lock", mock.Anything, "ip:192.0.2.1", mock.Anything)
+}
+
+// Верный пароль при включенной 2FA не сбрасывает счетчик неудач, а неверный
+// код учитывается по имени и IP. Поэтому повторный вход по паролю ради нового
+// challenge не дает бесконечных попыток подобрать код.
+func TestAuthService_LoginTwoFactor_CountsWrongCodes(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Hour})
+	mockUserRepo := new(postgres.MockUserRepository)
+	mockChallengeRepo := new(cache.MockChallengeRepository)
+	mockTwoFactor := new(MockTwoFactorService)
+	mockAttempts := new(cache.MockLoginAttemptRepository)
+	guard := NewLoginGuard(mockAttempts, new(postgres.MockAuditRepository), testLoginPolicy, slog.New(slog.DiscardHandler))
+	authService := NewAuthService(mockUserRepo, new(postgres.MockRefreshTokenRepository), new(cache.MockTokenRepository), mockChallengeRepo, mockTwoFactor, tm, testHasher, nil, 5*time.Minute, guard, nil, slog.New(slog.DiscardHandler))
+
+	hashedPassword, _ := testHasher.Hash("password123")
+	enabledAt := time.Now()
+	user := &models.User{ID: 7, Username: "seller", Password: hashedPassword, TOTPEnabledAt: &enabledAt}
+	mockUserRepo.On("GetUserByUsername", mock.Anything, "seller").Return(user, nil)
+	mockUserRepo.On("GetUserByID", mock.Anything, int64(7)).Return(user, nil)
+	mockChallengeRepo.On("CreateChallenge", mock.Anything, mock.AnythingOfType("string"), int64(7), 5*time.Minute).Return(nil)
+	mockChallengeRepo.On("TakeChallenge", mock.Anything, mock.AnythingOfType("string")).Return(int64(7), 5*time.Minute, nil)
+	mockChallengeRepo.On("IncrChallengeAttempts", mock.Anything, mock.AnythingOfType("string"), 5*time.Minute).Return(int64(1), nil)
+	mockTwoFactor.On("Verify", mock.Anything, int64(7), "000000").Return(ErrInvalidTwoFactorCode)
+	mockAttempts.On("LockedFor", mock.Anything, mock.Anything).Return(time.Duration(0), nil)
+	mockAttempts.On("RegisterFailure", mock.Anything, "user:seller", time.Hour).Return(int64(1), nil).Once()
+	mockAttempts.On("RegisterFailure", mock.Anything, "ip:192.0.2.1", time.Hour).Return(int64(1), nil).Once()
+
+	ctx := WithClientInfo(context.Background(), models.ClientInfo{IP: "192.0.2.1"})
+	result, err := authService.Login(ctx, "seller", "password123", "192.0.2.1")
+	if !assert.NoError(t, err) || !assert.NotNil(t, result.Challenge) {
+		return
+	}
+	_, err = authService.LoginTwoFactor(ctx, result.Challenge.Token, "000000")
+
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+	mockAttempts.AssertExpectations(t)
+	mockAttempts.AssertNotCalled(t, "Reset", mock.Anything, mock.Anything)
+}
+
+// Заблокированный по имени пользователь не может проверить код второго фактора
+func TestAuthService_LoginTwoFactor_Locked(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Hour})
+	mockUserRepo := new(postgres.MockUserRepository)
+	mockChallengeRepo := new(cache.MockChallengeRepository)
+	mockTwoFactor := new(MockTwoFactorService)
+	mockAttempts := new(cache.MockLoginAttemptRepository)
+	guard := NewLoginGuard(mockAttempts, new(postgres.MockAuditRepository), testLoginPolicy, slog.New(slog.DiscardHandler))
+	authService := NewAuthService(mockUserRepo, new(postgres.MockRefreshTokenRepository), new(cache.MockTokenRepository), mockChallengeRepo, mockTwoFactor, tm, testHasher, nil, 5*time.Minute, guard, nil, slog.New(slog.DiscardHandler))
+
+	mockChallengeRepo.On("TakeChallenge", mock.Anything, auth.HashOpaqueToken("challenge")).Return(int64(7), 5*time.Minute, nil)
+	mockChallengeRepo.On("IncrChallengeAttempts", mock.Anything, auth.HashOpaqueToken("challenge"), 5*time.Minute).Return(int64(1), nil)
+	mockUserRepo.On("GetUserByID", mock.Anything, int64(7)).Return(&models.User{ID: 7, Username: "seller"}, nil)
+	mockAttempts.On("LockedFor", mock.Anything, "user:seller").Return(time.Minute, nil)
+	mockAttempts.On("LockedFor", mock.Anything, "ip:192.0.2.1").Return(time.Duration(0), nil)
+	// Токен возвращается, чтобы ввести код после снятия блокировки
+	mockChallengeRepo.On("CreateChallenge", mock.Anything, auth.HashOpaqueToken("challenge"), int64(7), 5*time.Minute).Return(nil)
+
+	ctx := WithClientInfo(context.Background(), models.ClientInfo{IP: "192.0.2.1"})
+	_, err := authService.LoginTwoFactor(ctx, "challenge", "123456")
+
+	var locked *LoginLockedError
+	if assert.ErrorAs(t, err, &locked) {
+		assert.Equal(t, time.Minute, locked.RetryAfter)
+	}
+	mockTwoFactor.AssertNotCalled(t, "Verify", mock.Anything, mock.Anything, mock.Anything)
+	mockChallengeRepo.AssertExpectations(t)
+}
+
+// Счетчик по имени сбрасывается только после верного кода второго фактора
+func TestAuthService_LoginTwoFactor_ResetsGuard(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Hour})
+	mockUserRepo := new(postgres.MockUserRepository)
+	mockRefreshRepo := new(postgres.MockRefreshTokenRepository)
+	mockTokenRepo := new(cache.MockTokenRepository)
+	mockChallengeRepo := new(cache.MockChallengeRepository)
+	mockTwoFactor := new(MockTwoFactorService)
+	mockAttempts := new(cache.MockLoginAttemptRepository)
+	guard := NewLoginGuard(mockAttempts, new(postgres.MockAuditRepository), testLoginPolicy, slog.New(slog.DiscardHandler))
+	authService := NewAuthService(mockUserRepo, mockRefreshRepo, mockTokenRepo, mockChallengeRepo, mockTwoFactor, tm, testHasher, nil, 5*time.Minute, guard, nil, slog.New(slog.DiscardHandler))
+
+	mockChallengeRepo.On("TakeChallenge", mock.Anything, auth.HashOpaqueToken("challenge")).Return(int64(7), 5*time.Minute, nil)
+	mockChallengeRepo.On("IncrChallengeAttempts", mock.Anything, auth.HashOpaqueToken("challenge"), 5*time.Minute).Return(int64(1), nil)
+	mockChallengeRepo.On("DeleteChallenge", mock.Anything, auth.HashOpaqueToken("challenge")).Return(nil)
+	mockUserRepo.On("GetUserByID", mock.Anything, int64(7)).Return(&models.User{ID: 7, Username: "seller"}, nil)
+	mockAttempts.On("LockedFor", mock.Anything, mock.Anything).Return(time.Duration(0), nil)
+	mockTwoFactor.On("Verify", mock.Anything, int64(7), "123456").Return(nil)
+	mockAttempts.On("Reset", mock.Anything, "user:seller").Return(nil)
+	mockRefreshRepo.On("CreateRefreshToken", mock.Anything, mock.Anything, mock.Anything).Return(int64(1), nil)
+	mockTokenRepo.On("TokenGeneration", mock.Anything, int64(7)).Return(int64(0), nil)
+
+	_, err := authService.LoginTwoFactor(context.Background(), "challenge", "123456")
+
+	assert.NoError(t, err)
+	mockAttempts.AssertExpectations(t)
+}
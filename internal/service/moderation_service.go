@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
+	"marketplace/internal/repository/postgres"
+	"sort"
+	"time"
+)
+
+var (
+	ErrCannotReportOwnAd = errors.New("cannot report your own ad")
+)
+
+// notificationMessages - тексты уведомлений владельцу по каждому решению модератора.
+var notificationMessages = map[string]struct {
+	Type    string
+	Message string
+}{
+	models.DecisionHide: {
+		Type:    models.NotificationAdHidden,
+		Message: "Ваше объявление «%s» скрыто модератором по жалобе пользователей.",
+	},
+	models.DecisionDismiss: {
+		Type:    models.NotificationAdReviewed,
+		Message: "Жалоба на ваше объявление «%s» рассмотрена, нарушений не найдено.",
+	},
+	models.DecisionBanAuthor: {
+		Type:    models.NotificationAccountBanned,
+		Message: "Ваш аккаунт заблокирован за нарушение правил в объявлении «%s», все объявления скрыты.",
+	},
+}
+
+// premoderationMessages - тексты уведомлений по объявлению, которое
+// автоматическая проверка отправила на премодерацию.
+var premoderationMessages = map[string]struct {
+	Type    string
+	Message string
+}{
+	models.DecisionHide: {
+		Type:    models.NotificationAdHidden,
+		Message: "Ваше объявление «%s» не прошло проверку модератором и не будет опубликовано.",
+	},
+	models.DecisionDismiss: {
+		Type:    models.NotificationAdApproved,
+		Message: "Ваше объявление «%s» проверено модератором и опубликовано.",
+	},
+}
+
+type moderationService struct {
+	reportRepo       postgres.ReportRepository
+	adRepo           postgres.AdRepository
+	notificationRepo postgres.NotificationRepository
+	suggestRepo      cache.SuggestRepository
+	duplicates       DuplicatePolicy
+	log              *slog.Logger
+}
+
+func NewModerationService(
+	reportRepo postgres.ReportRepository,
+	adRepo postgres.AdRepository,
+	notificationRepo postgres.NotificationRepository,
+	suggestRepo cache.SuggestRepository,
+	duplicates DuplicatePolicy,
+	log *slog.Logger,
+) ModerationService {
+	return &moderationService{
+		reportRepo:       reportRepo,
+		adRepo:           adRepo,
+		notificationRepo: notificationRepo,
+		suggestRepo:      suggestRepo,
+		duplicates:       duplicates,
+		log:              log,
+	}
+}
+
+func (s *moderationService) ReportAd(ctx context.Context, adID, reporterID int64, reason, comment string) (int64, error) {
+	const op = "service.ReportAd"
+
+	ad, err := s.adRepo.GetAdByID(ctx, adID)
+	if err != nil {
+		return 0, err
+	}
+	// Скрытые и еще не опубликованные объявления видны только владельцу.
+	if ad.HiddenAt != nil || ad.IsScheduled(time.Now()) {
+		return 0, postgres.ErrAdNotFound
+	}
+	if ad.UserID == reporterID {
+		return 0, ErrCannotReportOwnAd
+	}
+
+	id, err := s.reportRepo.CreateReport(ctx, &models.Report{
+		AdID:       adID,
+		ReporterID: &reporterID,
+		Reason:     reason,
+		Comment:    comment,
+	})
+	if err != nil {
+		if errors.Is(err, postgres.ErrReportExists) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+func (s *moderationService) GetReports(ctx context.Context, status string, limit, offset int) ([]models.Report, error) {
+	reports, err := s.reportRepo.GetReports(ctx, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetReports: %w", err)
+	}
+	return reports, nil
+}
+
+func (s *moderationService) ClaimReport(ctx context.Context, id, moderatorID int64) error {
+	if _, err := s.reportRepo.GetReportByID(ctx, id); err != nil {
+		return err
+	}
+	return s.reportRepo.ClaimReport(ctx, id, moderatorID)
+}
+
+func (s *moderationService) ResolveReport(ctx context.Context, id, moderatorID int64, decision string) error {
+	const op = "service.ResolveReport"
+
+	report, err := s.reportRepo.GetReportByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	ad, err := s.adRepo.GetAdByID(ctx, report.AdID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Объявления, которые скроет решение, запоминаем до транзакции, чтобы
+	// затем убрать их заголовки из подсказок.
+	var hidden []models.Ad
+	switch decision {
+	case models.DecisionHide:
+		hidden = []models.Ad{*ad}
+	case models.DecisionBanAuthor:
+		hidden, err = s.adRepo.GetAdsByUserID(ctx, ad.UserID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := s.reportRepo.ResolveReport(ctx, id, moderatorID, decision); err != nil {
+		if errors.Is(err, postgres.ErrReportNotClaimed) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if decision == models.DecisionDismiss && ad.HiddenBy == models.HiddenByScreening {
+		s.indexApproved(ctx, ad.ID)
+	}
+	for _, h := range hidden {
+		if !titleIndexed(&h) {
+			continue
+		}
+		if err := s.suggestRepo.RemoveTitle(ctx, h.Title); err != nil {
+			s.log.Warn("failed to remove ad title from index", slog.String("error", err.Error()))
+		}
+	}
+
+	s.notifyOwner(ctx, ad, report.Reason, decision)
+
+	return nil
+}
+
+// indexApproved добавляет в подсказки заголовок объявления, которое
+// модератор опубликовал с премодерации. Решение уже применено, поэтому
+// ошибки только логируются.
+func (s *moderationService) indexApproved(ctx context.Context, adID int64) {
+	ad, err := s.adRepo.GetAdByID(ctx, adID)
+	if err != nil {
+		s.log.Warn("failed to load approved ad", slog.Int64("ad_id", adID), slog.String("error", err.Error()))
+		return
+	}
+	if !titleIndexed(ad) {
+		return
+	}
+	if err := s.suggestRepo.IndexTitle(ctx, ad.Title); err != nil {
+		s.log.Warn("failed to index ad title", slog.String("error", err.Error()))
+	}
+}
+
+// GetDuplicateClusters возвращает группы похожих объявлений, в том числе
+// опубликованных разными продавцами. Сходство текста и цены оценивается по тем
+// же порогам, что и при проверке повторов у одного продавца.
+func (s *moderationService) GetDuplicateClusters(ctx context.Context, minUsers, limit, offset int) ([]models.DuplicateCluster, error) {
+	pairs, err := s.adRepo.GetSimilarAdPairs(ctx, s.duplicates.match())
+	if err != nil {
+		return nil, fmt.Errorf("service.GetDuplicateClusters: %w", err)
+	}
+
+	clusters := clusterDuplicates(pairs, minUsers)
+	if offset >= len(clusters) {
+		return []models.DuplicateCluster{}, nil
+	}
+	return clusters[offset:min(offset+limit, len(clusters))], nil
+}
+
+// clusterDuplicates объединяет пары похожих объявлений в группы: объявления,
+// связанные цепочкой сходства, попадают в одну группу. Возвращаются группы
+// не меньше чем с minUsers продавцами, крупные и свежие идут первыми.
+func clusterDuplicates(pairs []postgres.SimilarPair, minUsers int) []models.DuplicateCluster {
+	ads := make(map[int64]postgres.SimilarAd)
+	parent := make(map[int64]int64)
+	var find func(id int64) int64
+	find = func(id int64) int64 {
+		if parent[id] != id {
+			parent[id] = find(parent[id])
+		}
+		return parent[id]
+	}
+
+	for _, p := range pairs {
+		for _, ad := range []postgres.SimilarAd{p.First, p.Second} {
+			if _, ok := ads[ad.ID]; !ok {
+				ads[ad.ID] = ad
+				parent[ad.ID] = ad.ID
+			}
+		}
+		parent[find(p.First.ID)] = find(p.Second.ID)
+	}
+
+	groups := make(map[int64][]postgres.SimilarAd)
+	for id, ad := range ads {
+		root := find(id)
+		groups[root] = append(groups[root], ad)
+	}
+
+	clusters := []models.DuplicateCluster{}
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			if !group[i].CreatedAt.Equal(group[j].CreatedAt) {
+				return group[i].CreatedAt.Before(group[j].CreatedAt)
+			}
+			return group[i].ID < group[j].ID
+		})
+
+		cluster := models.DuplicateCluster{AdCount: len(group), UserIDs: []int64{}}
+		users := make(map[int64]struct{})
+		for _, ad := range group {
+			cluster.AdIDs = append(cluster.AdIDs, ad.ID)
+			if _, ok := users[ad.UserID]; !ok {
+				users[ad.UserID] = struct{}{}
+				cluster.UserIDs = append(cluster.UserIDs, ad.UserID)
+			}
+		}
+		if len(users) < minUsers {
+			continue
+		}
+		sort.Slice(cluster.UserIDs, func(i, j int) bool { return cluster.UserIDs[i] < cluster.UserIDs[j] })
+		cluster.UserCount = len(users)
+		cluster.LastCreatedAt = group[len(group)-1].CreatedAt
+		clusters = append(clusters, cluster)
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		a, b := clusters[i], clusters[j]
+		if a.AdCount != b.AdCount {
+			return a.AdCount > b.AdCount
+		}
+		if !a.LastCreatedAt.Equal(b.LastCreatedAt) {
+			return a.LastCreatedAt.After(b.LastCreatedAt)
+		}
+		return a.AdIDs[0] < b.AdIDs[0]
+	})
+	return clusters
+}
+
+// notifyOwner сообщает владельцу объявления о решении модератора.
+// Решение уже применено, поэтому ошибка уведомления только логируется.
+func (s *moderationService) notifyOwner(ctx context.Context, ad *models.Ad, reason, decision string) {
+	// Отклонение жалоб не публикует объявление, скрытое модератором, поэтому
+	// владельцу сообщается только о рассмотрении жалобы.
+	messages := notificationMessages
+	if reason == models.ReportReasonAutoScreening && ad.HiddenBy != models.HiddenByModerator {
+		messages = premoderationMessages
+	}
+	tmpl, ok := messages[decision]
+	if !ok {
+		tmpl, ok = notificationMessages[decision]
+	}
+	if !ok {
+		return
+	}
+
+	_, err := s.notificationRepo.CreateNotification(ctx, &models.Notification{
+		UserID:  ad.UserID,
+		Type:    tmpl.Type,
+		Message: fmt.Sprintf(tmpl.Message, ad.Title),
+		AdID:    &ad.ID,
+	})
+	if err != nil {
+		s.log.Warn("failed to notify ad owner",
+			slog.Int64("ad_id", ad.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
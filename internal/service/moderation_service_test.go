@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
+	"marketplace/internal/repository/postgres"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Тестирование успешной отправки жалобы
+func TestModerationService_ReportAd_Success(t *testing.T) {
+	mockReportRepo := new(postgres.MockReportRepository)
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockNotificationRepo := new(postgres.MockNotificationRepository)
+	moderationService := NewModerationService(mockReportRepo, mockAdRepo, mockNotificationRepo, new(cache.MockSuggestRepository), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(&models.Ad{ID: 1, UserID: 10}, nil)
+	mockReportRepo.On("CreateReport", mock.Anything, mock.MatchedBy(func(r *models.Report) bool {
+		return r.AdID == 1 && r.ReporterID != nil && *r.ReporterID == 20 && r.Reason == models.ReportReasonScam
+	})).Return(int64(5), nil)
+
+	id, err := moderationService.ReportAd(context.Background(), 1, 20, models.ReportReasonScam, "просит предоплату")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), id)
+	mockReportRepo.AssertExpectations(t)
+}
+
+// Жаловаться на собственное или скрытое объявление нельзя
+func TestModerationService_ReportAd_Rejected(t *testing.T) {
+	mockReportRepo := new(postgres.MockReportRepository)
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockNotificationRepo := new(postgres.MockNotificationRepository)
+	moderationService := NewModerationService(mockReportRepo, mockAdRepo, mockNotificationRepo, new(cache.MockSuggestRepository), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(&models.Ad{ID: 1, UserID: 10}, nil)
+
+	_, err := moderationService.ReportAd(context.Background(), 1, 10, models.ReportReasonSpam, "")
+
+	assert.ErrorIs(t, err, ErrCannotReportOwnAd)
+	mockReportRepo.AssertNotCalled(t, "CreateReport", mock.Anything, mock.Anything)
+}
+
+// Отложенное объявление для остальных пользователей не существует
+func TestModerationService_ReportAd_Scheduled(t *testing.T) {
+	mockReportRepo := new(postgres.MockReportRepository)
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockNotificationRepo := new(postgres.MockNotificationRepository)
+	moderationService := NewModerationService(mockReportRepo, mockAdRepo, mockNotificationRepo, new(cache.MockSuggestRepository), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	publishAt := time.Now().Add(time.Hour)
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(&models.Ad{ID: 1, UserID: 10, PublishAt: &publishAt}, nil)
+
+	_, err := moderationService.ReportAd(context.Background(), 1, 20, models.ReportReasonSpam, "")
+
+	assert.ErrorIs(t, err, postgres.ErrAdNotFound)
+	mockReportRepo.AssertNotCalled(t, "CreateReport", mock.Anything, mock.Anything)
+}
+
+// Одобренное с премодерации объявление попадает в подсказки
+func TestModerationService_ResolveReport_IndexesApprovedAd(t *testing.T) {
+	mockReportRepo := new(postgres.MockReportRepository)
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockNotificationRepo := new(postgres.MockNotificationRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	moderationService := NewModerationService(mockReportRepo, mockAdRepo, mockNotificationRepo, mockSuggestRepo, DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	hiddenAt := time.Now()
+	mockReportRepo.On("GetReportByID", mock.Anything, int64(3)).Return(&models.Report{ID: 3, AdID: 1, Reason: models.ReportReasonAutoScreening}, nil)
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(&models.Ad{ID: 1, UserID: 10, Title: "Диван", HiddenAt: &hiddenAt, HiddenBy: models.HiddenByScreening}, nil).Once()
+	mockReportRepo.On("ResolveReport", mock.Anything, int64(3), int64(99), models.DecisionDismiss).Return(nil)
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(&models.Ad{ID: 1, UserID: 10, Title: "Диван"}, nil).Once()
+	mockSuggestRepo.On("IndexTitle", mock.Anything, "Диван").Return(nil)
+	mockNotificationRepo.On("CreateNotification", mock.Anything, mock.MatchedBy(func(n *models.Notification) bool {
+		return n.Type == models.NotificationAdApproved
+	})).Return(int64(1), nil)
+
+	err := moderationService.ResolveReport(context.Background(), 3, 99, models.DecisionDismiss)
+
+	assert.NoError(t, err)
+	mockSuggestRepo.AssertExpectations(t)
+	mockNotificationRepo.AssertExpectations(t)
+}
+
+// Отклонение системной жалобы не публикует объявление, скрытое модератором
+func TestModerationService_ResolveReport_DismissKeepsModeratorHide(t *testing.T) {
+	mockReportRepo := new(postgres.MockReportRepository)
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockNotificationRepo := new(postgres.MockNotificationRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	moderationService := NewModerationService(mockReportRepo, mockAdRepo, mockNotificationRepo, mockSuggestRepo, DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	hiddenAt := time.Now()
+	mockReportRepo.On("GetReportByID", mock.Anything, int64(3)).Return(&models.Report{ID: 3, AdID: 1, Reason: models.ReportReasonAutoScreening}, nil)
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(&models.Ad{ID: 1, UserID: 10, Title: "Диван", HiddenAt: &hiddenAt, HiddenBy: models.HiddenByModerator}, nil).Once()
+	mockReportRepo.On("ResolveReport", mock.Anything, int64(3), int64(99), models.DecisionDismiss).Return(nil)
+	mockNotificationRepo.On("CreateNotification", mock.Anything, mock.MatchedBy(func(n *models.Notification) bool {
+		return n.Type == models.NotificationAdReviewed
+	})).Return(int64(1), nil)
+
+	err := moderationService.ResolveReport(context.Background(), 3, 99, models.DecisionDismiss)
+
+	assert.NoError(t, err)
+	mockAdRepo.AssertExpectations(t)
+	mockSuggestRepo.AssertNotCalled(t, "IndexTitle", mock.Anything, mock.Anything)
+	mockNotificationRepo.AssertExpectations(t)
+}
+
+// После решения модератора владелец объявления получает уведомление
+func TestModerationService_ResolveReport_NotifiesOwner(t *testing.T) {
+	mockReportRepo := new(postgres.MockReportRepository)
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockNotificationRepo := new(postgres.MockNotificationRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	moderationService := NewModerationService(mockReportRepo, mockAdRepo, mockNotificationRepo, mockSuggestRepo, DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	reportID := int64(3)
+	moderatorID := int64(99)
+
+	mockReportRepo.On("GetReportByID", mock.Anything, reportID).Return(&models.Report{ID: reportID, AdID: 1}, nil)
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(&models.Ad{ID: 1, UserID: 10, Title: "iPhone"}, nil)
+	mockReportRepo.On("ResolveReport", mock.Anything, reportID, moderatorID, models.DecisionHide).Return(nil)
+	mockSuggestRepo.On("RemoveTitle", mock.Anything, "iPhone").Return(nil)
+	mockNotificationRepo.On("CreateNotification", mock.Anything, mock.MatchedBy(func(n *models.Notification) bool {
+		return n.UserID == 10 && n.Type == models.NotificationAdHidden && *n.AdID == 1
+	})).Return(int64(1), nil)
+
+	err := moderationService.ResolveReport(context.Background(), reportID, moderatorID, models.DecisionHide)
+
+	assert.NoError(t, err)
+	mockReportRepo.AssertExpectations(t)
+	mockNotificationRepo.AssertExpectations(t)
+	mockSuggestRepo.AssertExpectations(t)
+}
+
+// Блокировка автора убирает из подсказок заголовки всех его видимых объявлений
+func TestModerationService_ResolveReport_BanAuthorRemovesTitles(t *testing.T) {
+	mockReportRepo := new(postgres.MockReportRepository)
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockNotificationRepo := new(postgres.MockNotificationRepository)
+	mockSuggestRepo := new(cache.MockSuggestRepository)
+	moderationService := NewModerationService(mockReportRepo, mockAdRepo, mockNotificationRepo, mockSuggestRepo, DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	hiddenAt := time.Now()
+	publishAt := time.Now().Add(time.Hour)
+	mockReportRepo.On("GetReportByID", mock.Anything, int64(3)).Return(&models.Report{ID: 3, AdID: 1}, nil)
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(&models.Ad{ID: 1, UserID: 10, Title: "iPhone"}, nil)
+	mockAdRepo.On("GetAdsByUserID", mock.Anything, int64(10)).Return([]models.Ad{
+		{ID: 1, UserID: 10, Title: "iPhone"},
+		{ID: 2, UserID: 10, Title: "iPad"},
+		{ID: 3, UserID: 10, Title: "Скрытое", HiddenAt: &hiddenAt},
+		{ID: 4, UserID: 10, Title: "Отложенное", PublishAt: &publishAt},
+	}, nil)
+	mockReportRepo.On("ResolveReport", mock.Anything, int64(3), int64(99), models.DecisionBanAuthor).Return(nil)
+	mockSuggestRepo.On("RemoveTitle", mock.Anything, "iPhone").Return(nil)
+	mockSuggestRepo.On("RemoveTitle", mock.Anything, "iPad").Return(nil)
+	mockNotificationRepo.On("CreateNotification", mock.Anything, mock.Anything).Return(int64(1), nil)
+
+	err := moderationService.ResolveReport(context.Background(), 3, 99, models.DecisionBanAuthor)
+
+	assert.NoError(t, err)
+	mockSuggestRepo.AssertExpectations(t)
+	mockSuggestRepo.AssertNumberOfCalls(t, "RemoveTitle", 2)
+}
+
+// Решение по жалобе, не взятой текущим модератором, не применяется
+func TestModerationService_ResolveReport_NotClaimed(t *testing.T) {
+	mockReportRepo := new(postgres.MockReportRepository)
+	mockAdRepo := new(postgres.MockAdRepository)
+	mockNotificationRepo := new(postgres.MockNotificationRepository)
+	moderationService := NewModerationService(mockReportRepo, mockAdRepo, mockNotificationRepo, new(cache.MockSuggestRepository), DuplicatePolicy{}, slog.New(slog.DiscardHandler))
+
+	mockReportRepo.On("GetReportByID", mock.Anything, int64(3)).Return(&models.Report{ID: 3, AdID: 1}, nil)
+	mockAdRepo.On("GetAdByID", mock.Anything, int64(1)).Return(&models.Ad{ID: 1, UserID: 10}, nil)
+	mockReportRepo.On("ResolveReport", mock.Anything, int64(3), int64(99), models.DecisionDismiss).Return(postgres.ErrReportNotClaimed)
+
+	err := moderationService.ResolveReport(context.Background(), 3, 99, models.DecisionDismiss)
+
+	assert.ErrorIs(t, err, postgres.ErrReportNotClaimed)
+	mockNotificationRepo.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything)
+}
+
+// Пары похожих объявлений объединяются в группы по цепочке сходства
+func TestModerationService_GetDuplicateClusters(t *testing.T) {
+	mockAdRepo := new(postgres.MockAdRepository)
+	policy := DuplicatePolicy{Similarity: 0.8, PriceTolerance: 0.1}
+	moderationService := NewModerationService(nil, mockAdRepo, nil, nil, policy, slog.New(slog.DiscardHandler))
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ad := func(id, userID int64, hours int) postgres.SimilarAd {
+		return postgres.SimilarAd{ID: id, UserID: userID, CreatedAt: base.Add(time.Duration(hours) * time.Hour)}
+	}
+	mockAdRepo.On("GetSimilarAdPairs", mock.Anything, postgres.DuplicateMatch{Similarity: 0.8, PriceTolerance: 0.1}).Return([]postgres.SimilarPair{
+		// 1 похоже на 2, 2 похоже на 3: все три в одной группе
+		{First: ad(1, 10, 0), Second: ad(2, 11, 1)},
+		{First: ad(2, 11, 1), Second: ad(3, 10, 2)},
+		// Отдельная пара одного продавца, но свежее
+		{First: ad(4, 12, 5), Second: ad(5, 12, 6)},
+		// Пара разных продавцов
+		{First: ad(6, 13, 3), Second: ad(7, 14, 4)},
+	}, nil)
+
+	clusters, err := moderationService.GetDuplicateClusters(context.Background(), 1, 20, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []models.DuplicateCluster{
+		{AdCount: 3, UserCount: 2, AdIDs: []int64{1, 2, 3}, UserIDs: []int64{10, 11}, LastCreatedAt: base.Add(2 * time.Hour)},
+		{AdCount: 2, UserCount: 1, AdIDs: []int64{4, 5}, UserIDs: []int64{12}, LastCreatedAt: base.Add(6 * time.Hour)},
+		{AdCount: 2, UserCount: 2, AdIDs: []int64{6, 7}, UserIDs: []int64{13, 14}, LastCreatedAt: base.Add(4 * time.Hour)},
+	}, clusters)
+
+	// Фильтр по числу продавцов и пагинация
+	clusters, err = moderationService.GetDuplicateClusters(context.Background(), 2, 1, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, clusters, 1) {
+		assert.Equal(t, []int64{6, 7}, clusters[0].AdIDs)
+	}
+
+	clusters, err = moderationService.GetDuplicateClusters(context.Background(), 2, 20, 5)
+	assert.NoError(t, err)
+	assert.Empty(t, clusters)
+}
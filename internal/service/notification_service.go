@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+)
+
+// notificationsLimit - сколько последних уведомлений отдается пользователю.
+const notificationsLimit = 50
+
+type notificationService struct {
+	notificationRepo postgres.NotificationRepository
+}
+
+func NewNotificationService(notificationRepo postgres.NotificationRepository) NotificationService {
+	return &notificationService{notificationRepo: notificationRepo}
+}
+
+func (s *notificationService) GetNotifications(ctx context.Context, userID int64) ([]models.Notification, error) {
+	notifications, err := s.notificationRepo.GetNotificationsByUserID(ctx, userID, notificationsLimit)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetNotifications: %w", err)
+	}
+	return notifications, nil
+}
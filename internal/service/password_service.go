@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/auth"
+	"marketplace/pkg/hash"
+	"marketplace/pkg/mailer"
+	"marketplace/pkg/passwordpolicy"
+	"time"
+)
+
+var (
+	ErrWrongPassword     = errors.New("current password is incorrect")
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+)
+
+// WeakPasswordError возвращается, когда новый пароль не удовлетворяет политике паролей.
+type WeakPasswordError struct {
+	Violations []passwordpolicy.Violation
+}
+
+func (e *WeakPasswordError) Error() string {
+	return fmt.Sprintf("password rejected by %d policy rule(s)", len(e.Violations))
+}
+
+// checkPasswordPolicy проверяет новый пароль пользователя username и
+// возвращает *WeakPasswordError, если он нарушает политику.
+func checkPasswordPolicy(policy *passwordpolicy.Policy, password, username string) error {
+	violations, err := policy.Check(password, username)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return &WeakPasswordError{Violations: violations}
+	}
+	return nil
+}
+
+const resetMailSubject = "Сброс пароля"
+
+const resetMailBody = `Здравствуйте, %s!
+
+Для сброса пароля отправьте этот код вместе с новым паролем в POST /api/v1/auth/password-reset/confirm:
+
+%s
+
+Код действует %d мин. и может быть использован один раз. Если вы не запрашивали сброс пароля, просто проигнорируйте это письмо.
+`
+
+type passwordService struct {
+	userRepo  postgres.UserRepository
+	resetRepo postgres.PasswordResetRepository
+	auth      AuthService
+	passwords *hash.Hasher
+	policy    *passwordpolicy.Policy
+	mailer    mailer.Mailer
+	resetTTL  time.Duration
+	log       *slog.Logger
+}
+
+func NewPasswordService(
+	userRepo postgres.UserRepository,
+	resetRepo postgres.PasswordResetRepository,
+	auth AuthService,
+	passwords *hash.Hasher,
+	policy *passwordpolicy.Policy,
+	mailer mailer.Mailer,
+	resetTTL time.Duration,
+	log *slog.Logger,
+) PasswordService {
+	return &passwordService{
+		userRepo:  userRepo,
+		resetRepo: resetRepo,
+		auth:      auth,
+		passwords: passwords,
+		policy:    policy,
+		mailer:    mailer,
+		resetTTL:  resetTTL,
+		log:       log,
+	}
+}
+
+// ChangePassword меняет пароль после проверки текущего. Новый пароль должен
+// соответствовать политике паролей. Все сессии пользователя, включая текущую,
+// завершаются.
+func (s *passwordService) ChangePassword(ctx context.Context, userID int64, currentPassword, newPassword string) error {
+	const op = "service.ChangePassword"
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if ok, _ := s.passwords.Verify(currentPassword, user.Password); !ok {
+		return ErrWrongPassword
+	}
+	if err := checkPasswordPolicy(s.policy, newPassword, user.Username); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	hashedPassword, err := s.passwords.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.userRepo.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.auth.LogoutAll(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RequestReset отправляет на почту одноразовый токен сброса пароля. Результат
+// не зависит от того, зарегистрирован ли адрес, чтобы по ответу нельзя было
+// перебирать почтовые адреса пользователей.
+func (s *passwordService) RequestReset(ctx context.Context, email string) error {
+	const op = "service.RequestReset"
+
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, postgres.ErrUserNotFound) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if user.BannedAt != nil {
+		return nil
+	}
+
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	_, err = s.resetRepo.CreateResetToken(ctx, &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashOpaqueToken(token),
+		ExpiresAt: time.Now().Add(s.resetTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: resetMailSubject,
+		Body:    fmt.Sprintf(resetMailBody, user.Username, token, int(s.resetTTL.Minutes())),
+	})
+	if err != nil {
+		// Ошибка отправки не возвращается клиенту: она выдала бы, что адрес зарегистрирован.
+		s.log.Error("failed to send password reset email",
+			slog.Int64("user_id", user.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+	return nil
+}
+
+// ConfirmReset устанавливает новый пароль по токену сброса и завершает все
+// сессии пользователя. Новый пароль проверяется по политике паролей до того,
+// как токен будет погашен.
+func (s *passwordService) ConfirmReset(ctx context.Context, token, newPassword string) error {
+	const op = "service.ConfirmReset"
+
+	tokenHash := auth.HashOpaqueToken(token)
+	userID, err := s.resetRepo.GetResetTokenUserID(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, postgres.ErrResetTokenNotFound) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkPasswordPolicy(s.policy, newPassword, user.Username); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	hashedPassword, err := s.passwords.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err = s.resetRepo.ResetPassword(ctx, tokenHash, hashedPassword)
+	if err != nil {
+		if errors.Is(err, postgres.ErrResetTokenNotFound) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.auth.LogoutAll(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
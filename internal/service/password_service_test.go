@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"log/slog"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/auth"
+	"marketplace/pkg/mailer"
+	"marketplace/pkg/passwordpolicy"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// recordingMailer запоминает отправленные письма.
+type recordingMailer struct {
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(_ context.Context, msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func newTestPasswordService(userRepo *postgres.MockUserRepository, resetRepo *postgres.MockPasswordResetRepository, authService *MockAuthService, mail *recordingMailer) PasswordService {
+	return NewPasswordService(userRepo, resetRepo, authService, testHasher, nil, mail, time.Hour, slog.New(slog.DiscardHandler))
+}
+
+func TestPasswordService_ChangePassword(t *testing.T) {
+	currentHash, _ := testHasher.Hash("old-password")
+
+	testCases := []struct {
+		name            string
+		currentPassword string
+		expectErr       error
+	}{
+		{name: "Успешная смена", currentPassword: "old-password"},
+		{name: "Неверный текущий пароль", currentPassword: "wrong-password", expectErr: ErrWrongPassword},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUserRepo := new(postgres.MockUserRepository)
+			mockAuth := new(MockAuthService)
+			svc := newTestPasswordService(mockUserRepo, new(postgres.MockPasswordResetRepository), mockAuth, &recordingMailer{})
+
+			mockUserRepo.On("GetUserByID", mock.Anything, int64(7)).Return(&models.User{ID: 7, Password: currentHash}, nil)
+			if tc.expectErr == nil {
+				mockUserRepo.On("UpdatePassword", mock.Anything, int64(7), mock.MatchedBy(func(h string) bool {
+					ok, _ := testHasher.Verify("new-password", h)
+					return ok
+				})).Return(nil)
+				mockAuth.On("LogoutAll", mock.Anything, int64(7)).Return(nil)
+			}
+
+			err := svc.ChangePassword(context.Background(), 7, tc.currentPassword, "new-password")
+
+			assert.ErrorIs(t, err, tc.expectErr)
+			mockUserRepo.AssertExpectations(t)
+			mockAuth.AssertExpectations(t)
+		})
+	}
+}
+
+// Письмо содержит токен, а в базу попадает только его хеш
+func TestPasswordService_RequestReset(t *testing.T) {
+	mockUserRepo := new(postgres.MockUserRepository)
+	mockResetRepo := new(postgres.MockPasswordResetRepository)
+	mail := &recordingMailer{}
+	svc := newTestPasswordService(mockUserRepo, mockResetRepo, new(MockAuthService), mail)
+
+	mockUserRepo.On("GetUserByEmail", mock.Anything, "seller@example.com").
+		Return(&models.User{ID: 7, Username: "seller", Email: "seller@example.com"}, nil)
+	var stored *models.PasswordResetToken
+	mockResetRepo.On("CreateResetToken", mock.Anything, mock.AnythingOfType("*models.PasswordResetToken")).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*models.PasswordResetToken) }).
+		Return(int64(1), nil)
+
+	err := svc.RequestReset(context.Background(), "seller@example.com")
+
+	assert.NoError(t, err)
+	if assert.Len(t, mail.sent, 1) {
+		assert.Equal(t, "seller@example.com", mail.sent[0].To)
+		token := regexp.MustCompile(`(?m)^[A-Za-z0-9_-]{43}$`).FindString(mail.sent[0].Body)
+		assert.Equal(t, auth.HashOpaqueToken(token), stored.TokenHash)
+	}
+	assert.WithinDuration(t, time.Now().Add(time.Hour), stored.ExpiresAt, time.Minute)
+}
+
+// Для незарегистрированного адреса письмо не отправляется, но и ошибки нет
+func TestPasswordService_RequestReset_UnknownEmail(t *testing.T) {
+	mockUserRepo := new(postgres.MockUserRepository)
+	mail := &recordingMailer{}
+	svc := newTestPasswordService(mockUserRepo, new(postgres.MockPasswordResetRepository), new(MockAuthService), mail)
+
+	mockUserRepo.On("GetUserByEmail", mock.Anything, "nobody@example.com").Return(nil, postgres.ErrUserNotFound)
+
+	err := svc.RequestReset(context.Background(), "nobody@example.com")
+
+	assert.NoError(t, err)
+	assert.Empty(t, mail.sent)
+}
+
+func TestPasswordService_ConfirmReset(t *testing.T) {
+	testCases := []struct {
+		name      string
+		lookupErr error
+		repoErr   error
+		expectErr error
+	}{
+		{name: "Успешный сброс"},
+		{name: "Токен не найден", lookupErr: postgres.ErrResetTokenNotFound, expectErr: ErrInvalidResetToken},
+		{name: "Токен использован или истек", repoErr: postgres.ErrResetTokenNotFound, expectErr: ErrInvalidResetToken},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUserRepo := new(postgres.MockUserRepository)
+			mockResetRepo := new(postgres.MockPasswordResetRepository)
+			mockAuth := new(MockAuthService)
+			svc := newTestPasswordService(mockUserRepo, mockResetRepo, mockAuth, &recordingMailer{})
+
+			mockResetRepo.On("GetResetTokenUserID", mock.Anything, auth.HashOpaqueToken("token")).Return(int64(7), tc.lookupErr)
+			mockUserRepo.On("GetUserByID", mock.Anything, int64(7)).Return(&models.User{ID: 7, Username: "seller"}, nil).Maybe()
+			if tc.lookupErr == nil {
+				mockResetRepo.On("ResetPassword", mock.Anything, auth.HashOpaqueToken("token"), mock.AnythingOfType("string")).
+					Return(int64(7), tc.repoErr)
+			}
+			if tc.lookupErr == nil && tc.repoErr == nil {
+				mockAuth.On("LogoutAll", mock.Anything, int64(7)).Return(nil)
+			}
+
+			err := svc.ConfirmReset(context.Background(), "token", "new-password")
+
+			assert.ErrorIs(t, err, tc.expectErr)
+			mockAuth.AssertExpectations(t)
+		})
+	}
+}
+
+// Пароль, нарушающий политику, отклоняется со списком причин при смене и при
+// сбросе, и новый хеш при этом не сохраняется.
+func TestPasswordService_PasswordPolicy(t *testing.T) {
+	// Диапазон с хешем "correcthorse1" в формате SUFFIX:COUNT.
+	breachedDir := t.TempDir()
+	sum := sha1.Sum([]byte("correcthorse1"))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	assert.NoError(t, os.WriteFile(filepath.Join(breachedDir, digest[:5]+".txt"),
+		[]byte("0000000000000000000000000000000000A:3\r\n"+digest[5:]+":42\r\n"), 0o600))
+
+	policy := &passwordpolicy.Policy{
+		MinLength:      10,
+		RequireDigit:   true,
+		ForbidUsername: true,
+		Breached:       passwordpolicy.NewRangeDir(breachedDir),
+	}
+	currentHash, _ := testHasher.Hash("old-password")
+
+	testCases := []struct {
+		name        string
+		newPassword string
+		expectCodes []string
+	}{
+		{name: "Подходящий пароль", newPassword: "violet-staple-42"},
+		{name: "Короткий и без цифр", newPassword: "short", expectCodes: []string{passwordpolicy.CodeTooShort, passwordpolicy.CodeMissingDigit}},
+		{name: "Содержит имя пользователя", newPassword: "my-Seller-2026", expectCodes: []string{passwordpolicy.CodeContainsUsername}},
+		{name: "Есть в утечках", newPassword: "correcthorse1", expectCodes: []string{passwordpolicy.CodeBreached}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, flow := range []string{"change", "reset"} {
+				mockUserRepo := new(postgres.MockUserRepository)
+				mockResetRepo := new(postgres.MockPasswordResetRepository)
+				mockAuth := new(MockAuthService)
+				svc := NewPasswordService(mockUserRepo, mockResetRepo, mockAuth, testHasher, policy, &recordingMailer{}, time.Hour, slog.New(slog.DiscardHandler))
+
+				mockUserRepo.On("GetUserByID", mock.Anything, int64(7)).Return(&models.User{ID: 7, Username: "seller", Password: currentHash}, nil)
+				mockResetRepo.On("GetResetTokenUserID", mock.Anything, auth.HashOpaqueToken("token")).Return(int64(7), nil)
+				if tc.expectCodes == nil {
+					mockUserRepo.On("UpdatePassword", mock.Anything, int64(7), mock.AnythingOfType("string")).Return(nil)
+					mockResetRepo.On("ResetPassword", mock.Anything, auth.HashOpaqueToken("token"), mock.AnythingOfType("string")).Return(int64(7), nil)
+					mockAuth.On("LogoutAll", mock.Anything, int64(7)).Return(nil)
+				}
+
+				var err error
+				if flow == "change" {
+					err = svc.ChangePassword(context.Background(), 7, "old-password", tc.newPassword)
+				} else {
+					err = svc.ConfirmReset(context.Background(), "token", tc.newPassword)
+				}
+
+				if tc.expectCodes == nil {
+					assert.NoError(t, err, flow)
+					continue
+				}
+				var weak *WeakPasswordError
+				if assert.ErrorAs(t, err, &weak, flow) {
+					codes := make([]string, 0, len(weak.Violations))
+					for _, v := range weak.Violations {
+						codes = append(codes, v.Code)
+					}
+					assert.Equal(t, tc.expectCodes, codes, flow)
+				}
+				mockUserRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+				mockResetRepo.AssertNotCalled(t, "ResetPassword", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
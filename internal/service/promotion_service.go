@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"time"
+)
+
+var (
+	ErrInvalidPromotionPeriod = errors.New("promotion must end after it starts and in the future")
+)
+
+type promotionService struct {
+	promotionRepo postgres.PromotionRepository
+	adRepo        postgres.AdRepository
+}
+
+func NewPromotionService(promotionRepo postgres.PromotionRepository, adRepo postgres.AdRepository) PromotionService {
+	return &promotionService{
+		promotionRepo: promotionRepo,
+		adRepo:        adRepo,
+	}
+}
+
+func (s *promotionService) Grant(ctx context.Context, adID, adminID int64, startsAt, endsAt time.Time) (*models.Promotion, error) {
+	const op = "service.GrantPromotion"
+
+	if !endsAt.After(startsAt) || !endsAt.After(time.Now()) {
+		return nil, ErrInvalidPromotionPeriod
+	}
+
+	if _, err := s.adRepo.GetAdByID(ctx, adID); err != nil {
+		return nil, err
+	}
+
+	promotion := &models.Promotion{
+		AdID:      adID,
+		GrantedBy: adminID,
+		StartsAt:  startsAt,
+		EndsAt:    endsAt,
+	}
+	id, err := s.promotionRepo.CreatePromotion(ctx, promotion)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	promotion.ID = id
+
+	return promotion, nil
+}
+
+func (s *promotionService) Revoke(ctx context.Context, id int64) error {
+	return s.promotionRepo.RevokePromotion(ctx, id)
+}
+
+func (s *promotionService) GetByAdID(ctx context.Context, adID int64) ([]models.Promotion, error) {
+	promotions, err := s.promotionRepo.GetPromotionsByAdID(ctx, adID)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetPromotionsByAdID: %w", err)
+	}
+	return promotions, nil
+}
@@ -2,32 +2,175 @@ package service
 
 import (
 	"context"
+	"log/slog"
 	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
 	"marketplace/internal/repository/postgres"
 	"marketplace/pkg/auth"
+	"marketplace/pkg/hash"
+	"marketplace/pkg/mailer"
+	"marketplace/pkg/oidc"
+	"marketplace/pkg/passwordpolicy"
+	"marketplace/pkg/screening"
+	"time"
 )
 
 type AdService interface {
 	CreateAd(ctx context.Context, ad *models.Ad) (int64, error)
 	GetAllAds(ctx context.Context, params postgres.GetAllAdsParams) ([]models.Ad, error)
-	GetAdByID(ctx context.Context, id int64) (*models.Ad, error)
-	UpdateAd(ctx context.Context, id, userID int64, req models.UpdateAdRequest) (*models.Ad, error)
+	GetAdByID(ctx context.Context, id int64, actor models.Actor) (*models.Ad, error)
+	UpdateAd(ctx context.Context, id int64, actor models.Actor, req models.UpdateAdRequest) (*models.Ad, error)
+	PatchAd(ctx context.Context, id int64, actor models.Actor, patch models.AdPatch) (*models.Ad, error)
 	DeleteAd(ctx context.Context, id, userID int64) error
+	HideAd(ctx context.Context, id int64, actor models.Actor) error
+	PublishScheduled(ctx context.Context) (int, error)
+	Suggest(ctx context.Context, prefix string, limit int) (*models.SuggestResponse, error)
 }
 
 type AuthService interface {
-	Register(ctx context.Context, username, password string) (*models.User, error)
-	Login(ctx context.Context, username, password string) (string, error)
+	Register(ctx context.Context, username, password, email string) (*models.User, error)
+	Login(ctx context.Context, username, password, clientIP string) (*models.LoginResult, error)
+	LoginTwoFactor(ctx context.Context, challenge, code string) (*models.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error)
+	Logout(ctx context.Context, claims *auth.Claims, refreshToken string) error
+	LogoutAll(ctx context.Context, userID int64) error
+	CheckToken(ctx context.Context, claims *auth.Claims) error
+	BeginExternalLogin(ctx context.Context, provider string, linkUserID int64) (string, error)
+	CompleteExternalLogin(ctx context.Context, provider, state, code string) (*models.ExternalLoginResult, error)
+	ExternalIdentities(ctx context.Context, userID int64) ([]models.ExternalIdentity, error)
+}
+
+// SessionService показывает и завершает сессии пользователя на разных устройствах.
+type SessionService interface {
+	List(ctx context.Context, userID int64, currentID string) ([]models.Session, error)
+	Revoke(ctx context.Context, userID int64, id string) error
+}
+
+// AccountService выгружает и удаляет данные пользователя по его запросу.
+type AccountService interface {
+	Export(ctx context.Context, userID int64) (*models.AccountExport, error)
+	Delete(ctx context.Context, userID int64, sessionID, password string) error
+}
+
+type TwoFactorService interface {
+	Enroll(ctx context.Context, userID int64) (*models.TOTPEnrollment, error)
+	Confirm(ctx context.Context, userID int64, code string) ([]string, error)
+	Verify(ctx context.Context, userID int64, code string) error
+}
+
+// APIKeyService управляет API-ключами машинных клиентов.
+type APIKeyService interface {
+	Create(ctx context.Context, userID int64, name string, scopes []string) (*models.APIKey, string, error)
+	List(ctx context.Context, userID int64) ([]models.APIKey, error)
+	Revoke(ctx context.Context, userID, id int64) error
+	Authenticate(ctx context.Context, rawKey string) (*models.APIKeyPrincipal, error)
+}
+
+type PasswordService interface {
+	ChangePassword(ctx context.Context, userID int64, currentPassword, newPassword string) error
+	RequestReset(ctx context.Context, email string) error
+	ConfirmReset(ctx context.Context, token, newPassword string) error
+}
+
+type VerificationService interface {
+	SendVerification(ctx context.Context, user *models.User) error
+	Resend(ctx context.Context, userID int64) error
+	Verify(ctx context.Context, token string) (string, error)
+	RequireVerified(ctx context.Context, userID int64) error
+}
+
+type UserService interface {
+	GetPublicProfile(ctx context.Context, id int64) (*models.PublicProfile, error)
+	GetUserAds(ctx context.Context, id int64, params postgres.GetAllAdsParams) ([]models.Ad, error)
+	GetProfile(ctx context.Context, id int64) (*models.User, error)
+	UpdateProfile(ctx context.Context, id int64, req models.UpdateProfileRequest) (*models.User, error)
+}
+
+type TagService interface {
+	GetPopularTags(ctx context.Context, limit int) ([]models.TagCount, error)
+}
+
+type PromotionService interface {
+	Grant(ctx context.Context, adID, adminID int64, startsAt, endsAt time.Time) (*models.Promotion, error)
+	Revoke(ctx context.Context, id int64) error
+	GetByAdID(ctx context.Context, adID int64) ([]models.Promotion, error)
+}
+
+type ModerationService interface {
+	ReportAd(ctx context.Context, adID, reporterID int64, reason, comment string) (int64, error)
+	GetReports(ctx context.Context, status string, limit, offset int) ([]models.Report, error)
+	ClaimReport(ctx context.Context, id, moderatorID int64) error
+	ResolveReport(ctx context.Context, id, moderatorID int64, decision string) error
+	GetDuplicateClusters(ctx context.Context, minUsers, limit, offset int) ([]models.DuplicateCluster, error)
+}
+
+type NotificationService interface {
+	GetNotifications(ctx context.Context, userID int64) ([]models.Notification, error)
 }
 
 type Service struct {
-	Auth AuthService
-	Ad   AdService
+	Auth         AuthService
+	Password     PasswordService
+	TwoFactor    TwoFactorService
+	Verification VerificationService
+	APIKey       APIKeyService
+	Account      AccountService
+	Session      SessionService
+	User         UserService
+	Ad           AdService
+	Tag          TagService
+	Promotion    PromotionService
+	Moderation   ModerationService
+	Notification NotificationService
+}
+
+// Deps содержит зависимости, необходимые для сборки сервисного слоя.
+type Deps struct {
+	Repos            *postgres.Repository
+	Cache            *cache.Repository
+	TokenManager     *auth.TokenManager
+	Passwords        *hash.Hasher
+	PasswordPolicy   *passwordpolicy.Policy
+	Screener         *screening.Screener
+	Duplicates       DuplicatePolicy
+	Mailer           mailer.Mailer
+	PasswordResetTTL time.Duration
+	Verification     VerificationPolicy
+	TwoFactor        TwoFactorPolicy
+	Login            LoginPolicy
+	OIDCProviders    oidc.Providers
+	OIDCStateTTL     time.Duration
+	Log              *slog.Logger
 }
 
-func NewService(repos *postgres.Repository, tm *auth.TokenManager) *Service {
+func NewService(deps Deps) *Service {
+	twoFactorService := NewTwoFactorService(deps.Repos.User, deps.Repos.TwoFactor, deps.TwoFactor.Issuer)
+	authService := NewAuthService(
+		deps.Repos.User, deps.Repos.RefreshToken, deps.Cache.Token, deps.Cache.Challenge,
+		twoFactorService, deps.TokenManager, deps.Passwords, deps.PasswordPolicy, deps.TwoFactor.ChallengeTTL,
+		NewLoginGuard(deps.Cache.LoginAttempt, deps.Repos.Audit, deps.Login, deps.Log),
+		NewExternalLogin(deps.OIDCProviders, deps.Repos.Identity, deps.Cache.OIDCState, deps.OIDCStateTTL),
+		deps.Log,
+	)
 	return &Service{
-		Auth: NewAuthService(repos.User, tm),
-		Ad:   NewAdService(repos.Ad),
+		Auth:      authService,
+		TwoFactor: twoFactorService,
+		Password: NewPasswordService(
+			deps.Repos.User, deps.Repos.PasswordReset, authService, deps.Passwords, deps.PasswordPolicy, deps.Mailer, deps.PasswordResetTTL, deps.Log,
+		),
+		Verification: NewVerificationService(
+			deps.Repos.User, deps.Repos.Verification, deps.Mailer, deps.Verification, deps.Log,
+		),
+		APIKey:    NewAPIKeyService(deps.Repos.APIKey, deps.Repos.User, deps.Log),
+		Account:   NewAccountService(deps.Repos, deps.Cache.Suggest, authService, deps.Passwords, deps.Log),
+		Session:   NewSessionService(deps.Repos.Session, deps.Cache.Token, deps.TokenManager),
+		User:      NewUserService(deps.Repos.User, deps.Repos.Ad),
+		Ad:        NewAdService(deps.Repos.Ad, deps.Cache.Suggest, deps.Screener, deps.Duplicates, deps.Log),
+		Tag:       NewTagService(deps.Repos.Tag),
+		Promotion: NewPromotionService(deps.Repos.Promotion, deps.Repos.Ad),
+		Moderation: NewModerationService(
+			deps.Repos.Report, deps.Repos.Ad, deps.Repos.Notification, deps.Cache.Suggest, deps.Duplicates, deps.Log,
+		),
+		Notification: NewNotificationService(deps.Repos.Notification),
 	}
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"marketplace/internal/models"
 	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/auth"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -13,19 +15,179 @@ type MockAuthService struct {
 	mock.Mock
 }
 
-func (m *MockAuthService) Register(ctx context.Context, username, password string) (*models.User, error) {
-	args := m.Called(ctx, username, password)
+func (m *MockAuthService) Register(ctx context.Context, username, password, email string) (*models.User, error) {
+	args := m.Called(ctx, username, password, email)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockAuthService) Login(ctx context.Context, username, password string) (string, error) {
-	args := m.Called(ctx, username, password)
+func (m *MockAuthService) Login(ctx context.Context, username, password, clientIP string) (*models.LoginResult, error) {
+	args := m.Called(ctx, username, password, clientIP)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.LoginResult), args.Error(1)
+}
+
+func (m *MockAuthService) LoginTwoFactor(ctx context.Context, challenge, code string) (*models.TokenPair, error) {
+	args := m.Called(ctx, challenge, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TokenPair), args.Error(1)
+}
+
+func (m *MockAuthService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	args := m.Called(ctx, refreshToken)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TokenPair), args.Error(1)
+}
+
+func (m *MockAuthService) Logout(ctx context.Context, claims *auth.Claims, refreshToken string) error {
+	args := m.Called(ctx, claims, refreshToken)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) LogoutAll(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) CheckToken(ctx context.Context, claims *auth.Claims) error {
+	args := m.Called(ctx, claims)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) BeginExternalLogin(ctx context.Context, provider string, linkUserID int64) (string, error) {
+	args := m.Called(ctx, provider, linkUserID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockAuthService) CompleteExternalLogin(ctx context.Context, provider, state, code string) (*models.ExternalLoginResult, error) {
+	args := m.Called(ctx, provider, state, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ExternalLoginResult), args.Error(1)
+}
+
+func (m *MockAuthService) ExternalIdentities(ctx context.Context, userID int64) ([]models.ExternalIdentity, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.ExternalIdentity), args.Error(1)
+}
+
+// MockTwoFactorService является мок-реализацией TwoFactorService.
+type MockTwoFactorService struct {
+	mock.Mock
+}
+
+func (m *MockTwoFactorService) Enroll(ctx context.Context, userID int64) (*models.TOTPEnrollment, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TOTPEnrollment), args.Error(1)
+}
+
+func (m *MockTwoFactorService) Confirm(ctx context.Context, userID int64, code string) ([]string, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockTwoFactorService) Verify(ctx context.Context, userID int64, code string) error {
+	args := m.Called(ctx, userID, code)
+	return args.Error(0)
+}
+
+// MockPasswordService является мок-реализацией PasswordService.
+type MockPasswordService struct {
+	mock.Mock
+}
+
+func (m *MockPasswordService) ChangePassword(ctx context.Context, userID int64, currentPassword, newPassword string) error {
+	args := m.Called(ctx, userID, currentPassword, newPassword)
+	return args.Error(0)
+}
+
+func (m *MockPasswordService) RequestReset(ctx context.Context, email string) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+
+func (m *MockPasswordService) ConfirmReset(ctx context.Context, token, newPassword string) error {
+	args := m.Called(ctx, token, newPassword)
+	return args.Error(0)
+}
+
+// MockVerificationService является мок-реализацией VerificationService.
+type MockVerificationService struct {
+	mock.Mock
+}
+
+func (m *MockVerificationService) SendVerification(ctx context.Context, user *models.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockVerificationService) Resend(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockVerificationService) Verify(ctx context.Context, token string) (string, error) {
+	args := m.Called(ctx, token)
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockVerificationService) RequireVerified(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+// MockAPIKeyService является мок-реализацией APIKeyService.
+type MockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyService) Create(ctx context.Context, userID int64, name string, scopes []string) (*models.APIKey, string, error) {
+	args := m.Called(ctx, userID, name, scopes)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).(*models.APIKey), args.String(1), args.Error(2)
+}
+
+func (m *MockAPIKeyService) List(ctx context.Context, userID int64) ([]models.APIKey, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) Revoke(ctx context.Context, userID, id int64) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyService) Authenticate(ctx context.Context, rawKey string) (*models.APIKeyPrincipal, error) {
+	args := m.Called(ctx, rawKey)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIKeyPrincipal), args.Error(1)
+}
+
 // MockAdService является мок-реализацией AdService.
 type MockAdService struct {
 	mock.Mock
@@ -44,23 +206,210 @@ func (m *MockAdService) GetAllAds(ctx context.Context, params postgres.GetAllAds
 	return args.Get(0).([]models.Ad), args.Error(1)
 }
 
-func (m *MockAdService) GetAdByID(ctx context.Context, id int64) (*models.Ad, error) {
-	args := m.Called(ctx, id)
+func (m *MockAdService) GetAdByID(ctx context.Context, id int64, actor models.Actor) (*models.Ad, error) {
+	args := m.Called(ctx, id, actor)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Ad), args.Error(1)
+}
+
+func (m *MockAdService) UpdateAd(ctx context.Context, id int64, actor models.Actor, req models.UpdateAdRequest) (*models.Ad, error) {
+	args := m.Called(ctx, id, actor, req)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.Ad), args.Error(1)
 }
 
-func (m *MockAdService) UpdateAd(ctx context.Context, id, userID int64, req models.UpdateAdRequest) (*models.Ad, error) {
-	args := m.Called(ctx, id, userID, req)
+func (m *MockAdService) PatchAd(ctx context.Context, id int64, actor models.Actor, patch models.AdPatch) (*models.Ad, error) {
+	args := m.Called(ctx, id, actor, patch)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.Ad), args.Error(1)
 }
 
+func (m *MockAdService) PublishScheduled(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
 func (m *MockAdService) DeleteAd(ctx context.Context, id, userID int64) error {
 	args := m.Called(ctx, id, userID)
 	return args.Error(0)
 }
+
+func (m *MockAdService) HideAd(ctx context.Context, id int64, actor models.Actor) error {
+	args := m.Called(ctx, id, actor)
+	return args.Error(0)
+}
+
+func (m *MockAdService) Suggest(ctx context.Context, prefix string, limit int) (*models.SuggestResponse, error) {
+	args := m.Called(ctx, prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.SuggestResponse), args.Error(1)
+}
+
+// MockTagService является мок-реализацией TagService.
+type MockTagService struct {
+	mock.Mock
+}
+
+func (m *MockTagService) GetPopularTags(ctx context.Context, limit int) ([]models.TagCount, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.TagCount), args.Error(1)
+}
+
+// MockPromotionService является мок-реализацией PromotionService.
+type MockPromotionService struct {
+	mock.Mock
+}
+
+func (m *MockPromotionService) Grant(ctx context.Context, adID, adminID int64, startsAt, endsAt time.Time) (*models.Promotion, error) {
+	args := m.Called(ctx, adID, adminID, startsAt, endsAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Promotion), args.Error(1)
+}
+
+func (m *MockPromotionService) Revoke(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockPromotionService) GetByAdID(ctx context.Context, adID int64) ([]models.Promotion, error) {
+	args := m.Called(ctx, adID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Promotion), args.Error(1)
+}
+
+// MockSessionService является мок-реализацией SessionService.
+type MockSessionService struct {
+	mock.Mock
+}
+
+func (m *MockSessionService) List(ctx context.Context, userID int64, currentID string) ([]models.Session, error) {
+	args := m.Called(ctx, userID, currentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Session), args.Error(1)
+}
+
+func (m *MockSessionService) Revoke(ctx context.Context, userID int64, id string) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+// MockAccountService является мок-реализацией AccountService.
+type MockAccountService struct {
+	mock.Mock
+}
+
+func (m *MockAccountService) Export(ctx context.Context, userID int64) (*models.AccountExport, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AccountExport), args.Error(1)
+}
+
+func (m *MockAccountService) Delete(ctx context.Context, userID int64, sessionID, password string) error {
+	args := m.Called(ctx, userID, sessionID, password)
+	return args.Error(0)
+}
+
+// MockUserService является мок-реализацией UserService.
+type MockUserService struct {
+	mock.Mock
+}
+
+func (m *MockUserService) GetPublicProfile(ctx context.Context, id int64) (*models.PublicProfile, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PublicProfile), args.Error(1)
+}
+
+func (m *MockUserService) GetUserAds(ctx context.Context, id int64, params postgres.GetAllAdsParams) ([]models.Ad, error) {
+	args := m.Called(ctx, id, params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Ad), args.Error(1)
+}
+
+func (m *MockUserService) GetProfile(ctx context.Context, id int64) (*models.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserService) UpdateProfile(ctx context.Context, id int64, req models.UpdateProfileRequest) (*models.User, error) {
+	args := m.Called(ctx, id, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+// MockModerationService является мок-реализацией ModerationService.
+type MockModerationService struct {
+	mock.Mock
+}
+
+func (m *MockModerationService) ReportAd(ctx context.Context, adID, reporterID int64, reason, comment string) (int64, error) {
+	args := m.Called(ctx, adID, reporterID, reason, comment)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockModerationService) GetReports(ctx context.Context, status string, limit, offset int) ([]models.Report, error) {
+	args := m.Called(ctx, status, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Report), args.Error(1)
+}
+
+func (m *MockModerationService) ClaimReport(ctx context.Context, id, moderatorID int64) error {
+	args := m.Called(ctx, id, moderatorID)
+	return args.Error(0)
+}
+
+func (m *MockModerationService) ResolveReport(ctx context.Context, id, moderatorID int64, decision string) error {
+	args := m.Called(ctx, id, moderatorID, decision)
+	return args.Error(0)
+}
+
+// MockNotificationService является мок-реализацией NotificationService.
+type MockNotificationService struct {
+	mock.Mock
+}
+
+func (m *MockNotificationService) GetNotifications(ctx context.Context, userID int64) ([]models.Notification, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Notification), args.Error(1)
+}
+
+func (m *MockModerationService) GetDuplicateClusters(ctx context.Context, minUsers, limit, offset int) ([]models.DuplicateCluster, error) {
+	args := m.Called(ctx, minUsers, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.DuplicateCluster), args.Error(1)
+}
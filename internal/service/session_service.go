@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
+	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/auth"
+	"unicode/utf8"
+)
+
+// maxUserAgentLength - сколько символов User-Agent сохраняется в сессии.
+const maxUserAgentLength = 512
+
+type clientInfoKey struct{}
+
+// WithClientInfo добавляет в контекст данные клиента, выполняющего запрос.
+// Они сохраняются в открываемой при входе сессии, а IP-адрес служит ключом
+// для учета поисковых запросов.
+func WithClientInfo(ctx context.Context, info models.ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+func clientInfoFromContext(ctx context.Context) models.ClientInfo {
+	info, _ := ctx.Value(clientInfoKey{}).(models.ClientInfo)
+	if utf8.RuneCountInString(info.UserAgent) > maxUserAgentLength {
+		info.UserAgent = string([]rune(info.UserAgent)[:maxUserAgentLength])
+	}
+	return info
+}
+
+type sessionService struct {
+	sessionRepo  postgres.SessionRepository
+	tokenRepo    cache.TokenRepository
+	tokenManager *auth.TokenManager
+}
+
+func NewSessionService(
+	sessionRepo postgres.SessionRepository,
+	tokenRepo cache.TokenRepository,
+	tm *auth.TokenManager,
+) SessionService {
+	return &sessionService{
+		sessionRepo:  sessionRepo,
+		tokenRepo:    tokenRepo,
+		tokenManager: tm,
+	}
+}
+
+// List возвращает действующие сессии пользователя и отмечает среди них текущую.
+func (s *sessionService) List(ctx context.Context, userID int64, currentID string) ([]models.Session, error) {
+	sessions, err := s.sessionRepo.GetSessionsByUserID(ctx, userID, true)
+	if err != nil {
+		return nil, fmt.Errorf("service.ListSessions: %w", err)
+	}
+	for i := range sessions {
+		sessions[i].Current = currentID != "" && sessions[i].ID == currentID
+	}
+	return sessions, nil
+}
+
+// Revoke завершает сессию пользователя: ее refresh-токены отзываются, а
+// выданные в ней access-токены перестают приниматься сразу, не дожидаясь истечения.
+func (s *sessionService) Revoke(ctx context.Context, userID int64, id string) error {
+	const op = "service.RevokeSession"
+
+	if err := s.sessionRepo.RevokeSession(ctx, userID, id); err != nil {
+		if errors.Is(err, postgres.ErrSessionNotFound) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.tokenRepo.DenySession(ctx, id, s.tokenManager.AccessTokenTTL()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"marketplace/internal/config"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/cache"
+	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/auth"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSessionService_List(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Minute})
+	mockSessionRepo := new(postgres.MockSessionRepository)
+	sessionService := NewSessionService(mockSessionRepo, new(cache.MockTokenRepository), tm)
+
+	mockSessionRepo.On("GetSessionsByUserID", mock.Anything, int64(7), true).
+		Return([]models.Session{{ID: "phone"}, {ID: "laptop"}}, nil)
+
+	sessions, err := sessionService.List(context.Background(), 7, "laptop")
+
+	assert.NoError(t, err)
+	assert.False(t, sessions[0].Current)
+	assert.True(t, sessions[1].Current)
+}
+
+// Завершенная сессия попадает в denylist на время жизни access-токена
+func TestSessionService_Revoke(t *testing.T) {
+	tm, _ := auth.NewTokenManager(config.Auth{JWTSecret: "secret", AccessTokenTTL: time.Minute})
+
+	t.Run("Завершение сессии", func(t *testing.T) {
+		mockSessionRepo := new(postgres.MockSessionRepository)
+		mockTokenRepo := new(cache.MockTokenRepository)
+		sessionService := NewSessionService(mockSessionRepo, mockTokenRepo, tm)
+
+		mockSessionRepo.On("RevokeSession", mock.Anything, int64(7), "phone").Return(nil)
+		mockTokenRepo.On("DenySession", mock.Anything, "phone", time.Minute).Return(nil)
+
+		assert.NoError(t, sessionService.Revoke(context.Background(), 7, "phone"))
+		mockTokenRepo.AssertExpectations(t)
+	})
+
+	t.Run("Чужая или завершенная сессия", func(t *testing.T) {
+		mockSessionRepo := new(postgres.MockSessionRepository)
+		mockTokenRepo := new(cache.MockTokenRepository)
+		sessionService := NewSessionService(mockSessionRepo, mockTokenRepo, tm)
+
+		mockSessionRepo.On("RevokeSession", mock.Anything, int64(7), "other").Return(postgres.ErrSessionNotFound)
+
+		err := sessionService.Revoke(context.Background(), 7, "other")
+
+		assert.ErrorIs(t, err, postgres.ErrSessionNotFound)
+		mockTokenRepo.AssertNotCalled(t, "DenySession", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+// Длинный User-Agent обрезается до сохранения в сессии
+func TestClientInfoFromContext(t *testing.T) {
+	long := strings.Repeat("я", maxUserAgentLength+10)
+	info := clientInfoFromContext(WithClientInfo(context.Background(), models.ClientInfo{IP: "192.0.2.1", UserAgent: long}))
+
+	assert.Equal(t, "192.0.2.1", info.IP)
+	assert.Equal(t, strings.Repeat("я", maxUserAgentLength), info.UserAgent)
+	assert.Equal(t, models.ClientInfo{}, clientInfoFromContext(context.Background()))
+}
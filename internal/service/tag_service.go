@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+)
+
+type tagService struct {
+	tagRepo postgres.TagRepository
+}
+
+func NewTagService(tagRepo postgres.TagRepository) TagService {
+	return &tagService{tagRepo: tagRepo}
+}
+
+func (s *tagService) GetPopularTags(ctx context.Context, limit int) ([]models.TagCount, error) {
+	tags, err := s.tagRepo.GetPopularTags(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetPopularTags: %w", err)
+	}
+	return tags, nil
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/auth"
+	"time"
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled    = errors.New("two-factor enrollment has not been started")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+)
+
+// TwoFactorPolicy задает название сервиса в приложении-аутентификаторе и
+// время, за которое нужно ввести код после пароля.
+type TwoFactorPolicy struct {
+	Issuer       string
+	ChallengeTTL time.Duration
+}
+
+// recoveryCodesCount - сколько резервных кодов выдается при включении 2FA.
+const recoveryCodesCount = 10
+
+type twoFactorService struct {
+	userRepo      postgres.UserRepository
+	twoFactorRepo postgres.TwoFactorRepository
+	issuer        string
+}
+
+func NewTwoFactorService(userRepo postgres.UserRepository, twoFactorRepo postgres.TwoFactorRepository, issuer string) TwoFactorService {
+	return &twoFactorService{
+		userRepo:      userRepo,
+		twoFactorRepo: twoFactorRepo,
+		issuer:        issuer,
+	}
+}
+
+// Enroll создает новый секрет TOTP. Двухфакторная аутентификация включится
+// только после подтверждения кодом из приложения (Confirm).
+func (s *twoFactorService) Enroll(ctx context.Context, userID int64) (*models.TOTPEnrollment, error) {
+	const op = "service.Enroll"
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	key, err := auth.GenerateTOTPKey(s.issuer, user.Username)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	qrCode, err := auth.TOTPQRCode(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.twoFactorRepo.SetTOTPSecret(ctx, userID, key.Secret()); err != nil {
+		if errors.Is(err, postgres.ErrTOTPAlreadyEnabled) {
+			return nil, ErrTwoFactorAlreadyEnabled
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &models.TOTPEnrollment{
+		Secret: key.Secret(),
+		URI:    key.URL(),
+		QRCode: qrCode,
+	}, nil
+}
+
+// Confirm включает двухфакторную аутентификацию после проверки первого кода
+// и возвращает резервные коды. Коды показываются один раз, в базе хранятся их хеши.
+func (s *twoFactorService) Confirm(ctx context.Context, userID int64, code string) ([]string, error) {
+	const op = "service.Confirm"
+
+	settings, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if settings.EnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if settings.Secret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	step, ok := auth.ValidateTOTP(code, settings.Secret)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = auth.HashRecoveryCode(c)
+	}
+
+	if err := s.twoFactorRepo.EnableTOTP(ctx, userID, hashes, step); err != nil {
+		if errors.Is(err, postgres.ErrTOTPAlreadyEnabled) {
+			return nil, ErrTwoFactorAlreadyEnabled
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return codes, nil
+}
+
+// Verify проверяет второй фактор входа: код из приложения или одноразовый резервный код.
+// Код из приложения принимается один раз: код того же или более раннего
+// временного шага, чем уже принятый, отклоняется.
+func (s *twoFactorService) Verify(ctx context.Context, userID int64, code string) error {
+	const op = "service.Verify"
+
+	settings, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if settings.EnabledAt == nil {
+		return ErrInvalidTwoFactorCode
+	}
+
+	if auth.IsTOTPCode(code) {
+		step, ok := auth.ValidateTOTP(code, settings.Secret)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		if err := s.twoFactorRepo.AcceptTOTPStep(ctx, userID, step); err != nil {
+			if errors.Is(err, postgres.ErrTOTPCodeReused) {
+				return ErrInvalidTwoFactorCode
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	}
+
+	if err := s.twoFactorRepo.UseRecoveryCode(ctx, userID, auth.HashRecoveryCode(code)); err != nil {
+		if errors.Is(err, postgres.ErrRecoveryCodeNotFound) {
+			return ErrInvalidTwoFactorCode
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/auth"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Подключение возвращает секрет и QR-код, а сохраняется секрет до подтверждения
+func TestTwoFactorService_Enroll(t *testing.T) {
+	mockUserRepo := new(postgres.MockUserRepository)
+	mockTwoFactorRepo := new(postgres.MockTwoFactorRepository)
+	svc := NewTwoFactorService(mockUserRepo, mockTwoFactorRepo, "Marketplace")
+
+	mockUserRepo.On("GetUserByID", mock.Anything, int64(7)).Return(&models.User{ID: 7, Username: "seller"}, nil)
+	mockTwoFactorRepo.On("SetTOTPSecret", mock.Anything, int64(7), mock.AnythingOfType("string")).Return(nil)
+
+	enrollment, err := svc.Enroll(context.Background(), 7)
+
+	assert.NoError(t, err)
+	assert.Contains(t, enrollment.URI, "otpauth://totp/Marketplace:seller")
+	assert.Equal(t, []byte("\x89PNG"), enrollment.QRCode[:4])
+	mockTwoFactorRepo.AssertCalled(t, "SetTOTPSecret", mock.Anything, int64(7), enrollment.Secret)
+}
+
+func TestTwoFactorService_Confirm(t *testing.T) {
+	key, _ := auth.GenerateTOTPKey("Marketplace", "seller")
+	now := time.Now()
+	validCode, _ := totp.GenerateCode(key.Secret(), now)
+
+	testCases := []struct {
+		name      string
+		code      string
+		settings  *models.TOTPSettings
+		expectErr error
+	}{
+		{name: "Верный код", code: validCode, settings: &models.TOTPSettings{UserID: 7, Secret: key.Secret()}},
+		{name: "Неверный код", code: "000000", settings: &models.TOTPSettings{UserID: 7, Secret: key.Secret()}, expectErr: ErrInvalidTwoFactorCode},
+		{name: "Подключение не начато", code: validCode, settings: &models.TOTPSettings{UserID: 7}, expectErr: ErrTwoFactorNotEnrolled},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockTwoFactorRepo := new(postgres.MockTwoFactorRepository)
+			svc := NewTwoFactorService(new(postgres.MockUserRepository), mockTwoFactorRepo, "Marketplace")
+
+			mockTwoFactorRepo.On("GetTOTP", mock.Anything, int64(7)).Return(tc.settings, nil)
+			var storedHashes []string
+			// Шаг кода подтверждения считается использованным
+			mockTwoFactorRepo.On("EnableTOTP", mock.Anything, int64(7), mock.Anything, now.Unix()/30).
+				Run(func(args mock.Arguments) { storedHashes = args.Get(2).([]string) }).
+				Return(nil).Maybe()
+
+			codes, err := svc.Confirm(context.Background(), 7, tc.code)
+
+			assert.ErrorIs(t, err, tc.expectErr)
+			if tc.expectErr == nil {
+				assert.Len(t, codes, recoveryCodesCount)
+				assert.Equal(t, auth.HashRecoveryCode(codes[0]), storedHashes[0])
+			} else {
+				mockTwoFactorRepo.AssertNotCalled(t, "EnableTOTP", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+// Резервный код принимается без учета регистра и дефисов
+func TestTwoFactorService_Verify_RecoveryCode(t *testing.T) {
+	mockTwoFactorRepo := new(postgres.MockTwoFactorRepository)
+	svc := NewTwoFactorService(new(postgres.MockUserRepository), mockTwoFactorRepo, "Marketplace")
+
+	enabledAt := time.Now()
+	mockTwoFactorRepo.On("GetTOTP", mock.Anything, int64(7)).
+		Return(&models.TOTPSettings{UserID: 7, Secret: "SECRET", EnabledAt: &enabledAt}, nil)
+	mockTwoFactorRepo.On("UseRecoveryCode", mock.Anything, int64(7), auth.HashRecoveryCode("abcd-efgh-ijkl-mnop")).Return(nil)
+	mockTwoFactorRepo.On("UseRecoveryCode", mock.Anything, int64(7), mock.Anything).Return(postgres.ErrRecoveryCodeNotFound)
+
+	assert.NoError(t, svc.Verify(context.Background(), 7, "ABCDEFGHIJKLMNOP"))
+	assert.ErrorIs(t, svc.Verify(context.Background(), 7, "zzzz-zzzz-zzzz-zzzz"), ErrInvalidTwoFactorCode)
+}
+
+// Код из приложения нельзя предъявить повторно: шаг принимается только один раз
+func TestTwoFactorService_Verify_TOTPReplay(t *testing.T) {
+	key, _ := auth.GenerateTOTPKey("Marketplace", "seller")
+	now := time.Now()
+	code, _ := totp.GenerateCode(key.Secret(), now)
+	step := now.Unix() / 30
+
+	mockTwoFactorRepo := new(postgres.MockTwoFactorRepository)
+	svc := NewTwoFactorService(new(postgres.MockUserRepository), mockTwoFactorRepo, "Marketplace")
+
+	enabledAt := time.Now()
+	mockTwoFactorRepo.On("GetTOTP", mock.Anything, int64(7)).
+		Return(&models.TOTPSettings{UserID: 7, Secret: key.Secret(), EnabledAt: &enabledAt}, nil)
+	mockTwoFactorRepo.On("AcceptTOTPStep", mock.Anything, int64(7), step).Return(nil).Once()
+	mockTwoFactorRepo.On("AcceptTOTPStep", mock.Anything, int64(7), step).Return(postgres.ErrTOTPCodeReused).Once()
+
+	assert.NoError(t, svc.Verify(context.Background(), 7, code))
+	assert.ErrorIs(t, svc.Verify(context.Background(), 7, code), ErrInvalidTwoFactorCode)
+
+	// Неверный код не отмечает шаг
+	assert.ErrorIs(t, svc.Verify(context.Background(), 7, "000000"), ErrInvalidTwoFactorCode)
+	mockTwoFactorRepo.AssertNumberOfCalls(t, "AcceptTOTPStep", 2)
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"strings"
+)
+
+type userService struct {
+	userRepo postgres.UserRepository
+	adRepo   postgres.AdRepository
+}
+
+func NewUserService(userRepo postgres.UserRepository, adRepo postgres.AdRepository) UserService {
+	return &userService{
+		userRepo: userRepo,
+		adRepo:   adRepo,
+	}
+}
+
+// GetPublicProfile возвращает публичный профиль продавца с числом его видимых объявлений.
+func (s *userService) GetPublicProfile(ctx context.Context, id int64) (*models.PublicProfile, error) {
+	const op = "service.GetPublicProfile"
+
+	user, err := s.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.adRepo.CountAds(ctx, postgres.GetAllAdsParams{UserID: id})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &models.PublicProfile{
+		ID:            user.ID,
+		Username:      user.Username,
+		DisplayName:   user.DisplayName,
+		AvatarURL:     user.AvatarURL,
+		Bio:           user.Bio,
+		MemberSince:   user.CreatedAt,
+		ActiveAdCount: count,
+	}, nil
+}
+
+// GetUserAds возвращает видимые объявления продавца тем же запросом, что и общий список.
+func (s *userService) GetUserAds(ctx context.Context, id int64, params postgres.GetAllAdsParams) ([]models.Ad, error) {
+	const op = "service.GetUserAds"
+
+	if _, err := s.userRepo.GetUserByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	params.UserID = id
+	params.Tag = normalizeTag(params.Tag)
+	ads, err := s.adRepo.GetAllAds(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return ads, nil
+}
+
+// GetProfile возвращает текущего пользователя со всеми полями профиля.
+func (s *userService) GetProfile(ctx context.Context, id int64) (*models.User, error) {
+	return s.userRepo.GetUserByID(ctx, id)
+}
+
+// UpdateProfile меняет переданные поля профиля, остальные остаются прежними.
+// Имя и описание сохраняются без пробелов по краям.
+func (s *userService) UpdateProfile(ctx context.Context, id int64, req models.UpdateProfileRequest) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*req.DisplayName)
+	}
+	if req.AvatarURL != nil {
+		user.AvatarURL = *req.AvatarURL
+	}
+	if req.Phone != nil {
+		user.Phone = *req.Phone
+	}
+	if req.Bio != nil {
+		user.Bio = strings.TrimSpace(*req.Bio)
+	}
+	if req.Language != nil {
+		user.Language = *req.Language
+	}
+
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Профиль собирается из пользователя и числа его видимых объявлений
+func TestUserService_GetPublicProfile(t *testing.T) {
+	mockUserRepo := new(postgres.MockUserRepository)
+	mockAdRepo := new(postgres.MockAdRepository)
+	userService := NewUserService(mockUserRepo, mockAdRepo)
+
+	createdAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	mockUserRepo.On("GetUserByID", mock.Anything, int64(7)).
+		Return(&models.User{
+			ID: 7, Username: "seller", Password: "hash", Role: models.RoleUser, CreatedAt: createdAt,
+			DisplayName: "Иван", Bio: "Продаю мебель", Phone: "+79991234567", Language: "ru",
+		}, nil)
+	mockAdRepo.On("CountAds", mock.Anything, postgres.GetAllAdsParams{UserID: 7}).Return(3, nil)
+
+	profile, err := userService.GetPublicProfile(context.Background(), 7)
+
+	assert.NoError(t, err)
+	// Телефон и язык на публичную страницу не попадают.
+	assert.Equal(t, &models.PublicProfile{
+		ID: 7, Username: "seller", DisplayName: "Иван", Bio: "Продаю мебель", MemberSince: createdAt, ActiveAdCount: 3,
+	}, profile)
+}
+
+// Объявления продавца выбираются общим запросом с фильтром по продавцу
+func TestUserService_GetUserAds(t *testing.T) {
+	mockUserRepo := new(postgres.MockUserRepository)
+	mockAdRepo := new(postgres.MockAdRepository)
+	userService := NewUserService(mockUserRepo, mockAdRepo)
+
+	mockUserRepo.On("GetUserByID", mock.Anything, int64(404)).Return(nil, postgres.ErrUserNotFound)
+	_, err := userService.GetUserAds(context.Background(), 404, postgres.GetAllAdsParams{Limit: 10})
+	assert.ErrorIs(t, err, postgres.ErrUserNotFound)
+
+	mockUserRepo.On("GetUserByID", mock.Anything, int64(7)).Return(&models.User{ID: 7}, nil)
+	params := postgres.GetAllAdsParams{Limit: 10, SortBy: "price", SortOrder: "asc", Tag: "мебель", UserID: 7}
+	mockAdRepo.On("GetAllAds", mock.Anything, params).Return([]models.Ad{{ID: 1, UserID: 7}}, nil)
+
+	ads, err := userService.GetUserAds(context.Background(), 7, postgres.GetAllAdsParams{Limit: 10, SortBy: "price", SortOrder: "asc", Tag: " Мебель "})
+
+	assert.NoError(t, err)
+	assert.Len(t, ads, 1)
+}
+
+// Изменяются только переданные поля, пустая строка очищает поле
+func TestUserService_UpdateProfile(t *testing.T) {
+	mockUserRepo := new(postgres.MockUserRepository)
+	userService := NewUserService(mockUserRepo, new(postgres.MockAdRepository))
+
+	mockUserRepo.On("GetUserByID", mock.Anything, int64(7)).
+		Return(&models.User{ID: 7, Username: "seller", DisplayName: "Иван", Phone: "+79991234567", Language: "ru"}, nil)
+	mockUserRepo.On("UpdateUser", mock.Anything, &models.User{
+		ID: 7, Username: "seller", DisplayName: "Иван Петров", Bio: "Продаю мебель", Language: "ru",
+	}).Return(nil)
+
+	displayName, bio, phone := "  Иван Петров ", "Продаю мебель\n", ""
+	user, err := userService.UpdateProfile(context.Background(), 7, models.UpdateProfileRequest{
+		DisplayName: &displayName, Bio: &bio, Phone: &phone,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Иван Петров", user.DisplayName)
+	assert.Empty(t, user.Phone)
+	assert.Equal(t, "ru", user.Language)
+	mockUserRepo.AssertExpectations(t)
+
+	mockUserRepo.On("GetUserByID", mock.Anything, int64(404)).Return(nil, postgres.ErrUserNotFound)
+	_, err = userService.UpdateProfile(context.Background(), 404, models.UpdateProfileRequest{DisplayName: &displayName})
+	assert.ErrorIs(t, err, postgres.ErrUserNotFound)
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/auth"
+	"marketplace/pkg/mailer"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEmailNotVerified         = errors.New("email is not verified")
+	ErrEmailAlreadyVerified     = errors.New("email is already verified")
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+	ErrNoEmail                  = errors.New("user has no email to verify")
+)
+
+const verifyMailSubject = "Подтверждение почты"
+
+const verifyMailBody = `Здравствуйте, %s!
+
+Чтобы подтвердить адрес электронной почты, перейдите по ссылке:
+
+%s
+
+Ссылка действует %d ч. Если вы не регистрировались на площадке, просто проигнорируйте это письмо.
+`
+
+// VerificationPolicy задает срок действия ссылок подтверждения почты и адрес,
+// от которого они строятся. Required запрещает публиковать объявления до
+// подтверждения.
+type VerificationPolicy struct {
+	Required bool
+	TTL      time.Duration
+	BaseURL  string
+}
+
+// link строит ссылку на GET /auth/verify с токеном.
+func (p VerificationPolicy) link(token string) string {
+	return strings.TrimRight(p.BaseURL, "/") + "/api/v1/auth/verify?token=" + url.QueryEscape(token)
+}
+
+type verificationService struct {
+	userRepo   postgres.UserRepository
+	verifyRepo postgres.EmailVerificationRepository
+	mailer     mailer.Mailer
+	policy     VerificationPolicy
+	log        *slog.Logger
+}
+
+func NewVerificationService(
+	userRepo postgres.UserRepository,
+	verifyRepo postgres.EmailVerificationRepository,
+	mailer mailer.Mailer,
+	policy VerificationPolicy,
+	log *slog.Logger,
+) VerificationService {
+	return &verificationService{
+		userRepo:   userRepo,
+		verifyRepo: verifyRepo,
+		mailer:     mailer,
+		policy:     policy,
+		log:        log,
+	}
+}
+
+// SendVerification выдает новый токен подтверждения и отправляет ссылку на
+// текущую почту пользователя. Ранее отправленные ссылки перестают действовать.
+func (s *verificationService) SendVerification(ctx context.Context, user *models.User) error {
+	const op = "service.SendVerification"
+
+	if user.Email == "" {
+		return ErrNoEmail
+	}
+
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	_, err = s.verifyRepo.CreateVerificationToken(ctx, &models.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: auth.HashOpaqueToken(token),
+		ExpiresAt: time.Now().Add(s.policy.TTL),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: verifyMailSubject,
+		Body:    fmt.Sprintf(verifyMailBody, user.Username, s.policy.link(token), int(s.policy.TTL.Hours())),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Resend повторно отправляет ссылку подтверждения, если почта еще не подтверждена.
+func (s *verificationService) Resend(ctx context.Context, userID int64) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("service.Resend: %w", err)
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+	return s.SendVerification(ctx, user)
+}
+
+// Verify подтверждает почту по токену из письма и возвращает подтвержденный адрес.
+func (s *verificationService) Verify(ctx context.Context, token string) (string, error) {
+	email, err := s.verifyRepo.VerifyEmail(ctx, auth.HashOpaqueToken(token))
+	if err != nil {
+		if errors.Is(err, postgres.ErrVerificationTokenNotFound) {
+			return "", ErrInvalidVerificationToken
+		}
+		return "", fmt.Errorf("service.Verify: %w", err)
+	}
+	return email, nil
+}
+
+// RequireVerified возвращает ErrEmailNotVerified, если политика требует
+// подтвержденной почты, а пользователь ее еще не подтвердил.
+func (s *verificationService) RequireVerified(ctx context.Context, userID int64) error {
+	if !s.policy.Required {
+		return nil
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("service.RequireVerified: %w", err)
+	}
+	if user.EmailVerifiedAt == nil {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
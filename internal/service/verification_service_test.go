@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"marketplace/internal/models"
+	"marketplace/internal/repository/postgres"
+	"marketplace/pkg/auth"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestVerificationService(userRepo *postgres.MockUserRepository, verifyRepo *postgres.MockEmailVerificationRepository, mail *recordingMailer, required bool) VerificationService {
+	policy := VerificationPolicy{Required: required, TTL: 48 * time.Hour, BaseURL: "https://market.example.com/"}
+	return NewVerificationService(userRepo, verifyRepo, mail, policy, slog.New(slog.DiscardHandler))
+}
+
+// В письме приходит ссылка с токеном, а в базу попадает только его хеш и адрес
+func TestVerificationService_SendVerification(t *testing.T) {
+	mockVerifyRepo := new(postgres.MockEmailVerificationRepository)
+	mail := &recordingMailer{}
+	svc := newTestVerificationService(new(postgres.MockUserRepository), mockVerifyRepo, mail, false)
+
+	var stored *models.EmailVerificationToken
+	mockVerifyRepo.On("CreateVerificationToken", mock.Anything, mock.AnythingOfType("*models.EmailVerificationToken")).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*models.EmailVerificationToken) }).
+		Return(int64(1), nil)
+
+	err := svc.SendVerification(context.Background(), &models.User{ID: 7, Username: "seller", Email: "seller@example.com"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "seller@example.com", stored.Email)
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), stored.ExpiresAt, time.Minute)
+	if assert.Len(t, mail.sent, 1) {
+		assert.Equal(t, "seller@example.com", mail.sent[0].To)
+		link := regexp.MustCompile(`https://market\.example\.com/api/v1/auth/verify\?token=\S+`).FindString(mail.sent[0].Body)
+		u, err := url.Parse(link)
+		if assert.NoError(t, err) {
+			assert.Equal(t, auth.HashOpaqueToken(u.Query().Get("token")), stored.TokenHash)
+		}
+	}
+}
+
+func TestVerificationService_Verify(t *testing.T) {
+	testCases := []struct {
+		name      string
+		repoEmail string
+		repoErr   error
+		expectErr error
+	}{
+		{name: "Почта подтверждена", repoEmail: "seller@example.com"},
+		{name: "Токен недействителен", repoErr: postgres.ErrVerificationTokenNotFound, expectErr: ErrInvalidVerificationToken},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockVerifyRepo := new(postgres.MockEmailVerificationRepository)
+			svc := newTestVerificationService(new(postgres.MockUserRepository), mockVerifyRepo, &recordingMailer{}, false)
+
+			mockVerifyRepo.On("VerifyEmail", mock.Anything, auth.HashOpaqueToken("token")).Return(tc.repoEmail, tc.repoErr)
+
+			email, err := svc.Verify(context.Background(), "token")
+
+			assert.ErrorIs(t, err, tc.expectErr)
+			assert.Equal(t, tc.repoEmail, email)
+		})
+	}
+}
+
+func TestVerificationService_RequireVerified(t *testing.T) {
+	verifiedAt := time.Now()
+
+	testCases := []struct {
+		name      string
+		required  bool
+		user      *models.User
+		expectErr error
+	}{
+		{name: "Подтверждение не требуется", required: false},
+		{name: "Почта подтверждена", required: true, user: &models.User{ID: 7, EmailVerifiedAt: &verifiedAt}},
+		{name: "Почта не подтверждена", required: true, user: &models.User{ID: 7}, expectErr: ErrEmailNotVerified},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUserRepo := new(postgres.MockUserRepository)
+			svc := newTestVerificationService(mockUserRepo, new(postgres.MockEmailVerificationRepository), &recordingMailer{}, tc.required)
+			if tc.user != nil {
+				mockUserRepo.On("GetUserByID", mock.Anything, int64(7)).Return(tc.user, nil)
+			}
+
+			err := svc.RequireVerified(context.Background(), 7)
+
+			assert.ErrorIs(t, err, tc.expectErr)
+			mockUserRepo.AssertExpectations(t)
+		})
+	}
+}
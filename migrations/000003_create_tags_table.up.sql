@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS tags (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE CHECK (
+		length(name) >= 1
+		AND length(name) <= 30
+	)
+);
+
+CREATE TABLE IF NOT EXISTS ad_tags (
+	ad_id INTEGER NOT NULL REFERENCES ads(id) ON DELETE CASCADE,
+	tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+	PRIMARY KEY (ad_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ad_tags_tag_id ON ad_tags(tag_id);
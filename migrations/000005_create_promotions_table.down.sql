@@ -0,0 +1 @@
+DROP TABLE IF EXISTS promotions;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS promotions (
+	id SERIAL PRIMARY KEY,
+	ad_id INTEGER NOT NULL REFERENCES ads(id) ON DELETE CASCADE,
+	granted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	starts_at TIMESTAMPTZ NOT NULL,
+	ends_at TIMESTAMPTZ NOT NULL,
+	revoked_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotions_ad_id ON promotions(ad_id);
+CREATE INDEX IF NOT EXISTS idx_promotions_active ON promotions(starts_at, ends_at) WHERE revoked_at IS NULL;
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS ad_reports;
+
+ALTER TABLE ads DROP COLUMN IF EXISTS hidden_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS banned_at;
+
+UPDATE users SET role = 'user' WHERE role = 'moderator';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users
+ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'));
@@ -0,0 +1,26 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users
+ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'moderator', 'admin'));
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned_at TIMESTAMPTZ;
+
+ALTER TABLE ads ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS ad_reports (
+	id SERIAL PRIMARY KEY,
+	ad_id INTEGER NOT NULL REFERENCES ads(id) ON DELETE CASCADE,
+	reporter_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	reason TEXT NOT NULL CHECK (
+		reason IN ('spam', 'scam', 'prohibited', 'offensive', 'duplicate', 'other')
+	),
+	comment TEXT NOT NULL DEFAULT '' CHECK (length(comment) <= 500),
+	status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved')),
+	moderator_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	decision TEXT CHECK (decision IN ('hide', 'dismiss', 'ban_author')),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	claimed_at TIMESTAMPTZ,
+	resolved_at TIMESTAMPTZ,
+	UNIQUE (ad_id, reporter_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ad_reports_status ON ad_reports(status, created_at);
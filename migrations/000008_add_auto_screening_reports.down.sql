@@ -0,0 +1,9 @@
+DELETE FROM ad_reports WHERE reason = 'auto_screening';
+
+ALTER TABLE ad_reports DROP CONSTRAINT IF EXISTS ad_reports_reason_check;
+ALTER TABLE ad_reports
+ADD CONSTRAINT ad_reports_reason_check CHECK (
+	reason IN ('spam', 'scam', 'prohibited', 'offensive', 'duplicate', 'other')
+);
+
+ALTER TABLE ad_reports ALTER COLUMN reporter_id SET NOT NULL;
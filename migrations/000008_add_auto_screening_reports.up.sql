@@ -0,0 +1,7 @@
+ALTER TABLE ad_reports ALTER COLUMN reporter_id DROP NOT NULL;
+
+ALTER TABLE ad_reports DROP CONSTRAINT IF EXISTS ad_reports_reason_check;
+ALTER TABLE ad_reports
+ADD CONSTRAINT ad_reports_reason_check CHECK (
+	reason IN ('spam', 'scam', 'prohibited', 'offensive', 'duplicate', 'other', 'auto_screening')
+);
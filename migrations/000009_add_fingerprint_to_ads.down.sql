@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_ads_fingerprint;
+DROP INDEX IF EXISTS idx_ads_user_fingerprint;
+
+ALTER TABLE ads DROP COLUMN IF EXISTS fingerprint;
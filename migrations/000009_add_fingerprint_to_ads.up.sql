@@ -0,0 +1,4 @@
+ALTER TABLE ads ADD COLUMN fingerprint VARCHAR(64);
+
+CREATE INDEX idx_ads_user_fingerprint ON ads (user_id, fingerprint, created_at);
+CREATE INDEX idx_ads_fingerprint ON ads (fingerprint) WHERE fingerprint IS NOT NULL;
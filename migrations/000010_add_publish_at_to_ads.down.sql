@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_ads_publish_at;
+
+ALTER TABLE ads DROP COLUMN IF EXISTS publish_at;
@@ -0,0 +1,3 @@
+ALTER TABLE ads ADD COLUMN publish_at TIMESTAMPTZ;
+
+CREATE INDEX idx_ads_publish_at ON ads (publish_at) WHERE publish_at IS NOT NULL;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS password_reset_tokens;
+
+DROP INDEX IF EXISTS idx_users_email;
+
+ALTER TABLE users DROP COLUMN IF EXISTS email;
\ No newline at end of file
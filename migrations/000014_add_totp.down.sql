@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS totp_recovery_codes;
+
+ALTER TABLE users DROP COLUMN IF EXISTS totp_enabled_at;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_secret;
\ No newline at end of file
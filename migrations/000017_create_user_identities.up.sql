@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+	id BIGSERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	provider TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	email TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	CONSTRAINT user_identities_provider_subject_key UNIQUE (provider, subject),
+	CONSTRAINT user_identities_user_provider_key UNIQUE (user_id, provider)
+);
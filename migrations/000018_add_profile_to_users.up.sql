@@ -0,0 +1,6 @@
+ALTER TABLE users
+	ADD COLUMN display_name VARCHAR(64),
+	ADD COLUMN avatar_url TEXT,
+	ADD COLUMN phone VARCHAR(16),
+	ADD COLUMN bio VARCHAR(500),
+	ADD COLUMN language VARCHAR(8);
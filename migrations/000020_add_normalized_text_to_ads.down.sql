@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_ads_normalized_text_trgm;
+DROP INDEX IF EXISTS idx_ads_user_created_at;
+
+ALTER TABLE ads DROP COLUMN IF EXISTS normalized_text;
+
+ALTER TABLE ads ADD COLUMN fingerprint VARCHAR(64);
+
+CREATE INDEX idx_ads_user_fingerprint ON ads (user_id, fingerprint, created_at);
+CREATE INDEX idx_ads_fingerprint ON ads (fingerprint) WHERE fingerprint IS NOT NULL;
+
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,14 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+DROP INDEX IF EXISTS idx_ads_fingerprint;
+DROP INDEX IF EXISTS idx_ads_user_fingerprint;
+ALTER TABLE ads DROP COLUMN IF EXISTS fingerprint;
+
+ALTER TABLE ads ADD COLUMN normalized_text TEXT;
+
+-- Для существующих объявлений текст нормализуется приближенно, без замены
+-- гомоглифов; точное значение запишется при следующем изменении объявления.
+UPDATE ads SET normalized_text = btrim(regexp_replace(lower(title || ' ' || description), '[^[:alnum:]]+', ' ', 'g'));
+
+CREATE INDEX idx_ads_user_created_at ON ads (user_id, created_at);
+CREATE INDEX idx_ads_normalized_text_trgm ON ads USING GIN (normalized_text gin_trgm_ops);
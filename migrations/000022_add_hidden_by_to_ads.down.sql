@@ -0,0 +1 @@
+ALTER TABLE ads DROP COLUMN IF EXISTS hidden_by;
@@ -0,0 +1,12 @@
+ALTER TABLE ads ADD COLUMN hidden_by TEXT CHECK (hidden_by IN ('screening', 'moderator'));
+
+-- Скрытые объявления с нерассмотренной системной жалобой ждут премодерации,
+-- остальные скрыты модератором.
+UPDATE ads SET hidden_by = CASE
+	WHEN EXISTS (
+		SELECT 1 FROM ad_reports
+		WHERE ad_reports.ad_id = ads.id AND reason = 'auto_screening' AND status <> 'resolved'
+	) THEN 'screening'
+	ELSE 'moderator'
+END
+WHERE hidden_at IS NOT NULL;
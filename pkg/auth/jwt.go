@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"marketplace/internal/config"
+	"sort"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -13,45 +14,131 @@ var (
 	ErrInvalidToken = errors.New("invalid token")
 )
 
+// TokenManager выдает и проверяет access-токены. Без асимметричных ключей
+// токены подписываются HS256 на общем секрете, иначе - активным ключом,
+// а проверяются любым из еще действующих ключей по заголовку kid.
 type TokenManager struct {
 	signingKey string
+	keys       map[string]*signingKey
+	active     *signingKey
+	issuer     string
+	audience   string
 	ttl        time.Duration
+	refreshTTL time.Duration
 }
 
 func NewTokenManager(cfg config.Auth) (*TokenManager, error) {
-	if cfg.JWTSecret == "" {
-		return nil, errors.New("empty signing key")
+	m := &TokenManager{
+		signingKey: cfg.JWTSecret,
+		issuer:     cfg.Issuer,
+		audience:   cfg.Audience,
+		ttl:        cfg.AccessTokenTTL,
+		refreshTTL: cfg.RefreshTokenTTL,
 	}
-	return &TokenManager{signingKey: cfg.JWTSecret, ttl: cfg.TokenTTL}, nil
+
+	if len(cfg.SigningKeys) == 0 {
+		if cfg.JWTSecret == "" {
+			return nil, errors.New("empty signing key")
+		}
+		return m, nil
+	}
+
+	m.keys = make(map[string]*signingKey, len(cfg.SigningKeys))
+	for _, kc := range cfg.SigningKeys {
+		key, err := loadSigningKey(kc)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := m.keys[key.id]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.id)
+		}
+		m.keys[key.id] = key
+	}
+
+	m.active = m.keys[cfg.ActiveKeyID]
+	if m.active == nil {
+		return nil, fmt.Errorf("active key %q is not configured", cfg.ActiveKeyID)
+	}
+	if m.active.private == nil {
+		return nil, fmt.Errorf("active key %q has no private key", cfg.ActiveKeyID)
+	}
+	if m.active.retiredAt != nil {
+		return nil, fmt.Errorf("active key %q is retired", cfg.ActiveKeyID)
+	}
+	return m, nil
+}
+
+// AccessTokenTTL возвращает время жизни access-токена.
+func (m *TokenManager) AccessTokenTTL() time.Duration {
+	return m.ttl
+}
+
+// RefreshTokenTTL возвращает время жизни refresh-токена.
+func (m *TokenManager) RefreshTokenTTL() time.Duration {
+	return m.refreshTTL
 }
 
 type Claims struct {
 	jwt.RegisteredClaims
 	UserID   int64  `json:"user_id"`
 	Username string `json:"username"`
+	Role     string `json:"role"`
+	// Generation - поколение токенов пользователя на момент выдачи. Токены
+	// старших поколений отзываются выходом на всех устройствах.
+	Generation int64 `json:"gen"`
+	// SessionID - сессия (семейство refresh-токенов), в которой выдан токен.
+	// Токены отозванной сессии перестают приниматься сразу.
+	SessionID string `json:"sid,omitempty"`
 }
 
-func (m *TokenManager) GenerateToken(userID int64, username string) (string, error) {
+// GenerateToken выдает access-токен с уникальным идентификатором (jti),
+// по которому токен можно отозвать до истечения срока.
+func (m *TokenManager) GenerateToken(userID int64, username, role string, generation int64, sessionID string) (string, error) {
+	jti, err := randomString(jtiBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token id: %w", err)
+	}
+
 	claims := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    m.issuer,
 		},
-		UserID:   userID,
-		Username: username,
+		UserID:     userID,
+		Username:   username,
+		Role:       role,
+		Generation: generation,
+		SessionID:  sessionID,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(m.signingKey))
+	if m.audience != "" {
+		claims.Audience = jwt.ClaimStrings{m.audience}
+	}
+
+	if m.active == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(m.signingKey))
+	}
+
+	token := jwt.NewWithClaims(m.active.method, claims)
+	token.Header["kid"] = m.active.id
+	return token.SignedString(m.active.private)
 }
 
+// ParseToken проверяет подпись, срок действия, а также iss и aud, если они
+// заданы в конфигурации.
 func (m *TokenManager) ParseToken(accessToken string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(accessToken, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(m.signingKey), nil
-	})
+	var opts []jwt.ParserOption
+	if m.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(m.issuer))
+	}
+	if m.audience != "" {
+		opts = append(opts, jwt.WithAudience(m.audience))
+	}
+
+	token, err := jwt.ParseWithClaims(accessToken, &Claims{}, m.verificationKey, opts...)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -63,3 +150,45 @@ func (m *TokenManager) ParseToken(accessToken string) (*Claims, error) {
 
 	return nil, ErrInvalidToken
 }
+
+// verificationKey подбирает ключ проверки подписи. Алгоритм токена должен
+// совпадать с алгоритмом ключа, иначе открытый ключ можно было бы выдать
+// за HMAC-секрет.
+func (m *TokenManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	if m.keys == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(m.signingKey), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := m.keys[kid]
+	if !ok || !m.accepts(key, time.Now()) {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// accepts сообщает, принимаются ли еще токены, подписанные ключом. Выведенный
+// из оборота ключ действует, пока не истекут выданные им access-токены.
+func (m *TokenManager) accepts(key *signingKey, now time.Time) bool {
+	return key.retiredAt == nil || now.Before(key.retiredAt.Add(m.ttl))
+}
+
+// JWKS возвращает открытые ключи, которыми еще можно проверить выданные
+// токены. В режиме HS256 набор пуст: общий секрет не публикуется.
+func (m *TokenManager) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	now := time.Now()
+	for _, key := range m.keys {
+		if m.accepts(key, now) {
+			set.Keys = append(set.Keys, key.jwk())
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
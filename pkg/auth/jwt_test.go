@@ -0,0 +1,347 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"marketplace/internal/config"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// testKey - пара ключей в PEM вместе с исходными ключами для подписи вручную.
+type testKey struct {
+	private    any
+	public     any
+	privatePEM string
+	publicPEM  string
+}
+
+func newEd25519Key(t *testing.T) testKey {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encodeTestKey(t, priv, pub)
+}
+
+func newRSAKey(t *testing.T) testKey {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encodeTestKey(t, priv, &priv.PublicKey)
+}
+
+func encodeTestKey(t *testing.T, priv, pub any) testKey {
+	t.Helper()
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{
+		private:    priv,
+		public:     pub,
+		privatePEM: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})),
+		publicPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})),
+	}
+}
+
+func newTestManager(t *testing.T, cfg config.Auth) *TokenManager {
+	t.Helper()
+	if cfg.AccessTokenTTL == 0 {
+		cfg.AccessTokenTTL = time.Hour
+	}
+	tm, err := NewTokenManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+// signToken подписывает утверждения произвольным методом и ключом, минуя TokenManager.
+func signToken(t *testing.T, method jwt.SigningMethod, key any, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		UserID: 7,
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestTokenManager_HS256(t *testing.T) {
+	tm := newTestManager(t, config.Auth{JWTSecret: "secret"})
+
+	token, err := tm.GenerateToken(7, "seller", "user", 2, "session")
+	assert.NoError(t, err)
+
+	claims, err := tm.ParseToken(token)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(7), claims.UserID)
+		assert.Equal(t, "seller", claims.Username)
+		assert.Equal(t, int64(2), claims.Generation)
+		assert.Equal(t, "session", claims.SessionID)
+		assert.NotEmpty(t, claims.ID)
+	}
+
+	// Токен, подписанный другим секретом или асимметричным ключом, не принимается.
+	_, err = newTestManager(t, config.Auth{JWTSecret: "other"}).ParseToken(token)
+	assert.Error(t, err)
+	_, err = tm.ParseToken(signToken(t, jwt.SigningMethodEdDSA, newEd25519Key(t).private, ""))
+	assert.Error(t, err)
+
+	assert.Empty(t, tm.JWKS().Keys)
+}
+
+func TestNewTokenManager_InvalidConfig(t *testing.T) {
+	key := newEd25519Key(t)
+	retired := time.Now().Add(-time.Minute).Format(time.RFC3339)
+
+	testCases := []struct {
+		name string
+		cfg  config.Auth
+	}{
+		{name: "Нет ни секрета, ни ключей", cfg: config.Auth{}},
+		{
+			name: "Активный ключ не задан",
+			cfg:  config.Auth{SigningKeys: []config.SigningKey{{ID: "a", PrivateKey: key.privatePEM}}, ActiveKeyID: "b"},
+		},
+		{
+			name: "У активного ключа нет закрытой части",
+			cfg:  config.Auth{SigningKeys: []config.SigningKey{{ID: "a", PublicKey: key.publicPEM}}, ActiveKeyID: "a"},
+		},
+		{
+			name: "Активный ключ выведен из оборота",
+			cfg:  config.Auth{SigningKeys: []config.SigningKey{{ID: "a", PrivateKey: key.privatePEM, RetiredAt: retired}}, ActiveKeyID: "a"},
+		},
+		{
+			name: "Повторяющийся kid",
+			cfg: config.Auth{SigningKeys: []config.SigningKey{
+				{ID: "a", PrivateKey: key.privatePEM},
+				{ID: "a", PublicKey: key.publicPEM},
+			}, ActiveKeyID: "a"},
+		},
+		{
+			name: "Испорченный PEM",
+			cfg:  config.Auth{SigningKeys: []config.SigningKey{{ID: "a", PrivateKey: "not a pem"}}, ActiveKeyID: "a"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewTokenManager(tc.cfg)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+// Ключ проверки выбирается по kid, а алгоритм токена должен совпадать с алгоритмом ключа
+func TestTokenManager_VerificationKey(t *testing.T) {
+	edKey := newEd25519Key(t)
+	rsaKey := newRSAKey(t)
+	otherRSA := newRSAKey(t)
+	tm := newTestManager(t, config.Auth{
+		SigningKeys: []config.SigningKey{
+			{ID: "ed", PrivateKey: edKey.privatePEM},
+			{ID: "rsa", PublicKey: rsaKey.publicPEM},
+		},
+		ActiveKeyID: "ed",
+	})
+	rsaPublicDER, _ := x509.MarshalPKIXPublicKey(rsaKey.public)
+
+	testCases := []struct {
+		name     string
+		token    string
+		expectOK bool
+	}{
+		{name: "Активный ключ", token: signToken(t, jwt.SigningMethodEdDSA, edKey.private, "ed"), expectOK: true},
+		{name: "Дополнительный RSA-ключ", token: signToken(t, jwt.SigningMethodRS256, rsaKey.private, "rsa"), expectOK: true},
+		{name: "Без kid", token: signToken(t, jwt.SigningMethodEdDSA, edKey.private, "")},
+		{name: "Неизвестный kid", token: signToken(t, jwt.SigningMethodEdDSA, edKey.private, "unknown")},
+		{name: "kid другого ключа", token: signToken(t, jwt.SigningMethodRS256, rsaKey.private, "ed")},
+		{name: "Подпись чужим ключом", token: signToken(t, jwt.SigningMethodRS256, otherRSA.private, "rsa")},
+		{name: "Открытый ключ как HMAC-секрет", token: signToken(t, jwt.SigningMethodHS256, rsaPublicDER, "rsa")},
+		{name: "Открытый PEM как HMAC-секрет", token: signToken(t, jwt.SigningMethodHS256, []byte(rsaKey.publicPEM), "rsa")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := tm.ParseToken(tc.token)
+
+			if tc.expectOK {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(7), claims.UserID)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestTokenManager_IssuerAudience(t *testing.T) {
+	base := config.Auth{JWTSecret: "secret", Issuer: "marketplace", Audience: "marketplace-api"}
+	tm := newTestManager(t, base)
+
+	token, _ := tm.GenerateToken(7, "seller", "user", 0, "")
+	claims, err := tm.ParseToken(token)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "marketplace", claims.Issuer)
+		assert.Equal(t, jwt.ClaimStrings{"marketplace-api"}, claims.Audience)
+	}
+
+	testCases := []struct {
+		name     string
+		issuer   string
+		audience string
+	}{
+		{name: "Другой издатель", issuer: "evil", audience: "marketplace-api"},
+		{name: "Другая аудитория", issuer: "marketplace", audience: "billing-api"},
+		{name: "Без издателя", audience: "marketplace-api"},
+		{name: "Без аудитории", issuer: "marketplace"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			other := newTestManager(t, config.Auth{JWTSecret: "secret", Issuer: tc.issuer, Audience: tc.audience})
+			foreign, _ := other.GenerateToken(7, "seller", "user", 0, "")
+
+			_, err := tm.ParseToken(foreign)
+
+			assert.Error(t, err)
+		})
+	}
+
+	// Без настроенных iss и aud они не проверяются.
+	_, err = newTestManager(t, config.Auth{JWTSecret: "secret"}).ParseToken(token)
+	assert.NoError(t, err)
+}
+
+// Выведенный из оборота ключ принимается, пока не истекут выданные им токены
+func TestTokenManager_RetiredKey(t *testing.T) {
+	oldKey := newEd25519Key(t)
+	newKey := newEd25519Key(t)
+	ttl := time.Hour
+
+	signer := newTestManager(t, config.Auth{
+		SigningKeys:    []config.SigningKey{{ID: "old", PrivateKey: oldKey.privatePEM}},
+		ActiveKeyID:    "old",
+		AccessTokenTTL: ttl,
+	})
+	token, err := signer.GenerateToken(7, "seller", "user", 0, "")
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		retiredAt time.Time
+		expectOK  bool
+	}{
+		{name: "Выведен недавно", retiredAt: time.Now().Add(-ttl / 2), expectOK: true},
+		{name: "Выведен в будущем", retiredAt: time.Now().Add(time.Hour), expectOK: true},
+		{name: "Токены ключа истекли", retiredAt: time.Now().Add(-2 * ttl)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tm := newTestManager(t, config.Auth{
+				SigningKeys: []config.SigningKey{
+					{ID: "new", PrivateKey: newKey.privatePEM},
+					{ID: "old", PublicKey: oldKey.publicPEM, RetiredAt: tc.retiredAt.Format(time.RFC3339)},
+				},
+				ActiveKeyID:    "new",
+				AccessTokenTTL: ttl,
+			})
+
+			_, err := tm.ParseToken(token)
+
+			if tc.expectOK {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+
+			var kids []string
+			for _, k := range tm.JWKS().Keys {
+				kids = append(kids, k.KeyID)
+			}
+			if tc.expectOK {
+				assert.Equal(t, []string{"new", "old"}, kids)
+			} else {
+				assert.Equal(t, []string{"new"}, kids)
+			}
+		})
+	}
+
+	// Новые токены подписываются активным ключом.
+	tm := newTestManager(t, config.Auth{
+		SigningKeys: []config.SigningKey{
+			{ID: "new", PrivateKey: newKey.privatePEM},
+			{ID: "old", PublicKey: oldKey.publicPEM, RetiredAt: time.Now().Format(time.RFC3339)},
+		},
+		ActiveKeyID:    "new",
+		AccessTokenTTL: ttl,
+	})
+	fresh, _ := tm.GenerateToken(7, "seller", "user", 0, "")
+	parsed, _, err := jwt.NewParser().ParseUnverified(fresh, &Claims{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "new", parsed.Header["kid"])
+		assert.Equal(t, "EdDSA", parsed.Header["alg"])
+	}
+}
+
+func TestTokenManager_JWKS(t *testing.T) {
+	edKey := newEd25519Key(t)
+	rsaKey := newRSAKey(t)
+	tm := newTestManager(t, config.Auth{
+		SigningKeys: []config.SigningKey{
+			{ID: "b-rsa", PublicKey: rsaKey.publicPEM},
+			{ID: "a-ed", PrivateKey: edKey.privatePEM},
+		},
+		ActiveKeyID: "a-ed",
+	})
+
+	keys := tm.JWKS().Keys
+
+	if !assert.Len(t, keys, 2) {
+		return
+	}
+	ed, rsaJWK := keys[0], keys[1]
+	assert.Equal(t, JWK{
+		KeyType:   "OKP",
+		KeyID:     "a-ed",
+		Use:       "sig",
+		Algorithm: "EdDSA",
+		Curve:     "Ed25519",
+		X:         base64.RawURLEncoding.EncodeToString(edKey.public.(ed25519.PublicKey)),
+	}, ed)
+
+	pub := rsaKey.public.(*rsa.PublicKey)
+	assert.Equal(t, "RSA", rsaJWK.KeyType)
+	assert.Equal(t, "b-rsa", rsaJWK.KeyID)
+	assert.Equal(t, "RS256", rsaJWK.Algorithm)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(pub.N.Bytes()), rsaJWK.N)
+	assert.Equal(t, "AQAB", rsaJWK.E)
+	assert.Empty(t, rsaJWK.X)
+}
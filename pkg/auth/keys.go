@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"marketplace/internal/config"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported signing key type")
+)
+
+// signingKey - ключ подписи access-токенов, загруженный из конфигурации.
+// У ключей, выведенных из оборота, может не быть закрытой части.
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	public    crypto.PublicKey
+	retiredAt *time.Time
+}
+
+// JWK - открытый ключ в формате RFC 7517. Для RSA заполняются n и e,
+// для Ed25519 (kty OKP) - crv и x.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKS - набор открытых ключей для проверки токенов сторонними сервисами.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// loadSigningKey читает ключ из PEM, заданного строкой или путем к файлу.
+func loadSigningKey(cfg config.SigningKey) (*signingKey, error) {
+	key := &signingKey{id: cfg.ID}
+
+	privatePEM, err := readPEM(cfg.PrivateKey, cfg.PrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: private key: %w", cfg.ID, err)
+	}
+	if privatePEM != nil {
+		key.private, err = parsePrivateKey(privatePEM)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", cfg.ID, err)
+		}
+		key.public = key.private.Public()
+	}
+
+	publicPEM, err := readPEM(cfg.PublicKey, cfg.PublicKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: public key: %w", cfg.ID, err)
+	}
+	if publicPEM != nil && key.public == nil {
+		key.public, err = parsePublicKey(publicPEM)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", cfg.ID, err)
+		}
+	}
+	if key.public == nil {
+		return nil, fmt.Errorf("key %q: no private or public key", cfg.ID)
+	}
+
+	switch key.public.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("key %q: %w", cfg.ID, ErrUnsupportedKey)
+	}
+
+	if cfg.RetiredAt != "" {
+		retiredAt, err := time.Parse(time.RFC3339, cfg.RetiredAt)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid retired_at: %w", cfg.ID, err)
+		}
+		key.retiredAt = &retiredAt
+	}
+	return key, nil
+}
+
+// readPEM возвращает первый PEM-блок из строки или файла. Если не задано
+// ни то ни другое, возвращается nil.
+func readPEM(inline, path string) (*pem.Block, error) {
+	data := []byte(inline)
+	if inline == "" {
+		if path == "" {
+			return nil, nil
+		}
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	return block, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, nil
+		case ed25519.PrivateKey:
+			return k, nil
+		}
+		return nil, ErrUnsupportedKey
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("failed to parse private key: expected PKCS#8 or PKCS#1")
+}
+
+func parsePublicKey(block *pem.Block) (crypto.PublicKey, error) {
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("failed to parse public key: expected PKIX or PKCS#1")
+}
+
+// jwk описывает открытую часть ключа.
+func (k *signingKey) jwk() JWK {
+	out := JWK{KeyID: k.id, Use: "sig", Algorithm: k.method.Alg()}
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		out.KeyType = "RSA"
+		out.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		out.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		out.KeyType = "OKP"
+		out.Curve = "Ed25519"
+		out.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	opaqueTokenBytes = 32
+	jtiBytes         = 16
+)
+
+// GenerateOpaqueToken создает непрозрачный токен (refresh-токен, токен сброса
+// пароля). Клиент получает токен целиком, в базе хранится только его хеш.
+func GenerateOpaqueToken() (string, error) {
+	token, err := randomString(opaqueTokenBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return token, nil
+}
+
+// HashOpaqueToken возвращает хеш непрозрачного токена для хранения и поиска в базе.
+// Токен содержит 256 бит случайных данных, поэтому медленный хеш не нужен.
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyPrefix отличает API-ключи от других токенов, например при поиске
+// утекших секретов в репозиториях.
+const APIKeyPrefix = "mk_"
+
+// apiKeyVisibleChars - сколько символов ключа после APIKeyPrefix показывается в списке ключей.
+const apiKeyVisibleChars = 8
+
+// GenerateAPIKey создает API-ключ и его видимый префикс. Как и остальные
+// непрозрачные токены, ключ хранится только в виде HashOpaqueToken.
+func GenerateAPIKey() (key, prefix string, err error) {
+	secret, err := randomString(opaqueTokenBytes)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key = APIKeyPrefix + secret
+	return key, key[:len(APIKeyPrefix)+apiKeyVisibleChars], nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
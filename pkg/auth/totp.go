@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"fmt"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	// totpPeriod - длительность временного шага TOTP в секундах.
+	totpPeriod = 30
+	// totpSkew - допустимое расхождение часов клиента и сервера в периодах по 30 секунд.
+	totpSkew = 1
+	// recoveryCodeBytes дает 80 бит на резервный код: 16 символов base32.
+	recoveryCodeBytes = 10
+	qrCodeSize        = 256
+)
+
+// GenerateTOTPKey создает новый секрет TOTP для учетной записи account.
+func GenerateTOTPKey(issuer, account string) (*otp.Key, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: account,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate totp key: %w", err)
+	}
+	return key, nil
+}
+
+// TOTPQRCode возвращает PNG с QR-кодом otpauth-URI ключа для сканирования
+// приложением-аутентификатором.
+func TOTPQRCode(key *otp.Key) ([]byte, error) {
+	img, err := key.Image(qrCodeSize, qrCodeSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render qr code: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode qr code: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// totpOpts - параметры кодов, которые понимают распространенные приложения-аутентификаторы.
+var totpOpts = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+// ValidateTOTP проверяет шестизначный код по секрету и возвращает номер
+// временного шага, которому соответствует код. По номеру шага вызывающий код
+// отклоняет повторное предъявление уже принятого кода.
+func ValidateTOTP(code, secret string) (int64, bool) {
+	return validateTOTPAt(code, secret, time.Now().UTC())
+}
+
+func validateTOTPAt(code, secret string, now time.Time) (int64, bool) {
+	if !IsTOTPCode(code) {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0).UTC(), totpOpts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// IsTOTPCode сообщает, похожа ли строка на код из приложения, а не на резервный код.
+func IsTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateRecoveryCodes создает n одноразовых резервных кодов вида xxxx-xxxx-xxxx-xxxx.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	b := make([]byte, recoveryCodeBytes)
+	for range n {
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+		codes = append(codes, raw[0:4]+"-"+raw[4:8]+"-"+raw[8:12]+"-"+raw[12:16])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode возвращает хеш резервного кода. Регистр и дефисы не важны,
+// чтобы код можно было ввести так, как удобно пользователю.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return HashOpaqueToken(normalized)
+}
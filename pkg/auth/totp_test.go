@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	key, err := GenerateTOTPKey("marketplace", "seller")
+	if !assert.NoError(t, err) {
+		return
+	}
+	other, _ := GenerateTOTPKey("marketplace", "buyer")
+
+	codeAt := func(secret string, at time.Time) string {
+		code, err := totp.GenerateCodeCustom(secret, at, totp.ValidateOpts{
+			Period:    30,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+	// Середина шага, чтобы соседние периоды не зависели от момента запуска.
+	now := time.Unix(1_800_000_015, 0).UTC()
+	current := now.Unix() / 30
+
+	testCases := []struct {
+		name       string
+		code       string
+		expect     bool
+		expectStep int64
+	}{
+		{name: "Текущий код", code: codeAt(key.Secret(), now), expect: true, expectStep: current},
+		{name: "Предыдущий период", code: codeAt(key.Secret(), now.Add(-30*time.Second)), expect: true, expectStep: current - 1},
+		{name: "Следующий период", code: codeAt(key.Secret(), now.Add(30*time.Second)), expect: true, expectStep: current + 1},
+		{name: "Устаревший код", code: codeAt(key.Secret(), now.Add(-5*time.Minute))},
+		{name: "Код другого секрета", code: codeAt(other.Secret(), now)},
+		{name: "Не шесть цифр", code: "12345"},
+		{name: "Пустой код", code: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			step, ok := validateTOTPAt(tc.code, key.Secret(), now)
+
+			assert.Equal(t, tc.expect, ok)
+			assert.Equal(t, tc.expectStep, step)
+		})
+	}
+
+	_, ok := validateTOTPAt(codeAt(key.Secret(), now), "not base32!", now)
+	assert.False(t, ok)
+
+	// Без явного времени код проверяется на текущий момент.
+	step, ok := ValidateTOTP(codeAt(key.Secret(), time.Now()), key.Secret())
+	assert.True(t, ok)
+	assert.InDelta(t, time.Now().Unix()/30, step, 1)
+}
+
+func TestIsTOTPCode(t *testing.T) {
+	assert.True(t, IsTOTPCode("012345"))
+	assert.False(t, IsTOTPCode("01234"))
+	assert.False(t, IsTOTPCode("0123456"))
+	assert.False(t, IsTOTPCode("01234a"))
+	assert.False(t, IsTOTPCode("abcd-efgh-ijkl-mnop"))
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+	format := regexp.MustCompile(`^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`)
+	seen := make(map[string]struct{}, len(codes))
+	for _, code := range codes {
+		assert.Regexp(t, format, code)
+		assert.False(t, IsTOTPCode(code))
+		seen[code] = struct{}{}
+	}
+	assert.Len(t, seen, len(codes))
+}
+
+// Хеш резервного кода не зависит от регистра, дефисов и пробелов
+func TestHashRecoveryCode(t *testing.T) {
+	expected := HashRecoveryCode("abcd-efgh-ijkl-mnop")
+
+	testCases := []struct {
+		name   string
+		code   string
+		expect bool
+	}{
+		{name: "Как выдан", code: "abcd-efgh-ijkl-mnop", expect: true},
+		{name: "Заглавными", code: "ABCD-EFGH-IJKL-MNOP", expect: true},
+		{name: "Без дефисов", code: "abcdefghijklmnop", expect: true},
+		{name: "С пробелами", code: "abcd efgh ijkl mnop", expect: true},
+		{name: "Другой код", code: "abcd-efgh-ijkl-mnoq"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expect, HashRecoveryCode(tc.code) == expected)
+		})
+	}
+
+	assert.NotEqual(t, "abcdefghijklmnop", expected)
+	assert.Equal(t, HashOpaqueToken("abcdefghijklmnop"), expected)
+}
@@ -1,13 +1,150 @@
 package hash
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var errUnknownHashFormat = errors.New("unknown password hash format")
+
+// Params - параметры argon2id. Memory задается в КиБ.
+type Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams - рекомендуемые параметры argon2id (RFC 9106, вариант
+// для ограниченной памяти: 64 МиБ, 3 прохода).
+var DefaultParams = Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Validate проверяет, что параметры допустимы для argon2id.
+func (p Params) Validate() error {
+	if p.Iterations < 1 {
+		return errors.New("iterations must be at least 1")
+	}
+	if p.Parallelism < 1 {
+		return errors.New("parallelism must be at least 1")
+	}
+	if p.Memory < 8*uint32(p.Parallelism) {
+		return errors.New("memory must be at least 8 KiB per lane")
+	}
+	if p.SaltLength < 8 {
+		return errors.New("salt length must be at least 8 bytes")
+	}
+	if p.KeyLength < 16 {
+		return errors.New("key length must be at least 16 bytes")
+	}
+	return nil
 }
 
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// Hasher хеширует пароли алгоритмом argon2id и проверяет хеши в формате PHC:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<соль>$<ключ>
+//
+// Для совместимости принимаются и bcrypt-хеши ($2a$, $2b$, $2y$), созданные
+// до перехода на argon2id.
+type Hasher struct {
+	params Params
+}
+
+func NewHasher(params Params) *Hasher {
+	return &Hasher{params: params}
+}
+
+// Hash возвращает argon2id-хеш пароля со случайной солью.
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("hash.Hash: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+	return encodeArgon2id(h.params, salt, key), nil
+}
+
+// Verify сравнивает пароль с хешем. needsRehash равен true, если пароль
+// верный, но хеш создан bcrypt или с параметрами, отличными от текущих, и его
+// стоит пересчитать.
+func (h *Hasher) Verify(password, encoded string) (ok, needsRehash bool) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false
+		}
+		actual := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		if subtle.ConstantTimeCompare(actual, key) != 1 {
+			return false, false
+		}
+		return true, params != h.params
+	case isBcrypt(encoded):
+		if bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) != nil {
+			return false, false
+		}
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+func encodeArgon2id(p Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func decodeArgon2id(encoded string) (Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", соль, ключ
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return Params{}, nil, nil, errUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Params{}, nil, nil, errUnknownHashFormat
+	}
+
+	var p Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return Params{}, nil, nil, errUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Params{}, nil, nil, errUnknownHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Params{}, nil, nil, errUnknownHashFormat
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	if p.Validate() != nil {
+		return Params{}, nil, nil, errUnknownHashFormat
+	}
+	return p, salt, key, nil
 }
@@ -0,0 +1,155 @@
+package hash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testParams = Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+// Хеш в формате PHC содержит параметры и проверяется тем же хешером
+func TestHasher_RoundTrip(t *testing.T) {
+	h := NewHasher(testParams)
+
+	encoded, err := h.Hash("password123")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$"))
+
+	params, salt, key, err := decodeArgon2id(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, testParams, params)
+	assert.Len(t, salt, 16)
+	assert.Len(t, key, 32)
+
+	ok, needsRehash := h.Verify("password123", encoded)
+	assert.True(t, ok)
+	assert.False(t, needsRehash)
+
+	ok, _ = h.Verify("password124", encoded)
+	assert.False(t, ok)
+
+	// Соль случайная, поэтому хеши одного пароля различаются.
+	again, _ := h.Hash("password123")
+	assert.NotEqual(t, encoded, again)
+}
+
+func TestHasher_VerifyMalformed(t *testing.T) {
+	h := NewHasher(testParams)
+	valid, _ := h.Hash("password123")
+	parts := strings.Split(valid, "$")
+
+	testCases := []struct {
+		name    string
+		encoded string
+	}{
+		{name: "Пустая строка", encoded: ""},
+		{name: "Открытый текст", encoded: "password123"},
+		{name: "Неизвестный алгоритм", encoded: "$argon2i$v=19$m=64,t=1,p=1$" + parts[4] + "$" + parts[5]},
+		{name: "Другая версия", encoded: "$argon2id$v=16$m=64,t=1,p=1$" + parts[4] + "$" + parts[5]},
+		{name: "Нет версии", encoded: "$argon2id$m=64,t=1,p=1$" + parts[4] + "$" + parts[5]},
+		{name: "Испорченные параметры", encoded: "$argon2id$v=19$m=x,t=1,p=1$" + parts[4] + "$" + parts[5]},
+		{name: "Недопустимые параметры", encoded: "$argon2id$v=19$m=64,t=0,p=1$" + parts[4] + "$" + parts[5]},
+		{name: "Соль не в base64", encoded: "$argon2id$v=19$m=64,t=1,p=1$!!!$" + parts[5]},
+		{name: "Короткий ключ", encoded: "$argon2id$v=19$m=64,t=1,p=1$" + parts[4] + "$" + parts[5][:8]},
+		{name: "Лишняя часть", encoded: valid + "$extra"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, needsRehash := h.Verify("password123", tc.encoded)
+
+			assert.False(t, ok)
+			assert.False(t, needsRehash)
+		})
+	}
+}
+
+// bcrypt-хеши, созданные до перехода на argon2id, принимаются и требуют пересчета
+func TestHasher_VerifyBcrypt(t *testing.T) {
+	h := NewHasher(testParams)
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		encoded      string
+		password     string
+		expectOK     bool
+		expectRehash bool
+	}{
+		{name: "Верный пароль", encoded: string(legacy), password: "password123", expectOK: true, expectRehash: true},
+		{name: "Неверный пароль", encoded: string(legacy), password: "password124"},
+		{name: "Префикс $2y$", encoded: "$2y$" + string(legacy)[4:], password: "password123", expectOK: true, expectRehash: true},
+		{name: "Испорченный хеш", encoded: "$2b$10$broken", password: "password123"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, needsRehash := h.Verify(tc.password, tc.encoded)
+
+			assert.Equal(t, tc.expectOK, ok)
+			assert.Equal(t, tc.expectRehash, needsRehash)
+		})
+	}
+}
+
+// Хеш с параметрами, отличными от текущих, нужно пересчитать
+func TestHasher_NeedsRehash(t *testing.T) {
+	encoded, _ := NewHasher(testParams).Hash("password123")
+
+	testCases := []struct {
+		name   string
+		change func(p *Params)
+		expect bool
+	}{
+		{name: "Те же параметры", change: func(p *Params) {}},
+		{name: "Больше памяти", change: func(p *Params) { p.Memory = 128 }, expect: true},
+		{name: "Больше проходов", change: func(p *Params) { p.Iterations = 2 }, expect: true},
+		{name: "Больше потоков", change: func(p *Params) { p.Parallelism = 2 }, expect: true},
+		{name: "Длиннее соль", change: func(p *Params) { p.SaltLength = 32 }, expect: true},
+		{name: "Длиннее ключ", change: func(p *Params) { p.KeyLength = 64 }, expect: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params := testParams
+			tc.change(&params)
+
+			ok, needsRehash := NewHasher(params).Verify("password123", encoded)
+
+			assert.True(t, ok)
+			assert.Equal(t, tc.expect, needsRehash)
+		})
+	}
+}
+
+func TestParams_Validate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		params    Params
+		expectErr bool
+	}{
+		{name: "Параметры по умолчанию", params: DefaultParams},
+		{name: "Тестовые параметры", params: testParams},
+		{name: "Нет проходов", params: Params{Memory: 64, Iterations: 0, Parallelism: 1, SaltLength: 16, KeyLength: 32}, expectErr: true},
+		{name: "Нет потоков", params: Params{Memory: 64, Iterations: 1, Parallelism: 0, SaltLength: 16, KeyLength: 32}, expectErr: true},
+		{name: "Мало памяти на поток", params: Params{Memory: 15, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32}, expectErr: true},
+		{name: "Короткая соль", params: Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 4, KeyLength: 32}, expectErr: true},
+		{name: "Короткий ключ", params: Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 8}, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.params.Validate()
+
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
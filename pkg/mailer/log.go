@@ -0,0 +1,40 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogMailer дописывает письма в файл вместо отправки. Подходит для локальной
+// разработки, когда SMTP-сервера нет.
+type LogMailer struct {
+	mu   sync.Mutex
+	path string
+	from string
+}
+
+func NewLogMailer(path, from string) *LogMailer {
+	return &LogMailer{path: path, from: from}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Письма содержат одноразовые токены, поэтому файл доступен только владельцу.
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("mailer.LogMailer.Send: %w", err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "--- %s\nFrom: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC3339), m.from, msg.To, msg.Subject, msg.Body)
+	if err != nil {
+		return fmt.Errorf("mailer.LogMailer.Send: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"marketplace/internal/config"
+)
+
+// Message - письмо с текстовым телом.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма пользователям.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New создает отправителя писем по настройке mail.driver.
+func New(cfg config.Mail) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg.SMTP, cfg.From), nil
+	case "log":
+		return NewLogMailer(cfg.LogPath, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
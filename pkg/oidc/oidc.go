@@ -0,0 +1,187 @@
+// Package oidc реализует вход через внешних провайдеров OpenID Connect
+// по схеме authorization code с PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"marketplace/internal/config"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrNoIDToken     = errors.New("token response has no id_token")
+	ErrNonceMismatch = errors.New("id token nonce mismatch")
+)
+
+// httpTimeout ограничивает запросы к провайдеру: discovery, JWKS и обмен кода.
+const httpTimeout = 10 * time.Second
+
+// Identity - проверенные утверждения ID-токена о пользователе.
+type Identity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+}
+
+// Provider - настроенный провайдер OpenID Connect. Discovery-документ
+// запрашивается при первом обращении, а не при старте приложения, чтобы
+// недоступность одного провайдера не мешала запуску.
+type Provider struct {
+	name   string
+	issuer string
+	oauth  oauth2.Config
+	client *http.Client
+
+	mu       sync.Mutex
+	endpoint *oauth2.Endpoint
+	verifier *gooidc.IDTokenVerifier
+}
+
+// Providers - провайдеры по имени из конфигурации.
+type Providers map[string]*Provider
+
+func NewProviders(cfgs []config.OIDCProvider) Providers {
+	providers := make(Providers, len(cfgs))
+	for _, cfg := range cfgs {
+		providers[cfg.Name] = NewProvider(cfg)
+	}
+	return providers
+}
+
+func NewProvider(cfg config.OIDCProvider) *Provider {
+	scopes := []string{gooidc.ScopeOpenID}
+	for _, s := range cfg.Scopes {
+		if s != gooidc.ScopeOpenID {
+			scopes = append(scopes, s)
+		}
+	}
+	return &Provider{
+		name:   cfg.Name,
+		issuer: cfg.Issuer,
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       scopes,
+		},
+		client: &http.Client{Timeout: httpTimeout},
+	}
+}
+
+// Name возвращает имя провайдера из конфигурации.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// NewVerifier создает секрет PKCE (code_verifier) для одной попытки входа.
+func NewVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// AuthCodeURL возвращает адрес страницы входа у провайдера. В запрос
+// добавляются state, nonce и code_challenge, вычисленный из verifier.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	cfg, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return cfg.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange обменивает код авторизации на токены и проверяет ID-токен: подпись,
+// издателя, аудиторию, срок действия и nonce.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	cfg, idVerifier, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = p.clientContext(ctx)
+	token, err := cfg.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: exchange code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, ErrNoIDToken
+	}
+
+	idToken, err := idVerifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: verify id token: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		return nil, ErrNonceMismatch
+	}
+
+	var claims struct {
+		Email             string    `json:"email"`
+		EmailVerified     claimBool `json:"email_verified"`
+		PreferredUsername string    `json:"preferred_username"`
+		Name              string    `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("oidc: decode id token claims: %w", err)
+	}
+
+	return &Identity{
+		Subject:           idToken.Subject,
+		Email:             claims.Email,
+		EmailVerified:     bool(claims.EmailVerified),
+		PreferredUsername: claims.PreferredUsername,
+		Name:              claims.Name,
+	}, nil
+}
+
+// discover загружает discovery-документ провайдера. Удачный результат
+// кешируется, после ошибки следующий запрос попробует снова.
+func (p *Provider) discover(ctx context.Context) (oauth2.Config, *gooidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.endpoint == nil {
+		provider, err := gooidc.NewProvider(p.clientContext(ctx), p.issuer)
+		if err != nil {
+			return oauth2.Config{}, nil, fmt.Errorf("oidc: discover %s: %w", p.name, err)
+		}
+		endpoint := provider.Endpoint()
+		p.endpoint = &endpoint
+		p.verifier = provider.Verifier(&gooidc.Config{ClientID: p.oauth.ClientID})
+	}
+
+	cfg := p.oauth
+	cfg.Endpoint = *p.endpoint
+	return cfg, p.verifier, nil
+}
+
+func (p *Provider) clientContext(ctx context.Context) context.Context {
+	ctx = gooidc.ClientContext(ctx, p.client)
+	return context.WithValue(ctx, oauth2.HTTPClient, p.client)
+}
+
+// claimBool читает email_verified, который некоторые провайдеры передают строкой.
+type claimBool bool
+
+func (b *claimBool) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid boolean claim %s", data)
+	}
+	*b = claimBool(v)
+	return nil
+}
@@ -0,0 +1,57 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rangePrefixLength - длина префикса SHA-1, по которому выбирается диапазон.
+const rangePrefixLength = 5
+
+// RangeDir ищет пароли в локальной копии базы утекших паролей, разбитой по
+// принципу k-анонимности: файл <PREFIX>.txt содержит строки вида SUFFIX:COUNT
+// для всех хешей SHA-1, начинающихся с пятисимвольного префикса PREFIX. Такой
+// набор выгружает, например, haveibeenpwned-downloader. Отсутствие файла
+// означает, что утечек с таким префиксом нет.
+type RangeDir struct {
+	dir string
+}
+
+func NewRangeDir(dir string) *RangeDir {
+	return &RangeDir{dir: dir}
+}
+
+// Contains сообщает, есть ли хеш пароля в наборе.
+func (d *RangeDir) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:rangePrefixLength], digest[rangePrefixLength:]
+
+	f, err := os.Open(filepath.Join(d.dir, prefix+".txt"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("passwordpolicy.RangeDir: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		candidate, _, _ := strings.Cut(scanner.Text(), ":")
+		if strings.EqualFold(strings.TrimSpace(candidate), suffix) {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("passwordpolicy.RangeDir: %w", err)
+	}
+	return false, nil
+}
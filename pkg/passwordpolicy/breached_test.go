@@ -0,0 +1,101 @@
+package passwordpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// SHA-1 пароля "password": 5BAA6 1E4C9B93F3F0682250B6CF8331B7EE68FD8.
+const (
+	passwordPrefix = "5BAA6"
+	passwordSuffix = "1E4C9B93F3F0682250B6CF8331B7EE68FD8"
+)
+
+func writeRange(t *testing.T, dir, prefix, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRangeDir_Contains(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		password string
+		expect   bool
+	}{
+		{
+			name:     "Суффикс со счетчиком",
+			content:  "0018A45C4D1DEF81644B54AB7F969B88D65:1\n" + passwordSuffix + ":9659365\n",
+			password: "password",
+			expect:   true,
+		},
+		{
+			name:     "Суффикс в нижнем регистре",
+			content:  "1e4c9b93f3f0682250b6cf8331b7ee68fd8:3\n",
+			password: "password",
+			expect:   true,
+		},
+		{
+			name:     "Пробелы и CRLF",
+			content:  "  " + passwordSuffix + " :3\r\n",
+			password: "password",
+			expect:   true,
+		},
+		{
+			name:     "Строка без счетчика",
+			content:  passwordSuffix + "\n",
+			password: "password",
+			expect:   true,
+		},
+		{
+			name:     "Суффикса нет в диапазоне",
+			content:  "0018A45C4D1DEF81644B54AB7F969B88D65:1\n",
+			password: "password",
+		},
+		{
+			name:     "Совпадает только начало суффикса",
+			content:  passwordSuffix[:20] + ":1\n",
+			password: "password",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeRange(t, dir, passwordPrefix, tc.content)
+
+			found, err := NewRangeDir(dir).Contains(tc.password)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expect, found)
+		})
+	}
+}
+
+// Отсутствие файла диапазона означает, что утечек с таким префиксом нет
+func TestRangeDir_MissingPrefixFile(t *testing.T) {
+	dir := t.TempDir()
+	writeRange(t, dir, "00000", passwordSuffix+":1\n")
+
+	found, err := NewRangeDir(dir).Contains("password")
+
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+// Файл диапазона, который нельзя прочитать, - ошибка, а не отсутствие утечки
+func TestRangeDir_Unreadable(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, passwordPrefix+".txt"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewRangeDir(dir).Contains("password")
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package passwordpolicy
+
+import (
+	"fmt"
+	"marketplace/internal/config"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Коды нарушений политики паролей. Клиент может показать по ним подсказку
+// на своем языке.
+const (
+	CodeTooShort         = "too_short"
+	CodeMissingLowercase = "missing_lowercase"
+	CodeMissingUppercase = "missing_uppercase"
+	CodeMissingDigit     = "missing_digit"
+	CodeMissingSymbol    = "missing_symbol"
+	CodeContainsUsername = "contains_username"
+	CodeBreached         = "breached"
+)
+
+// Violation - одно нарушенное требование к паролю.
+type Violation struct {
+	Code    string
+	Message string
+}
+
+// BreachedList сообщает, встречался ли пароль в известных утечках.
+type BreachedList interface {
+	Contains(password string) (bool, error)
+}
+
+// Policy проверяет пароль на длину, наличие классов символов, вхождение имени
+// пользователя и присутствие в списке утекших паролей.
+type Policy struct {
+	MinLength        int
+	RequireLowercase bool
+	RequireUppercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	ForbidUsername   bool
+	// Breached - список утекших паролей; nil отключает проверку.
+	Breached BreachedList
+}
+
+// NewFromConfig собирает политику из конфигурации. Если задан BreachedPath,
+// пароли сверяются с диапазонами хешей из этого каталога.
+func NewFromConfig(cfg config.PasswordPolicy) *Policy {
+	p := &Policy{
+		MinLength:        cfg.MinLength,
+		RequireLowercase: cfg.RequireLowercase,
+		RequireUppercase: cfg.RequireUppercase,
+		RequireDigit:     cfg.RequireDigit,
+		RequireSymbol:    cfg.RequireSymbol,
+		ForbidUsername:   cfg.ForbidUsername,
+	}
+	if cfg.BreachedPath != "" {
+		p.Breached = NewRangeDir(cfg.BreachedPath)
+	}
+	return p
+}
+
+// Check возвращает все нарушенные требования. Пустой результат означает, что
+// пароль подходит. Ошибка возвращается, только если не удалось прочитать
+// список утекших паролей. Для nil-политики любой пароль подходит.
+func (p *Policy) Check(password, username string) ([]Violation, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	var violations []Violation
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, Violation{
+			Code:    CodeTooShort,
+			Message: fmt.Sprintf("password must be at least %d characters long", p.MinLength),
+		})
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+	if p.RequireLowercase && !lower {
+		violations = append(violations, Violation{Code: CodeMissingLowercase, Message: "password must contain a lowercase letter"})
+	}
+	if p.RequireUppercase && !upper {
+		violations = append(violations, Violation{Code: CodeMissingUppercase, Message: "password must contain an uppercase letter"})
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, Violation{Code: CodeMissingDigit, Message: "password must contain a digit"})
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, Violation{Code: CodeMissingSymbol, Message: "password must contain a symbol"})
+	}
+
+	if p.ForbidUsername && username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		violations = append(violations, Violation{Code: CodeContainsUsername, Message: "password must not contain the username"})
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return nil, fmt.Errorf("passwordpolicy.Check: %w", err)
+		}
+		if breached {
+			violations = append(violations, Violation{Code: CodeBreached, Message: "password has appeared in a data breach"})
+		}
+	}
+	return violations, nil
+}
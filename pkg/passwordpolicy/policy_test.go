@@ -0,0 +1,101 @@
+package passwordpolicy
+
+import (
+	"errors"
+	"marketplace/internal/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubBreached - список утекших паролей в памяти.
+type stubBreached struct {
+	passwords map[string]bool
+	err       error
+}
+
+func (s stubBreached) Contains(password string) (bool, error) {
+	return s.passwords[password], s.err
+}
+
+func codes(violations []Violation) []string {
+	result := make([]string, 0, len(violations))
+	for _, v := range violations {
+		result = append(result, v.Code)
+	}
+	return result
+}
+
+func TestPolicy_Check(t *testing.T) {
+	strict := &Policy{
+		MinLength:        10,
+		RequireLowercase: true,
+		RequireUppercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		ForbidUsername:   true,
+		Breached:         stubBreached{passwords: map[string]bool{"Password123!": true}},
+	}
+
+	testCases := []struct {
+		name     string
+		policy   *Policy
+		password string
+		username string
+		expect   []string
+	}{
+		{name: "Подходящий пароль", policy: strict, password: "Correct-Horse-42", username: "seller", expect: []string{}},
+		{name: "Короткий", policy: strict, password: "Ab1!", username: "seller", expect: []string{CodeTooShort}},
+		{name: "Длина в символах, а не байтах", policy: &Policy{MinLength: 6}, password: "пароль", expect: []string{}},
+		{name: "Без строчных", policy: strict, password: "CORRECT-HORSE-42", username: "seller", expect: []string{CodeMissingLowercase}},
+		{name: "Без заглавных", policy: strict, password: "correct-horse-42", username: "seller", expect: []string{CodeMissingUppercase}},
+		{name: "Без цифр", policy: strict, password: "Correct-Horse-Battery", username: "seller", expect: []string{CodeMissingDigit}},
+		{name: "Без символов", policy: strict, password: "CorrectHorse42", username: "seller", expect: []string{CodeMissingSymbol}},
+		{name: "Кириллица считается буквами", policy: strict, password: "Правильный-Конь-42", username: "seller", expect: []string{}},
+		{name: "Содержит имя без учета регистра", policy: strict, password: "My-SELLER-pass-42", username: "seller", expect: []string{CodeContainsUsername}},
+		{name: "Имя разрешено политикой", policy: &Policy{}, password: "seller", username: "seller", expect: []string{}},
+		{name: "Утекший пароль", policy: strict, password: "Password123!", username: "seller", expect: []string{CodeBreached}},
+		{
+			name:     "Все нарушения сразу",
+			policy:   strict,
+			password: "",
+			username: "seller",
+			expect:   []string{CodeTooShort, CodeMissingLowercase, CodeMissingUppercase, CodeMissingDigit, CodeMissingSymbol},
+		},
+		{name: "Политика не задана", policy: nil, password: "1", username: "seller", expect: []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations, err := tc.policy.Check(tc.password, tc.username)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expect, codes(violations))
+			for _, v := range violations {
+				assert.NotEmpty(t, v.Message)
+			}
+		})
+	}
+}
+
+// Ошибка чтения списка утечек не выдается за нарушение политики
+func TestPolicy_CheckBreachedError(t *testing.T) {
+	policy := &Policy{Breached: stubBreached{err: errors.New("disk failure")}}
+
+	violations, err := policy.Check("Correct-Horse-42", "seller")
+
+	assert.Error(t, err)
+	assert.Nil(t, violations)
+}
+
+func TestNewFromConfig(t *testing.T) {
+	policy := NewFromConfig(config.PasswordPolicy{MinLength: 12, RequireDigit: true, ForbidUsername: true})
+
+	assert.Equal(t, 12, policy.MinLength)
+	assert.True(t, policy.RequireDigit)
+	assert.True(t, policy.ForbidUsername)
+	assert.Nil(t, policy.Breached)
+
+	policy = NewFromConfig(config.PasswordPolicy{BreachedPath: "/var/lib/pwned"})
+	assert.Equal(t, NewRangeDir("/var/lib/pwned"), policy.Breached)
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Result описывает решение лимитера по одному запросу.
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter ограничивает количество запросов по произвольному ключу (например, IP клиента).
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// RedisLimiter реализует ограничение по фиксированному окну на счетчиках Redis.
+type RedisLimiter struct {
+	client *redis.Client
+	prefix string
+	limit  int
+	window time.Duration
+}
+
+// NewRedisLimiter создает лимитер, пропускающий не более limit запросов за window.
+func NewRedisLimiter(client *redis.Client, prefix string, limit int, window time.Duration) *RedisLimiter {
+	return &RedisLimiter{
+		client: client,
+		prefix: prefix,
+		limit:  limit,
+		window: window,
+	}
+}
+
+// Allow увеличивает счетчик ключа в текущем окне и сообщает, можно ли выполнить запрос.
+func (l *RedisLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	redisKey := fmt.Sprintf("ratelimit:%s:%s", l.prefix, key)
+
+	var incr *redis.IntCmd
+	var ttl *redis.DurationCmd
+	_, err := l.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, redisKey)
+		// NX выставляет время жизни только первому запросу в окне.
+		pipe.ExpireNX(ctx, redisKey, l.window)
+		ttl = pipe.PTTL(ctx, redisKey)
+		return nil
+	})
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit.Allow: %w", err)
+	}
+
+	count := int(incr.Val())
+	if count > l.limit {
+		retryAfter := ttl.Val()
+		if retryAfter <= 0 {
+			retryAfter = l.window
+		}
+		return Result{Allowed: false, RetryAfter: retryAfter}, nil
+	}
+
+	return Result{Allowed: true, Remaining: l.limit - count}, nil
+}
@@ -0,0 +1,14 @@
+package screening
+
+import "strings"
+
+// Normalize приводит заголовок и описание объявления к виду, в котором их
+// сравнивают при поиске повторов. Текст приводится к нижнему регистру,
+// гомоглифы заменяются, а пунктуация и лишние пробелы отбрасываются, поэтому
+// копии с косметическими правками дают одинаковый результат.
+//
+// Похожие, но не одинаковые тексты (переставленные, добавленные или
+// исправленные слова) сравниваются уже по сходству триграмм в базе данных.
+func Normalize(title, description string) string {
+	return strings.Join(words(Skeleton(title+" "+description)), " ")
+}
@@ -0,0 +1,35 @@
+package screening
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	base := Normalize("Продам диван", "Почти новый, самовывоз")
+
+	testCases := []struct {
+		name        string
+		title       string
+		description string
+		expectSame  bool
+	}{
+		{name: "Регистр", title: "ПРОДАМ ДИВАН", description: "почти новый, самовывоз", expectSame: true},
+		{name: "Пунктуация и пробелы", title: "Продам  диван!!!", description: "Почти новый - самовывоз.", expectSame: true},
+		{name: "Подмена букв", title: "Пpoдaм дивaн", description: "Почти новый, самовывоз", expectSame: true},
+		{name: "Граница между заголовком и описанием", title: "Продам", description: "диван почти новый самовывоз", expectSame: true},
+		{name: "Другое слово", title: "Продам кресло", description: "Почти новый, самовывоз"},
+		{name: "Переставленные слова", title: "Диван продам", description: "Почти новый, самовывоз"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectSame, Normalize(tc.title, tc.description) == base)
+		})
+	}
+
+	// Слова разделяются одним пробелом, без пробелов по краям.
+	assert.Equal(t, Skeleton("продам диван почти новый самовывоз"), base)
+	assert.Empty(t, Normalize(" !!! ", "-"))
+}
@@ -0,0 +1,159 @@
+package screening
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// homoglyphs сводит визуально одинаковые кириллические и латинские буквы
+// (и цифры, которыми их подменяют) к одному латинскому символу.
+var homoglyphs = map[rune]rune{
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h',
+	'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x',
+	'і': 'i', 'ѕ': 's', 'ј': 'j',
+	'0': 'o', '3': 'з', '6': 'б',
+}
+
+// Skeleton приводит строку к виду, устойчивому к подмене букв: нижний регистр,
+// затем замена гомоглифов. «КАЗИНО», «кaзинo» и «kaзинo» дают одинаковый результат.
+func Skeleton(s string) string {
+	return strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if mapped, ok := homoglyphs[r]; ok {
+			return mapped
+		}
+		return r
+	}, s)
+}
+
+// words разбивает строку на слова из букв и цифр.
+func words(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// BannedWordsRule ищет запрещенные слова с учетом подмены букв.
+// Слово считается найденным, если с него начинается любое слово текста,
+// поэтому словоформы («наркотик» - «наркотики») тоже срабатывают.
+type BannedWordsRule struct {
+	words []string
+}
+
+func NewBannedWordsRule(banned []string) *BannedWordsRule {
+	skeletons := make([]string, 0, len(banned))
+	for _, w := range banned {
+		if w = strings.TrimSpace(w); w != "" {
+			skeletons = append(skeletons, Skeleton(w))
+		}
+	}
+	return &BannedWordsRule{words: skeletons}
+}
+
+func (r *BannedWordsRule) Name() string { return "banned_words" }
+
+func (r *BannedWordsRule) Check(c Content) []Violation {
+	var violations []Violation
+	for _, f := range c.fields() {
+		if r.contains(f.text) {
+			violations = append(violations, Violation{
+				Rule:    r.Name(),
+				Field:   f.name,
+				Message: "contains a prohibited word",
+			})
+		}
+	}
+	return violations
+}
+
+func (r *BannedWordsRule) contains(text string) bool {
+	for _, token := range words(Skeleton(text)) {
+		for _, banned := range r.words {
+			if strings.HasPrefix(token, banned) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	// phoneCandidate находит последовательности цифр с типичными разделителями номера.
+	phoneCandidate = regexp.MustCompile(`\+?\d[\d\s\-().]{8,}\d`)
+	// linkPattern находит URL, адреса с www, ссылки на мессенджеры и голые домены.
+	linkPattern = regexp.MustCompile(`(?i)(https?://|www\.|t\.me/|wa\.me/|\b[a-z0-9-]+\.(ru|com|net|org|рф|me|io|su|info|biz)\b)`)
+)
+
+const minPhoneDigits = 10
+
+// ContactsRule ищет в описании телефоны и ссылки, через которые продавцы уводят
+// покупателей с площадки.
+type ContactsRule struct{}
+
+func NewContactsRule() *ContactsRule {
+	return &ContactsRule{}
+}
+
+func (r *ContactsRule) Name() string { return "contacts" }
+
+func (r *ContactsRule) Check(c Content) []Violation {
+	if linkPattern.MatchString(c.Description) {
+		return []Violation{{Rule: r.Name(), Field: FieldDescription, Message: "must not contain links"}}
+	}
+	for _, candidate := range phoneCandidate.FindAllString(c.Description, -1) {
+		digits := 0
+		for _, ch := range candidate {
+			if unicode.IsDigit(ch) {
+				digits++
+			}
+		}
+		if digits >= minPhoneDigits {
+			return []Violation{{Rule: r.Name(), Field: FieldDescription, Message: "must not contain phone numbers"}}
+		}
+	}
+	return nil
+}
+
+// CapsRule срабатывает на текст, набранный преимущественно заглавными буквами.
+// Короткие строки (меньше minLetters букв) не проверяются: «iPhone 15 PRO» - нормальный заголовок.
+type CapsRule struct {
+	maxRatio   float64
+	minLetters int
+}
+
+func NewCapsRule(maxRatio float64, minLetters int) *CapsRule {
+	return &CapsRule{maxRatio: maxRatio, minLetters: minLetters}
+}
+
+func (r *CapsRule) Name() string { return "caps" }
+
+func (r *CapsRule) Check(c Content) []Violation {
+	var violations []Violation
+	for _, f := range c.fields() {
+		if r.excessive(f.text) {
+			violations = append(violations, Violation{
+				Rule:    r.Name(),
+				Field:   f.name,
+				Message: "too many capital letters",
+			})
+		}
+	}
+	return violations
+}
+
+func (r *CapsRule) excessive(text string) bool {
+	letters, upper := 0, 0
+	for _, ch := range text {
+		if unicode.IsLetter(ch) {
+			letters++
+			if unicode.IsUpper(ch) {
+				upper++
+			}
+		}
+	}
+	if letters < r.minLetters || letters == 0 {
+		return false
+	}
+	return float64(upper)/float64(letters) > r.maxRatio
+}
@@ -0,0 +1,134 @@
+package screening
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkeleton(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		other string
+	}{
+		{name: "Регистр", input: "КАЗИНО", other: "казино"},
+		{name: "Латиница вместо кириллицы", input: "кaзинo", other: "казино"},
+		{name: "Смешанный текст", input: "kaзинo", other: "КАЗИНО"},
+		{name: "Цифры вместо букв", input: "kaзин0", other: "казино"},
+		{name: "Тройка вместо З", input: "ка3ино", other: "казино"},
+		{name: "Шестерка вместо Б", input: "6анк", other: "банк"},
+		{name: "Ё и Е", input: "ёлка", other: "елка"},
+		{name: "Украинская і", input: "іphone", other: "iPhone"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, Skeleton(tc.other), Skeleton(tc.input))
+		})
+	}
+
+	// Буквы без двойников не меняются.
+	assert.Equal(t, "шлюз", Skeleton("Шлюз"))
+	assert.NotEqual(t, Skeleton("казино"), Skeleton("кино"))
+}
+
+func TestBannedWordsRule(t *testing.T) {
+	rule := NewBannedWordsRule([]string{"казино", " наркотик ", ""})
+
+	testCases := []struct {
+		name    string
+		content Content
+		fields  []string
+	}{
+		{name: "Чистый текст", content: Content{Title: "Продам диван", Description: "Почти новый"}},
+		{name: "Слово в заголовке", content: Content{Title: "Лучшее казино", Description: "Почти новый"}, fields: []string{FieldTitle}},
+		{name: "Подмена букв", content: Content{Title: "Диван", Description: "Заходи в KAЗИНO"}, fields: []string{FieldDescription}},
+		{name: "Словоформа", content: Content{Title: "Наркотики", Description: "Казиноbet"}, fields: []string{FieldTitle, FieldDescription}},
+		{name: "Слово внутри другого слова", content: Content{Title: "Суперказино", Description: ""}},
+		{name: "Слово с пунктуацией", content: Content{Title: "«казино»!", Description: ""}, fields: []string{FieldTitle}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations := rule.Check(tc.content)
+
+			var fields []string
+			for _, v := range violations {
+				assert.Equal(t, "banned_words", v.Rule)
+				fields = append(fields, v.Field)
+			}
+			assert.Equal(t, tc.fields, fields)
+		})
+	}
+
+	// Пустые слова из конфигурации отбрасываются и не срабатывают на любой текст.
+	assert.Empty(t, NewBannedWordsRule([]string{"", "  "}).Check(Content{Title: "Продам диван"}))
+}
+
+func TestContactsRule(t *testing.T) {
+	rule := NewContactsRule()
+
+	testCases := []struct {
+		name        string
+		description string
+		message     string
+	}{
+		{name: "Без контактов", description: "Диван, почти новый, 2 года"},
+		{name: "Телефон со скобками", description: "Звоните: +7 (912) 345-67-89", message: "must not contain phone numbers"},
+		{name: "Телефон подряд", description: "89123456789", message: "must not contain phone numbers"},
+		{name: "Телефон с пробелами", description: "8 912 345 67 89", message: "must not contain phone numbers"},
+		{name: "Мало цифр для телефона", description: "Размер 200 x 180 x 45", message: ""},
+		{name: "Ссылка", description: "Подробнее на https://example.com/ad", message: "must not contain links"},
+		{name: "Адрес с www", description: "см. www.example", message: "must not contain links"},
+		{name: "Мессенджер", description: "Пишите в t.me/seller", message: "must not contain links"},
+		{name: "Голый домен", description: "Фото на avito.ru", message: "must not contain links"},
+		{name: "Домен заглавными", description: "EXAMPLE.COM", message: "must not contain links"},
+		{name: "Точка в конце предложения", description: "Почти новый.Торг", message: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations := rule.Check(Content{Title: "+7 912 345-67-89", Description: tc.description})
+
+			if tc.message == "" {
+				assert.Empty(t, violations)
+				return
+			}
+			if assert.Len(t, violations, 1) {
+				assert.Equal(t, "contacts", violations[0].Rule)
+				assert.Equal(t, FieldDescription, violations[0].Field)
+				assert.Equal(t, tc.message, violations[0].Message)
+			}
+		})
+	}
+}
+
+func TestCapsRule(t *testing.T) {
+	rule := NewCapsRule(0.5, 10)
+
+	testCases := []struct {
+		name   string
+		text   string
+		expect bool
+	}{
+		{name: "Обычный текст", text: "Продам диван в хорошем состоянии"},
+		{name: "Весь текст заглавными", text: "СРОЧНО ПРОДАМ ДИВАН", expect: true},
+		{name: "Короткая строка", text: "iPhone 15 PRO"},
+		{name: "Ровно на пороге", text: "ABCDEabcde"},
+		{name: "Чуть выше порога", text: "ABCDEFabcd", expect: true},
+		{name: "Цифры не считаются буквами", text: "ДИВАН 1234567890"},
+		{name: "Без букв", text: "1234567890 !!!"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations := rule.Check(Content{Title: tc.text})
+
+			assert.Equal(t, tc.expect, len(violations) == 1)
+			for _, v := range violations {
+				assert.Equal(t, FieldTitle, v.Field)
+			}
+		})
+	}
+}
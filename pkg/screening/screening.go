@@ -0,0 +1,131 @@
+package screening
+
+import "marketplace/internal/config"
+
+// Action определяет, что делать с объявлением, нарушившим правило.
+type Action string
+
+const (
+	// ActionReject отклоняет объявление с ошибкой в конкретном поле.
+	ActionReject Action = "reject"
+	// ActionPremoderate публикует объявление только после проверки модератором.
+	ActionPremoderate Action = "premoderate"
+	// ActionOff отключает правило.
+	ActionOff Action = "off"
+)
+
+const (
+	FieldTitle       = "title"
+	FieldDescription = "description"
+)
+
+// Content - проверяемые поля объявления.
+type Content struct {
+	Title       string
+	Description string
+}
+
+type field struct {
+	name string
+	text string
+}
+
+func (c Content) fields() []field {
+	return []field{
+		{name: FieldTitle, text: c.Title},
+		{name: FieldDescription, text: c.Description},
+	}
+}
+
+// Violation описывает срабатывание правила на одном поле.
+type Violation struct {
+	Rule    string
+	Field   string
+	Action  Action
+	Message string
+}
+
+// Rule - одно правило проверки. Check возвращает нарушения без учета действия,
+// действие проставляет Screener.
+type Rule interface {
+	Name() string
+	Check(c Content) []Violation
+}
+
+// Result - итог проверки объявления всеми правилами.
+type Result struct {
+	Violations []Violation
+}
+
+// Rejections возвращает нарушения, из-за которых объявление должно быть отклонено.
+func (r Result) Rejections() []Violation {
+	var result []Violation
+	for _, v := range r.Violations {
+		if v.Action == ActionReject {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// NeedsPremoderation сообщает, нужно ли отправить объявление на проверку модератору.
+func (r Result) NeedsPremoderation() bool {
+	for _, v := range r.Violations {
+		if v.Action == ActionPremoderate {
+			return true
+		}
+	}
+	return false
+}
+
+type configuredRule struct {
+	rule   Rule
+	action Action
+}
+
+// Screener прогоняет объявление через набор правил.
+type Screener struct {
+	rules []configuredRule
+}
+
+// NewScreener создает проверку без правил; правила добавляются через Add.
+func NewScreener() *Screener {
+	return &Screener{}
+}
+
+// Add подключает правило с указанным действием. Правила с ActionOff пропускаются.
+func (s *Screener) Add(rule Rule, action Action) *Screener {
+	if action != ActionOff {
+		s.rules = append(s.rules, configuredRule{rule: rule, action: action})
+	}
+	return s
+}
+
+// Screen проверяет объявление всеми подключенными правилами.
+func (s *Screener) Screen(c Content) Result {
+	var result Result
+	for _, r := range s.rules {
+		for _, v := range r.rule.Check(c) {
+			v.Action = r.action
+			result.Violations = append(result.Violations, v)
+		}
+	}
+	return result
+}
+
+// NewFromConfig собирает набор правил из конфигурации.
+// Действия правил проверяются при загрузке конфигурации.
+func NewFromConfig(cfg config.Screening) *Screener {
+	return NewScreener().
+		Add(NewBannedWordsRule(cfg.BannedWords.Words), actionOrOff(cfg.BannedWords.Action)).
+		Add(NewContactsRule(), actionOrOff(cfg.Contacts.Action)).
+		Add(NewCapsRule(cfg.Caps.MaxRatio, cfg.Caps.MinLetters), actionOrOff(cfg.Caps.Action))
+}
+
+// actionOrOff трактует не заданное действие как выключенное правило.
+func actionOrOff(action string) Action {
+	if action == "" {
+		return ActionOff
+	}
+	return Action(action)
+}